@@ -2,9 +2,17 @@ package main
 
 import (
 	"flag"
+	"strings"
+	"time"
 
+	"gopkg.in/guregu/null.v3"
+
+	"github.com/wpwilson10/caterpillar/internal/api"
+	"github.com/wpwilson10/caterpillar/internal/export"
 	"github.com/wpwilson10/caterpillar/internal/news"
 	"github.com/wpwilson10/caterpillar/internal/reddit"
+	"github.com/wpwilson10/caterpillar/internal/search"
+	"github.com/wpwilson10/caterpillar/internal/sentiment"
 	"github.com/wpwilson10/caterpillar/internal/setup"
 	"github.com/wpwilson10/caterpillar/internal/setup/logsummary"
 	"github.com/wpwilson10/caterpillar/internal/stocks"
@@ -32,12 +40,56 @@ func main() {
 }
 
 // select app parses input arguments and returns an app's configuration
+// Each app has its own port, which setup.RunOnce uses to allow only one instance of the app at a time
 func selectApp() (string, int, func()) {
 	// check command line arguments
 	testFlag := flag.Bool("test", false, "Test program")
 	redditBotFlag := flag.Bool("redditBot", false, "RedditBot")
 	redditAppFlag := flag.Bool("redditApp", false, "RedditApp")
+	redditBackfillMentionsFlag := flag.Bool("redditBackfillMentions", false, "RedditBackfillMentions")
 	newsAppFlag := flag.Bool("newsApp", false, "NewsApp")
+	newsFeedHealthFlag := flag.Bool("newsFeedHealth", false, "NewsFeedHealth")
+	newsFeedAddFlag := flag.String("newsFeedAdd", "", "NewsFeedAdd rss url")
+	newsFeedDisableFlag := flag.String("newsFeedDisable", "", "NewsFeedDisable rss url")
+	newsFeedImportFlag := flag.String("newsFeedImport", "", "NewsFeedImport csv filepath")
+	// feed catalog values used with newsFeedAdd
+	feedName := flag.String("feedName", "", "Feed name")
+	feedLink := flag.String("feedLink", "", "Feed publisher website")
+	feedCategory := flag.String("feedCategory", "", "Feed category")
+	feedType := flag.String("feedType", news.FeedRSS, "Feed type, rss or sitemap")
+	feedLanguage := flag.String("feedLanguage", "", "Feed language code")
+	feedRegion := flag.String("feedRegion", "", "Feed region code")
+	feedInterval := flag.Int("feedInterval", 0, "Minimum minutes between feed polls")
+	newsBackfillRedditFlag := flag.Bool("newsBackfillReddit", false, "NewsBackfillReddit")
+	// backfill values used with newsBackfillReddit, newsBackfillAuthors, newsBackfillListings, newsReextract,
+	// redditBackfillMentions, and sentiment
+	// from and to are also optional date filters for search and sentimentSeries, batch is also the export batch size
+	backfillFrom := flag.String("from", "", "Backfill start date, inclusive (YYYY-MM-DD)")
+	backfillTo := flag.String("to", "", "Backfill end date, exclusive (YYYY-MM-DD)")
+	backfillBatch := flag.Int("batch", 500, "Backfill batch size")
+	newsBackfillAuthorsFlag := flag.Bool("newsBackfillAuthors", false, "NewsBackfillAuthors")
+	newsBackfillListingsFlag := flag.Bool("newsBackfillListings", false, "NewsBackfillListings")
+	newsAuthorFlag := flag.String("newsAuthor", "", "NewsAuthor name")
+	newsReextractFlag := flag.Bool("newsReextract", false, "NewsReextract")
+	newsHostQualityFlag := flag.Int("newsHostQuality", 0, "NewsHostQuality minimum articles per host")
+	newsRecrawlFlag := flag.Bool("newsRecrawl", false, "NewsRecrawl")
+	newsRevisionsFlag := flag.Int64("newsRevisions", 0, "NewsRevisions article id")
+	newsSyndicationsFlag := flag.Int64("newsSyndications", 0, "NewsSyndications article id")
+	searchFlag := flag.String("search", "", "Search query, supports \"quoted phrases\", or, and -excluded words")
+	// search filters used with search
+	searchKind := flag.String("searchKind", "", "Search kinds, comma separated: news, reddit, article, submission, comment")
+	searchHost := flag.String("searchHost", "", "Search only articles from this host")
+	searchSubreddit := flag.String("searchSubreddit", "", "Search only reddit content from this subreddit")
+	searchLimit := flag.Int("searchLimit", 20, "Most search results")
+	exportFlag := flag.String("export", "", "Export tables, comma separated or all: newsarticle, redditsubmission, redditcomment, intraday, listing")
+	// export values used with export
+	exportFormat := flag.String("exportFormat", export.FormatJSONL, "Export format: jsonl, csv, or parquet")
+	exportDir := flag.String("exportDir", "", "Export root directory, defaults to EXPORT_DIR")
+	exportColumns := flag.String("exportColumns", "", "Export only these columns, comma separated")
+	apiFlag := flag.Bool("api", false, "API server")
+	sentimentFlag := flag.String("sentiment", "", "Sentiment kinds to score, comma separated or all: news, reddit, article, submission, comment")
+	sentimentIncremental := flag.Bool("sentimentIncremental", false, "Score only content added since the last incremental run, instead of the from and to range")
+	sentimentSeriesFlag := flag.String("sentimentSeries", "", "Daily sentiment of host:name, subreddit:name, or listing:symbol")
 	iexAppFlag := flag.Bool("iexApp", false, "IEXApp")
 	iexUpdateFlag := flag.Bool("iexUpdate", false, "IEXUpdateApp")
 	iexActiveFlag := flag.Bool("iexActive", false, "IEXIndexApp")
@@ -50,17 +102,105 @@ func selectApp() (string, int, func()) {
 	case *testFlag:
 		return "TestApp", 9997, test
 	case *redditBotFlag:
-		return "RedditBot", setup.EnvToInt("REDDIT_BOT_PORT"), reddit.BotApp
+		return "RedditBot", envPort("REDDIT_BOT_PORT"), reddit.BotApp
 	case *redditAppFlag:
-		return "RedditApp", setup.EnvToInt("REDDIT_PORT"), reddit.App
+		return "RedditApp", envPort("REDDIT_PORT"), reddit.App
+	case *redditBackfillMentionsFlag:
+		from, to := flagDate(*backfillFrom), flagDate(*backfillTo)
+		return "RedditBackfillMentions", 9990, func() { reddit.MentionBackfillApp(from, to, *backfillBatch) }
 	case *newsAppFlag:
-		return "NewsApp", setup.EnvToInt("NEWSPAPER_PORT"), news.App
+		return "NewsApp", envPort("NEWSPAPER_PORT"), news.App
+	case *newsFeedHealthFlag:
+		return "NewsFeedHealth", 9996, news.FeedHealthApp
+	case len(*newsFeedAddFlag) > 0:
+		feed := news.NewFeed(*feedName, *feedLink, *newsFeedAddFlag, *feedCategory)
+		feed.FeedType = *feedType
+		feed.Language = null.NewString(*feedLanguage, len(*feedLanguage) > 0)
+		feed.Region = null.NewString(*feedRegion, len(*feedRegion) > 0)
+		feed.PollInterval = *feedInterval
+		return "NewsFeedAdd", 9995, func() { news.FeedAddApp(feed) }
+	case len(*newsFeedDisableFlag) > 0:
+		return "NewsFeedDisable", 9989, func() { news.FeedDisableApp(*newsFeedDisableFlag) }
+	case len(*newsFeedImportFlag) > 0:
+		return "NewsFeedImport", 9988, func() { news.FeedImportApp(*newsFeedImportFlag) }
+	case *newsBackfillRedditFlag:
+		from, to := flagDate(*backfillFrom), flagDate(*backfillTo)
+		return "NewsBackfillReddit", 9994, func() { news.RedditBackfillApp(from, to, *backfillBatch) }
+	case *newsBackfillAuthorsFlag:
+		from, to := flagDate(*backfillFrom), flagDate(*backfillTo)
+		return "NewsBackfillAuthors", 9987, func() { news.AuthorBackfillApp(from, to, *backfillBatch) }
+	case *newsBackfillListingsFlag:
+		from, to := flagDate(*backfillFrom), flagDate(*backfillTo)
+		return "NewsBackfillListings", 9986, func() { news.ListingBackfillApp(from, to, *backfillBatch) }
+	case len(*newsAuthorFlag) > 0:
+		return "NewsAuthor", 9985, func() { news.AuthorArticlesApp(*newsAuthorFlag) }
+	case *newsReextractFlag:
+		from, to := flagDate(*backfillFrom), flagDate(*backfillTo)
+		return "NewsReextract", 9984, func() { news.ReextractApp(from, to, *backfillBatch) }
+	case *newsHostQualityFlag > 0:
+		return "NewsHostQuality", 9983, func() { news.HostQualityApp(*newsHostQualityFlag) }
+	case *newsRecrawlFlag:
+		return "NewsRecrawl", 9993, news.RecrawlApp
+	case *newsRevisionsFlag > 0:
+		return "NewsRevisions", 9982, func() { news.RevisionsApp(*newsRevisionsFlag) }
+	case *newsSyndicationsFlag > 0:
+		return "NewsSyndications", 9977, func() { news.SyndicationsApp(*newsSyndicationsFlag) }
+	case len(*searchFlag) > 0:
+		kinds, err := search.ParseKinds(*searchKind)
+		if err != nil {
+			setup.LogCommon(err).Fatal("Failed search kind flag")
+		}
+		q := &search.Query{
+			Text:      *searchFlag,
+			Kinds:     kinds,
+			Host:      *searchHost,
+			Subreddit: *searchSubreddit,
+			From:      optionalFlagDate(*backfillFrom),
+			To:        optionalFlagDate(*backfillTo),
+			Limit:     *searchLimit,
+		}
+		return "Search", 9981, func() { search.App(q) }
+	case len(*exportFlag) > 0:
+		tables, err := export.ParseTables(*exportFlag)
+		if err != nil {
+			setup.LogCommon(err).Fatal("Failed export flag")
+		}
+		format, err := export.ParseFormat(*exportFormat)
+		if err != nil {
+			setup.LogCommon(err).Fatal("Failed export format flag")
+		}
+		opts := &export.Options{
+			Dir:     *exportDir,
+			Format:  format,
+			Columns: splitFlag(*exportColumns),
+			Batch:   *backfillBatch,
+		}
+		return "Export", 9991, func() { export.App(tables, opts) }
+	case *apiFlag:
+		return "API", 9992, api.App
+	case len(*sentimentFlag) > 0:
+		kinds, err := sentiment.ParseKinds(*sentimentFlag)
+		if err != nil {
+			setup.LogCommon(err).Fatal("Failed sentiment flag")
+		}
+		if *sentimentIncremental {
+			return "SentimentIncremental", 9980, func() { sentiment.IncrementalApp(kinds, *backfillBatch) }
+		}
+		from, to := flagDate(*backfillFrom), flagDate(*backfillTo)
+		return "SentimentBackfill", 9979, func() { sentiment.BackfillApp(kinds, from, to, *backfillBatch) }
+	case len(*sentimentSeriesFlag) > 0:
+		by, value, err := sentiment.ParseSeries(*sentimentSeriesFlag)
+		if err != nil {
+			setup.LogCommon(err).Fatal("Failed sentiment series flag")
+		}
+		from, to := optionalFlagDate(*backfillFrom), optionalFlagDate(*backfillTo)
+		return "SentimentSeries", 9978, func() { sentiment.SeriesApp(by, value, from, to) }
 	case *iexAppFlag:
-		return "IEXApp", setup.EnvToInt("IEX_PORT"), stocks.App
+		return "IEXApp", envPort("IEX_PORT"), stocks.App
 	case *iexUpdateFlag:
-		return "IEXUpdate", setup.EnvToInt("IEX_PORT"), stocks.UpdateListingsDriver
+		return "IEXUpdate", envPort("IEX_PORT"), stocks.UpdateListingsDriver
 	case *iexActiveFlag:
-		return "IEXActive", setup.EnvToInt("IEX_PORT"), stocks.UpdateActiveDriver
+		return "IEXActive", envPort("IEX_PORT"), stocks.UpdateActiveDriver
 	case *textClean:
 		return "TextClean", 9998, text.App
 	case *logSummary:
@@ -72,6 +212,49 @@ func selectApp() (string, int, func()) {
 	return "", 0, nil
 }
 
+// envPort returns the port number stored in the given .env variable
+func envPort(name string) int {
+	port, err := setup.EnvToInt(name)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Failed env int conversion")
+	}
+
+	return port
+}
+
+// flagDate returns the time for a YYYY-MM-DD date given on the command line
+func flagDate(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("date", s).
+			Fatal("Failed date flag")
+	}
+
+	return t
+}
+
+// optionalFlagDate is flagDate for dates that may be left out, returning the zero time if empty
+func optionalFlagDate(s string) time.Time {
+	if len(s) == 0 {
+		return time.Time{}
+	}
+
+	return flagDate(s)
+}
+
+// splitFlag returns the values in a comma separated list given on the command line
+func splitFlag(s string) []string {
+	out := []string{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			out = append(out, v)
+		}
+	}
+
+	return out
+}
+
 func test() {
 	return
 }
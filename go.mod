@@ -19,7 +19,9 @@ require (
 	github.com/shopspring/decimal v1.2.0
 	github.com/sirupsen/logrus v1.6.0
 	github.com/turnage/graw v0.0.0-20200719190030-8ef4107c4a29
-	golang.org/x/net v0.0.0-20200707034311-ab3426394381 // indirect
+	github.com/xitongsys/parquet-go v1.5.2
+	github.com/xitongsys/parquet-go-source v0.0.0-20190524061010-2b72cbee77d5
+	golang.org/x/net v0.0.0-20200707034311-ab3426394381
 	golang.org/x/text v0.3.3
 	google.golang.org/grpc v1.30.0
 	google.golang.org/protobuf v1.25.0
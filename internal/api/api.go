@@ -0,0 +1,281 @@
+// Package api serves the collected news, reddit, and stocks data as a read only JSON HTTP API.
+package api
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/guregu/null.v3"
+
+	"github.com/wpwilson10/caterpillar/internal/setup"
+)
+
+// default and largest page sizes
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// defaultAddress is used when API_ADDRESS is not set
+const defaultAddress = "127.0.0.1:8080"
+
+// errNotFound is returned by handlers when the requested item does not exist.
+var errNotFound = errors.New("not found")
+
+// badRequest is returned by handlers for invalid query parameters or paths.
+type badRequest struct {
+	msg string
+}
+
+func (e *badRequest) Error() string {
+	return e.msg
+}
+
+// badRequestf returns a badRequest error with a formatted message.
+func badRequestf(format string, a ...interface{}) error {
+	return &badRequest{msg: fmt.Sprintf(format, a...)}
+}
+
+// Page is a page of results from a list endpoint.
+// Next is passed as the cursor parameter to get the following page, and is empty on the last page.
+type Page struct {
+	Data interface{} `json:"data"`
+	Next string      `json:"next,omitempty"`
+}
+
+// handler returns the value to encode as the response body
+type handler func(r *http.Request) (interface{}, error)
+
+// Server answers API requests from the database.
+type Server struct {
+	db  *sqlx.DB
+	mux *http.ServeMux
+}
+
+// NewServer returns a Server reading from the given database.
+func NewServer(db *sqlx.DB) *Server {
+	s := &Server{db: db, mux: http.NewServeMux()}
+
+	s.mux.HandleFunc("/articles", s.serve(s.articles))
+	s.mux.HandleFunc("/articles/", s.serve(s.article))
+	s.mux.HandleFunc("/reddit/submissions", s.serve(s.submissions))
+	s.mux.HandleFunc("/reddit/submissions/", s.serve(s.submission))
+	s.mux.HandleFunc("/listings", s.serve(s.listings))
+	s.mux.HandleFunc("/listings/", s.serve(s.listing))
+	s.mux.HandleFunc("/intraday", s.serve(s.intraday))
+
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// serve wraps a handler with method checks, error responses, and ETag caching.
+func (s *Server) serve(h handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		out, err := h(r)
+		var bad *badRequest
+		switch {
+		case err == nil:
+		case errors.Is(err, errNotFound):
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		case errors.As(err, &bad):
+			writeError(w, http.StatusBadRequest, bad.Error())
+			return
+		default:
+			setup.LogCommon(err).
+				WithField("url", r.URL.String()).
+				Error("Failed API request")
+			writeError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+
+		body, err := json.Marshal(out)
+		if err != nil {
+			setup.LogCommon(err).
+				WithField("url", r.URL.String()).
+				Error("Failed API response encoding")
+			writeError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+
+		// the same data always encodes the same, so a hash of the body identifies it
+		sum := sha1.Sum(body)
+		etag := `"` + hex.EncodeToString(sum[:]) + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "no-cache")
+		if matchesETag(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method != http.MethodHead {
+			w.Write(body)
+		}
+	}
+}
+
+// matchesETag returns true if an If-None-Match header value includes the etag.
+// Weak validators are compared by their opaque value.
+func matchesETag(header string, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeError writes a JSON error response.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	body, _ := json.Marshal(map[string]string{"error": msg})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// limitParam returns the page size from the limit parameter.
+func limitParam(r *http.Request) (int, error) {
+	s := r.URL.Query().Get("limit")
+	if len(s) == 0 {
+		return defaultLimit, nil
+	}
+
+	limit, err := strconv.Atoi(s)
+	if err != nil || limit <= 0 {
+		return 0, badRequestf("invalid limit %s", s)
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	return limit, nil
+}
+
+// intParam returns the named integer parameter, which is null if not given.
+func intParam(r *http.Request, name string) (null.Int, error) {
+	s := r.URL.Query().Get(name)
+	if len(s) == 0 {
+		return null.Int{}, nil
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return null.Int{}, badRequestf("invalid %s %s", name, s)
+	}
+
+	return null.IntFrom(n), nil
+}
+
+// boolParam returns the named boolean parameter, which is null if not given.
+func boolParam(r *http.Request, name string) (null.Bool, error) {
+	s := r.URL.Query().Get(name)
+	if len(s) == 0 {
+		return null.Bool{}, nil
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return null.Bool{}, badRequestf("invalid %s %s", name, s)
+	}
+
+	return null.BoolFrom(b), nil
+}
+
+// timeParam returns the named time parameter, given as RFC 3339 or YYYY-MM-DD, which is null if not given.
+func timeParam(r *http.Request, name string) (null.Time, error) {
+	s := r.URL.Query().Get(name)
+	if len(s) == 0 {
+		return null.Time{}, nil
+	}
+
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return null.TimeFrom(t), nil
+		}
+	}
+
+	return null.Time{}, badRequestf("invalid %s %s, use RFC 3339 or YYYY-MM-DD", name, s)
+}
+
+// rangeParams returns the from and to time parameters.
+func rangeParams(r *http.Request) (null.Time, null.Time, error) {
+	from, err := timeParam(r, "from")
+	if err != nil {
+		return from, null.Time{}, err
+	}
+	to, err := timeParam(r, "to")
+
+	return from, to, err
+}
+
+// pathID returns the integer ID following prefix in the request path, and the rest of the path after it.
+func pathID(r *http.Request, prefix string) (int64, string, error) {
+	rest := strings.TrimPrefix(r.URL.Path, prefix)
+	parts := strings.SplitN(rest, "/", 2)
+
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, "", badRequestf("invalid id %s", parts[0])
+	}
+	if len(parts) == 1 {
+		return id, "", nil
+	}
+
+	return id, parts[1], nil
+}
+
+// App serves the API on the address in API_ADDRESS until the process is stopped.
+func App() {
+	ctx := context.Background()
+	// connect to database
+	db, err := setup.SQL(ctx)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to SQL database")
+	}
+
+	address := os.Getenv("API_ADDRESS")
+	if len(address) == 0 {
+		address = defaultAddress
+	}
+
+	server := &http.Server{
+		Addr:         address,
+		Handler:      NewServer(db),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 60 * time.Second,
+	}
+
+	setup.LogCommon(nil).
+		WithField("address", address).
+		Info("Serving API")
+
+	err = server.ListenAndServe()
+	setup.LogCommon(err).
+		WithField("address", address).
+		Fatal("Failed API server")
+}
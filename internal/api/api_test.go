@@ -0,0 +1,221 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/wpwilson10/caterpillar/internal/reddit"
+)
+
+// testServer serves fixed handlers at /ok and /fail, plus the real routes without a database.
+func testServer(fail error) *Server {
+	s := NewServer(nil)
+	s.mux.HandleFunc("/ok", s.serve(func(r *http.Request) (interface{}, error) {
+		return map[string]int{"answer": 42}, nil
+	}))
+	s.mux.HandleFunc("/fail", s.serve(func(r *http.Request) (interface{}, error) {
+		return nil, fail
+	}))
+
+	return s
+}
+
+func do(s *Server, method string, target string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestServeETag(t *testing.T) {
+	s := testServer(nil)
+
+	w := do(s, http.MethodGet, "/ok", nil)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || w.Body.String() != `{"answer":42}` || len(etag) == 0 {
+		t.Fatalf("GET = %d %q with ETag %q", w.Code, w.Body.String(), etag)
+	}
+	if w.Header().Get("Content-Type") != "application/json" || w.Header().Get("Content-Length") != "13" {
+		t.Errorf("GET headers = %v", w.Header())
+	}
+
+	// the same data has the same ETag
+	if again := do(s, http.MethodGet, "/ok", nil).Header().Get("ETag"); again != etag {
+		t.Errorf("second ETag = %q, want %q", again, etag)
+	}
+
+	for _, match := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+		w = do(s, http.MethodGet, "/ok", map[string]string{"If-None-Match": match})
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 || w.Header().Get("ETag") != etag {
+			t.Errorf("If-None-Match %s = %d %q", match, w.Code, w.Body.String())
+		}
+	}
+
+	w = do(s, http.MethodGet, "/ok", map[string]string{"If-None-Match": `"other"`})
+	if w.Code != http.StatusOK || w.Body.Len() == 0 {
+		t.Errorf("stale If-None-Match = %d %q", w.Code, w.Body.String())
+	}
+
+	w = do(s, http.MethodHead, "/ok", nil)
+	if w.Code != http.StatusOK || w.Body.Len() != 0 || w.Header().Get("ETag") != etag || w.Header().Get("Content-Length") != "13" {
+		t.Errorf("HEAD = %d %q with headers %v", w.Code, w.Body.String(), w.Header())
+	}
+
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+		w = do(s, method, "/ok", nil)
+		if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, HEAD" {
+			t.Errorf("%s = %d with Allow %q", method, w.Code, w.Header().Get("Allow"))
+		}
+	}
+}
+
+func TestServeErrors(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+		body string
+	}{
+		{errNotFound, http.StatusNotFound, `{"error":"not found"}`},
+		{badRequestf("invalid limit %s", "x"), http.StatusBadRequest, `{"error":"invalid limit x"}`},
+		{errors.New("connection refused"), http.StatusInternalServerError, `{"error":"internal error"}`},
+	}
+
+	for _, tt := range tests {
+		w := do(testServer(tt.err), http.MethodGet, "/fail", nil)
+		if w.Code != tt.code || w.Body.String() != tt.body || len(w.Header().Get("ETag")) > 0 {
+			t.Errorf("%v = %d %q", tt.err, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestBadParams(t *testing.T) {
+	// each is rejected before the database is used
+	targets := []string{
+		"/articles?limit=0",
+		"/articles?limit=ten",
+		"/articles?cursor=abc",
+		"/articles?from=yesterday",
+		"/articles/abc",
+		"/reddit/submissions?cursor=1.5",
+		"/reddit/submissions/t3_abc",
+		"/listings?limit=-1",
+		"/intraday",
+		"/intraday?listing_id=1&cursor=bad",
+		"/intraday?listing_id=1&cursor=1583298367000000000_",
+		"/intraday?listing_id=x",
+	}
+
+	s := NewServer(nil)
+	for _, target := range targets {
+		if w := do(s, http.MethodGet, target, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("GET %s = %d %q, want 400", target, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestMatchesETag(t *testing.T) {
+	etag := `"abc"`
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{`"abc"`, true},
+		{`W/"abc"`, true},
+		{`"xyz", "abc"`, true},
+		{`"xyz"`, false},
+		{`abc`, false},
+		{`*`, true},
+	}
+
+	for _, tt := range tests {
+		if got := matchesETag(tt.header, etag); got != tt.want {
+			t.Errorf("matchesETag(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestPathID(t *testing.T) {
+	tests := []struct {
+		path    string
+		id      int64
+		rest    string
+		wantErr bool
+	}{
+		{"/articles/42", 42, "", false},
+		{"/articles/42/", 42, "", false},
+		{"/articles/42/revisions", 42, "revisions", false},
+		{"/articles/", 0, "", true},
+		{"/articles/x42", 0, "", true},
+	}
+
+	for _, tt := range tests {
+		id, rest, err := pathID(httptest.NewRequest(http.MethodGet, tt.path, nil), "/articles/")
+		if id != tt.id || rest != tt.rest || (err != nil) != tt.wantErr {
+			t.Errorf("pathID(%q) = %d, %q, %v", tt.path, id, rest, err)
+		}
+	}
+}
+
+func TestIntradayCursor(t *testing.T) {
+	when := time.Date(2020, 3, 4, 14, 30, 0, 0, time.UTC)
+
+	at, id, err := intradayCursor("")
+	if at.Valid || id != 0 || err != nil {
+		t.Errorf("empty cursor = %v, %d, %v", at, id, err)
+	}
+
+	at, id, err = intradayCursor(strconv.FormatInt(when.UnixNano(), 10) + "_42")
+	if !at.Time.Equal(when) || id != 42 || err != nil {
+		t.Errorf("cursor = %v, %d, %v", at, id, err)
+	}
+
+	for _, s := range []string{"42", "_42", "1583332200000000000_", "a_b", "1_2_3"} {
+		if _, _, err := intradayCursor(s); err == nil {
+			t.Errorf("intradayCursor(%q) has no error", s)
+		}
+	}
+}
+
+func TestThreads(t *testing.T) {
+	comment := func(id string, parent string) *reddit.Comment {
+		return &reddit.Comment{RedditID: id, ParentID: parent}
+	}
+	a := comment("a", "t3_post")
+	b := comment("b", "t1_a")
+	c := comment("c", "t1_b")
+	d := comment("d", "t1_gone") // parent was not collected
+	e := comment("e", "t1_a")
+	again := comment("a", "t3_post") // collected twice
+
+	// ids of each thread's comments and replies, depth first
+	var ids func(threads []*Thread) []string
+	ids = func(threads []*Thread) []string {
+		out := []string{}
+		for _, th := range threads {
+			out = append(out, th.RedditID+"(")
+			out = append(out, ids(th.Replies)...)
+			out = append(out, ")")
+		}
+		return out
+	}
+
+	got := ids(threads([]*reddit.Comment{a, b, c, d, e, again}))
+	want := []string{"a(", "b(", "c(", ")", ")", "e(", ")", ")", "d(", ")", "a(", ")"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("threads = %v, want %v", got, want)
+	}
+
+	if out := threads(nil); out == nil || len(out) != 0 {
+		t.Errorf("threads(nil) = %#v, want an empty list", out)
+	}
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/wpwilson10/caterpillar/internal/news"
+)
+
+// articles lists articles newest first.
+// Filters: host, source, language, status (extraction status), from and to (published time).
+func (s *Server) articles(r *http.Request) (interface{}, error) {
+	var selectStmt string = `SELECT ` + news.ArticleColumns("") + ` FROM NewsArticle
+							 WHERE ($1 = '' OR host = $1)
+								AND ($2 = '' OR source = $2)
+								AND ($3 = '' OR language = $3)
+								AND ($4 = '' OR extraction_status = $4)
+								AND ($5::timestamptz IS NULL OR COALESCE(published_time, source_published_time, data_entry_time) >= $5)
+								AND ($6::timestamptz IS NULL OR COALESCE(published_time, source_published_time, data_entry_time) < $6)
+								AND ($7::bigint IS NULL OR article_id < $7)
+							 ORDER BY article_id DESC
+							 LIMIT $8`
+
+	q := r.URL.Query()
+	limit, err := limitParam(r)
+	if err != nil {
+		return nil, err
+	}
+	from, to, err := rangeParams(r)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := intParam(r, "cursor")
+	if err != nil {
+		return nil, err
+	}
+
+	// one extra row tells if there is another page
+	out := []*news.Article{}
+	err = s.db.SelectContext(r.Context(), &out, selectStmt,
+		q.Get("host"), q.Get("source"), q.Get("language"), q.Get("status"), from, to, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	next := ""
+	if len(out) > limit {
+		out = out[:limit]
+		next = strconv.FormatInt(out[limit-1].ArticleID, 10)
+	}
+
+	return Page{Data: out, Next: next}, nil
+}
+
+// article returns the article at /articles/{id}.
+func (s *Server) article(r *http.Request) (interface{}, error) {
+	id, rest, err := pathID(r, "/articles/")
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errNotFound
+	}
+
+	out := news.Article{}
+	err = s.db.GetContext(r.Context(), &out,
+		`SELECT `+news.ArticleColumns("")+` FROM NewsArticle WHERE article_id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, errNotFound
+	}
+
+	return &out, err
+}
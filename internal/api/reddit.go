@@ -0,0 +1,120 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/wpwilson10/caterpillar/internal/reddit"
+)
+
+// Thread is a comment with its replies.
+type Thread struct {
+	*reddit.Comment
+	Replies []*Thread `json:"replies"`
+}
+
+// SubmissionThreads is a submission with its comment threads.
+type SubmissionThreads struct {
+	Submission *reddit.Submission `json:"submission"`
+	Comments   []*Thread          `json:"comments"`
+}
+
+// submissions lists submissions newest first.
+// Filters: subreddit, user, from and to (created time).
+func (s *Server) submissions(r *http.Request) (interface{}, error) {
+	var selectStmt string = `SELECT ` + reddit.SubmissionColumns("") + ` FROM RedditSubmission
+							 WHERE ($1 = '' OR lower(subreddit_name) = lower($1))
+								AND ($2 = '' OR user_name = $2)
+								AND ($3::timestamptz IS NULL OR created_time >= $3)
+								AND ($4::timestamptz IS NULL OR created_time < $4)
+								AND ($5::bigint IS NULL OR submission_id < $5)
+							 ORDER BY submission_id DESC
+							 LIMIT $6`
+
+	q := r.URL.Query()
+	limit, err := limitParam(r)
+	if err != nil {
+		return nil, err
+	}
+	from, to, err := rangeParams(r)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := intParam(r, "cursor")
+	if err != nil {
+		return nil, err
+	}
+
+	// one extra row tells if there is another page
+	out := []*reddit.Submission{}
+	err = s.db.SelectContext(r.Context(), &out, selectStmt,
+		q.Get("subreddit"), q.Get("user"), from, to, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	next := ""
+	if len(out) > limit {
+		out = out[:limit]
+		next = strconv.FormatInt(out[limit-1].SubmissionID, 10)
+	}
+
+	return Page{Data: out, Next: next}, nil
+}
+
+// submission returns the submission at /reddit/submissions/{id} with its comment threads.
+func (s *Server) submission(r *http.Request) (interface{}, error) {
+	id, rest, err := pathID(r, "/reddit/submissions/")
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errNotFound
+	}
+
+	submission := reddit.Submission{}
+	err = s.db.GetContext(r.Context(), &submission,
+		`SELECT `+reddit.SubmissionColumns("")+` FROM RedditSubmission WHERE submission_id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, errNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	comments := []*reddit.Comment{}
+	err = s.db.SelectContext(r.Context(), &comments,
+		`SELECT `+reddit.CommentColumns("")+` FROM RedditComment
+		 WHERE submission_id = $1
+		 ORDER BY created_time, comment_id`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SubmissionThreads{Submission: &submission, Comments: threads(comments)}, nil
+}
+
+// threads arranges comments into reply trees, keeping the order of the given comments.
+// Comments whose parent was not collected are treated as top level.
+func threads(comments []*reddit.Comment) []*Thread {
+	nodes := make([]*Thread, len(comments))
+	byName := make(map[string]*Thread, len(comments))
+	for i, c := range comments {
+		nodes[i] = &Thread{Comment: c, Replies: []*Thread{}}
+		// a comment collected twice keeps its first copy as the parent of replies
+		if _, ok := byName[c.FullName()]; !ok {
+			byName[c.FullName()] = nodes[i]
+		}
+	}
+
+	out := []*Thread{}
+	for _, node := range nodes {
+		if parent, ok := byName[node.ParentID]; ok && parent != node {
+			parent.Replies = append(parent.Replies, node)
+		} else {
+			out = append(out, node)
+		}
+	}
+
+	return out
+}
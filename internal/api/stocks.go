@@ -0,0 +1,207 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/guregu/null.v3"
+
+	"github.com/wpwilson10/caterpillar/internal/stocks"
+)
+
+// listingColumns and intradayColumns select the columns stored in stocks.Listing and stocks.Intraday,
+// with NULL values read as the column's default or zero value so one incomplete row does not fail a page.
+const (
+	listingColumns = `listing_id, COALESCE(update_time, 'epoch') AS update_time, COALESCE(is_enabled, false) AS is_enabled,
+		COALESCE(symbol, '') AS symbol, COALESCE(name, '') AS name, COALESCE(iex_id, '') AS iex_id,
+		COALESCE(type, '') AS type, COALESCE(region, '') AS region, COALESCE(currency, '') AS currency,
+		COALESCE(exchange, '') AS exchange, COALESCE(is_sp500, false) AS is_sp500,
+		COALESCE(is_russell3000, false) AS is_russell3000, COALESCE(is_active, true) AS is_active`
+	intradayColumns = `intraday_id, listing_id, COALESCE(source_id, 0) AS source_id, data_time,
+		COALESCE(update_time, data_time) AS update_time, COALESCE(open, 0) AS open, COALESCE(close, 0) AS close,
+		COALESCE(high, 0) AS high, COALESCE(low, 0) AS low, COALESCE(volume, 0) AS volume,
+		COALESCE(notional, 0) AS notional, COALESCE(num_trades, 0) AS num_trades`
+)
+
+// AuditListing is a past version of a listing from the AuditListing table.
+type AuditListing struct {
+	AuditID int64 `db:"audit_id" json:"audit_id"`
+	stocks.Listing
+}
+
+// ListingHistory is a listing with its past versions, newest first.
+type ListingHistory struct {
+	Listing *stocks.Listing `json:"listing"`
+	History []*AuditListing `json:"history"`
+}
+
+// listings lists listings by ID.
+// Filters: symbol, exchange, type, active, sp500, russell3000.
+func (s *Server) listings(r *http.Request) (interface{}, error) {
+	var selectStmt string = `SELECT ` + listingColumns + ` FROM Listing
+							 WHERE ($1 = '' OR symbol = upper($1))
+								AND ($2 = '' OR exchange = $2)
+								AND ($3 = '' OR type = $3)
+								AND ($4::boolean IS NULL OR is_active = $4)
+								AND ($5::boolean IS NULL OR is_sp500 = $5)
+								AND ($6::boolean IS NULL OR is_russell3000 = $6)
+								AND ($7::bigint IS NULL OR listing_id > $7)
+							 ORDER BY listing_id
+							 LIMIT $8`
+
+	q := r.URL.Query()
+	limit, err := limitParam(r)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := intParam(r, "cursor")
+	if err != nil {
+		return nil, err
+	}
+	flags := [3]interface{}{}
+	for i, name := range []string{"active", "sp500", "russell3000"} {
+		if flags[i], err = boolParam(r, name); err != nil {
+			return nil, err
+		}
+	}
+
+	// one extra row tells if there is another page
+	out := []*stocks.Listing{}
+	err = s.db.SelectContext(r.Context(), &out, selectStmt,
+		q.Get("symbol"), q.Get("exchange"), q.Get("type"), flags[0], flags[1], flags[2], cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	next := ""
+	if len(out) > limit {
+		out = out[:limit]
+		next = strconv.FormatInt(out[limit-1].ListingID, 10)
+	}
+
+	return Page{Data: out, Next: next}, nil
+}
+
+// listing returns the listing at /listings/{id} with its audit history.
+func (s *Server) listing(r *http.Request) (interface{}, error) {
+	id, rest, err := pathID(r, "/listings/")
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errNotFound
+	}
+
+	listing := stocks.Listing{}
+	err = s.db.GetContext(r.Context(), &listing, `SELECT `+listingColumns+` FROM Listing WHERE listing_id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, errNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	history := []*AuditListing{}
+	err = s.db.SelectContext(r.Context(), &history,
+		`SELECT audit_id, `+listingColumns+`
+		 FROM AuditListing
+		 WHERE listing_id = $1
+		 ORDER BY update_time DESC, audit_id DESC`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListingHistory{Listing: &listing, History: history}, nil
+}
+
+// intraday lists a listing's bars oldest first.
+// The listing is given by symbol or listing_id. Filters: from and to (data time).
+func (s *Server) intraday(r *http.Request) (interface{}, error) {
+	var selectStmt string = `SELECT ` + intradayColumns + ` FROM Intraday
+							 WHERE listing_id = $1 AND data_time IS NOT NULL
+								AND ($2::timestamptz IS NULL OR data_time >= $2)
+								AND ($3::timestamptz IS NULL OR data_time < $3)
+								AND ($4::timestamptz IS NULL OR (data_time, intraday_id) > ($4, $5))
+							 ORDER BY data_time, intraday_id
+							 LIMIT $6`
+
+	limit, err := limitParam(r)
+	if err != nil {
+		return nil, err
+	}
+	from, to, err := rangeParams(r)
+	if err != nil {
+		return nil, err
+	}
+	listingID, err := s.listingParam(r)
+	if err != nil {
+		return nil, err
+	}
+	afterTime, afterID, err := intradayCursor(r.URL.Query().Get("cursor"))
+	if err != nil {
+		return nil, err
+	}
+
+	// one extra row tells if there is another page
+	out := []*stocks.Intraday{}
+	err = s.db.SelectContext(r.Context(), &out, selectStmt, listingID, from, to, afterTime, afterID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	next := ""
+	if len(out) > limit {
+		out = out[:limit]
+		last := out[limit-1]
+		next = fmt.Sprintf("%d_%d", last.DataTime.UnixNano(), last.IntradayID)
+	}
+
+	return Page{Data: out, Next: next}, nil
+}
+
+// listingParam returns the listing ID from the listing_id parameter, or looks up the symbol parameter
+// preferring active and then newer listings.
+func (s *Server) listingParam(r *http.Request) (int64, error) {
+	id, err := intParam(r, "listing_id")
+	if err != nil || id.Valid {
+		return id.Int64, err
+	}
+
+	symbol := r.URL.Query().Get("symbol")
+	if len(symbol) == 0 {
+		return 0, badRequestf("symbol or listing_id is required")
+	}
+
+	var out int64
+	err = s.db.GetContext(r.Context(), &out,
+		`SELECT listing_id FROM Listing WHERE symbol = upper($1)
+		 ORDER BY is_active DESC, listing_id DESC
+		 LIMIT 1`, symbol)
+	if err == sql.ErrNoRows {
+		return 0, errNotFound
+	}
+
+	return out, err
+}
+
+// intradayCursor parses a cursor of the last bar's data time in unix nanoseconds and ID.
+// An empty cursor starts from the first bar.
+func intradayCursor(s string) (null.Time, int64, error) {
+	if len(s) == 0 {
+		return null.Time{}, 0, nil
+	}
+
+	parts := strings.SplitN(s, "_", 2)
+	if len(parts) == 2 {
+		nanos, err1 := strconv.ParseInt(parts[0], 10, 64)
+		id, err2 := strconv.ParseInt(parts[1], 10, 64)
+		if err1 == nil && err2 == nil {
+			return null.TimeFrom(time.Unix(0, nanos).UTC()), id, nil
+		}
+	}
+
+	return null.Time{}, 0, badRequestf("invalid cursor %s", s)
+}
@@ -0,0 +1,347 @@
+// Package export writes collected data to JSONL, CSV, or Parquet files partitioned by date.
+// Each run continues from a watermark saved in the database, so only new rows are exported.
+package export
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/guregu/null.v3"
+
+	"github.com/wpwilson10/caterpillar/internal/setup"
+)
+
+// Column types
+const (
+	TypeInt64   = "int64"
+	TypeInt32   = "int32"
+	TypeBool    = "bool"
+	TypeText    = "text"
+	TypeNumeric = "numeric" // exported as a string to keep its precision
+	TypeTime    = "time"
+)
+
+// lag is how old a row's entry time must be before the watermark passes it,
+// so rows from transactions still in progress are not skipped
+const lag = 5 * time.Minute
+
+// Column is an exported table column.
+type Column struct {
+	Name string
+	Type string
+}
+
+// Table describes how a database table is exported.
+type Table struct {
+	Name      string   // database table name
+	Key       string   // increasing ID column the watermark follows
+	Entry     string   // time the row was stored, used for the lag
+	Partition string   // time column whose UTC date names the output directory
+	Columns   []Column // exportable columns in table order, generated search columns are left out
+}
+
+// Tables that can be exported, by lower case name
+var Tables = map[string]*Table{
+	"newsarticle": {
+		Name: "NewsArticle", Key: "article_id", Entry: "data_entry_time", Partition: "data_entry_time",
+		Columns: []Column{
+			{"article_id", TypeInt64}, {"data_entry_time", TypeTime}, {"source", TypeText}, {"host", TypeText},
+			{"link", TypeText}, {"source_published_time", TypeTime}, {"published_time", TypeTime},
+			{"source_title", TypeText}, {"title", TypeText}, {"canonical_link", TypeText}, {"body", TypeText},
+			{"authors", TypeText}, {"feed_id", TypeInt64}, {"language", TypeText},
+			{"published_time_source", TypeText}, {"extraction_status", TypeText}, {"fetch_link", TypeText},
+		},
+	},
+	"redditsubmission": {
+		Name: "RedditSubmission", Key: "submission_id", Entry: "data_entry_time", Partition: "data_entry_time",
+		Columns: []Column{
+			{"submission_id", TypeInt64}, {"reddit_id", TypeText}, {"title", TypeText}, {"url", TypeText},
+			{"permalink", TypeText}, {"data_entry_time", TypeTime}, {"created_time", TypeTime},
+			{"user_name", TypeText}, {"subreddit_name", TypeText}, {"subreddit_id", TypeText},
+			{"selftext", TypeText}, {"selftext_html", TypeText}, {"num_comments", TypeInt32},
+			{"score", TypeInt32}, {"up_votes", TypeInt32}, {"down_votes", TypeInt32}, {"is_nsfw", TypeBool},
+			{"is_self", TypeBool},
+		},
+	},
+	"redditcomment": {
+		Name: "RedditComment", Key: "comment_id", Entry: "data_entry_time", Partition: "data_entry_time",
+		Columns: []Column{
+			{"comment_id", TypeInt64}, {"submission_id", TypeInt64}, {"reddit_id", TypeText},
+			{"parent_id", TypeText}, {"data_entry_time", TypeTime}, {"created_time", TypeTime},
+			{"user_name", TypeText}, {"body", TypeText}, {"body_html", TypeText}, {"up_votes", TypeInt32},
+			{"down_votes", TypeInt32}, {"is_deleted", TypeBool},
+		},
+	},
+	"intraday": {
+		Name: "Intraday", Key: "intraday_id", Entry: "update_time", Partition: "data_time",
+		Columns: []Column{
+			{"intraday_id", TypeInt64}, {"listing_id", TypeInt32}, {"source_id", TypeInt32},
+			{"data_time", TypeTime}, {"update_time", TypeTime}, {"open", TypeNumeric}, {"close", TypeNumeric},
+			{"high", TypeNumeric}, {"low", TypeNumeric}, {"volume", TypeNumeric}, {"notional", TypeNumeric},
+			{"num_trades", TypeNumeric},
+		},
+	},
+	// listings are exported when added, later changes to them are kept in AuditListing
+	"listing": {
+		Name: "Listing", Key: "listing_id", Entry: "update_time", Partition: "update_time",
+		Columns: []Column{
+			{"listing_id", TypeInt32}, {"update_time", TypeTime}, {"is_enabled", TypeBool},
+			{"is_active", TypeBool}, {"symbol", TypeText}, {"name", TypeText}, {"iex_id", TypeText},
+			{"type", TypeText}, {"region", TypeText}, {"currency", TypeText}, {"exchange", TypeText},
+			{"is_sp500", TypeBool}, {"is_russell3000", TypeBool},
+		},
+	},
+}
+
+// Options for an export run
+type Options struct {
+	Dir     string   // root output directory, tables are written to subdirectories
+	Format  string   // FormatJSONL, FormatCSV, or FormatParquet
+	Columns []string // columns to export, all columns if empty
+	Batch   int      // rows read per query
+}
+
+// ParseTables returns the tables in a comma separated list of table names, or all tables for "all".
+func ParseTables(s string) ([]*Table, error) {
+	out := []*Table{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "all" {
+			for _, key := range []string{"newsarticle", "redditsubmission", "redditcomment", "intraday", "listing"} {
+				out = append(out, Tables[key])
+			}
+			continue
+		}
+
+		table, ok := Tables[name]
+		if !ok {
+			return nil, fmt.Errorf("export: unknown table %s", name)
+		}
+		out = append(out, table)
+	}
+
+	return out, nil
+}
+
+// ParseFormat checks that s is a supported format.
+func ParseFormat(s string) (string, error) {
+	switch format := strings.ToLower(s); format {
+	case FormatJSONL, FormatCSV, FormatParquet:
+		return format, nil
+	}
+
+	return "", fmt.Errorf("export: unknown format %s", s)
+}
+
+// Select returns the table's columns with the given names in the given order, or all columns if names is empty.
+// Names the table does not have are skipped when exporting several tables, so one list can cover them all.
+func (t *Table) Select(names []string) []Column {
+	if len(names) == 0 {
+		return t.Columns
+	}
+
+	out := []Column{}
+	for _, name := range names {
+		for _, col := range t.Columns {
+			if col.Name == name {
+				out = append(out, col)
+			}
+		}
+	}
+
+	return out
+}
+
+// Export writes the table's rows after its watermark to date partitioned files and advances the watermark.
+// Files are only moved into place, and the watermark saved, once every row has been written.
+func Export(ctx context.Context, db *sqlx.DB, table *Table, opts *Options) (*Watermark, int64, error) {
+	columns := table.Select(opts.Columns)
+	if len(columns) == 0 {
+		return nil, 0, fmt.Errorf("export %s: no matching columns", table.Name)
+	}
+
+	mark, err := LoadWatermark(ctx, db, strings.ToLower(table.Name), opts.Format, opts.Dir)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// newest row old enough that transactions before it have finished
+	var bound null.Int
+	err = db.GetContext(ctx, &bound,
+		fmt.Sprintf(`SELECT max(%[1]s) FROM (SELECT %[1]s FROM %[2]s WHERE %[3]s < $1 ORDER BY %[1]s DESC LIMIT 1) newest`,
+			table.Key, table.Name, table.Entry),
+		time.Now().Add(-lag))
+	if err != nil || !bound.Valid || bound.Int64 <= mark.LastID {
+		return mark, 0, err
+	}
+
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.Name
+	}
+	var selectStmt string = fmt.Sprintf(`SELECT %s, %s, %s FROM %s
+										 WHERE %[1]s > $1 AND %[1]s <= $2
+										 ORDER BY %[1]s
+										 LIMIT $3`,
+		table.Key, table.Partition, strings.Join(names, ", "), table.Name)
+
+	parts := newPartitions(filepath.Join(opts.Dir, strings.ToLower(table.Name)), strings.ToLower(table.Name), opts.Format, columns)
+	lastID := mark.LastID
+	var numRows int64
+	for {
+		n, last, err := exportBatch(ctx, db, selectStmt, lastID, bound.Int64, opts.Batch, columns, parts)
+		if err != nil {
+			parts.Abort()
+			return mark, 0, err
+		}
+		if n == 0 {
+			break
+		}
+		numRows += int64(n)
+		lastID = last
+	}
+
+	if err := parts.Commit(); err != nil {
+		return mark, 0, err
+	}
+
+	mark.LastID = lastID
+	mark.NumRows += numRows
+	mark.NumRuns++
+
+	return mark, numRows, mark.Save(ctx, db)
+}
+
+// exportBatch writes up to limit rows after lastID, returning the number written and the last key.
+func exportBatch(ctx context.Context, db *sqlx.DB, selectStmt string, lastID int64, bound int64, limit int,
+	columns []Column, parts *partitions) (int, int64, error) {
+	rows, err := db.QueryContext(ctx, selectStmt, lastID, bound, limit)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+
+	var key int64
+	var partition null.Time
+	dest := make([]interface{}, len(columns)+2)
+	dest[0], dest[1] = &key, &partition
+	for i, col := range columns {
+		dest[i+2] = scanner(col.Type)
+	}
+
+	n := 0
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return n, lastID, err
+		}
+
+		row := make([]interface{}, len(columns))
+		for i := range columns {
+			row[i] = value(dest[i+2], columns[i].Type)
+		}
+
+		date := "unknown"
+		if partition.Valid {
+			date = partition.Time.UTC().Format("2006-01-02")
+		}
+		if err := parts.Write(date, key, row); err != nil {
+			return n, lastID, err
+		}
+
+		n++
+		lastID = key
+	}
+
+	return n, lastID, rows.Err()
+}
+
+// scanner returns a nullable destination for scanning a column of the type.
+func scanner(columnType string) interface{} {
+	switch columnType {
+	case TypeInt64, TypeInt32:
+		return &null.Int{}
+	case TypeBool:
+		return &null.Bool{}
+	case TypeTime:
+		return &null.Time{}
+	}
+
+	return &null.String{}
+}
+
+// value returns the scanned value of a column of the type for writing, nil if null.
+func value(dest interface{}, columnType string) interface{} {
+	switch v := dest.(type) {
+	case *null.Int:
+		if v.Valid && columnType == TypeInt32 {
+			return int32(v.Int64)
+		} else if v.Valid {
+			return v.Int64
+		}
+	case *null.Bool:
+		if v.Valid {
+			return v.Bool
+		}
+	case *null.Time:
+		if v.Valid {
+			return v.Time
+		}
+	case *null.String:
+		if v.Valid {
+			return v.String
+		}
+	}
+
+	return nil
+}
+
+// App exports each table in the format to opts.Dir, or the EXPORT_DIR .env variable if not given.
+func App(tables []*Table, opts *Options) {
+	ctx := context.Background()
+	// connect to database
+	db, err := setup.SQL(ctx)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to SQL database")
+	}
+
+	if len(opts.Dir) == 0 {
+		opts.Dir = os.Getenv("EXPORT_DIR")
+	}
+	if len(opts.Dir) == 0 {
+		setup.LogCommon(nil).Fatal("No export directory")
+	}
+	// watermarks are kept per directory, so the same directory must always have the same name
+	if opts.Dir, err = filepath.Abs(opts.Dir); err != nil {
+		setup.LogCommon(err).Fatal("Failed export directory")
+	}
+
+	var numRows int64
+	for _, table := range tables {
+		mark, n, err := Export(ctx, db, table, opts)
+		if err != nil {
+			setup.LogCommon(err).
+				WithField("table", table.Name).
+				Error("Failed Export")
+			continue
+		}
+		numRows += n
+
+		setup.LogCommon(nil).
+			WithField("table", table.Name).
+			WithField("NumRows", n).
+			WithField("LastID", mark.LastID).
+			Info("Exported table")
+	}
+
+	setup.LogCommon(nil).
+		WithField("NumTables", len(tables)).
+		WithField("NumRows", numRows).
+		WithField("Format", opts.Format).
+		WithField("RunTime", setup.RunTime().String()).
+		Info("RunSummary")
+}
@@ -0,0 +1,75 @@
+package export
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Watermark stores how far a table has been exported in a format matching the ExportWatermark table schema.
+// Each table, format, and output directory has its own watermark.
+type Watermark struct {
+	Name        string    `db:"name"`
+	TableName   string    `db:"table_name"`
+	Format      string    `db:"format"`
+	Directory   string    `db:"directory"`
+	LastID      int64     `db:"last_id"` // key of the last exported row
+	NumRows     int64     `db:"num_rows"`
+	NumRuns     int64     `db:"num_runs"`
+	UpdatedTime time.Time `db:"updated_time"`
+}
+
+// LoadWatermark returns the saved watermark for exporting the table in the format to dir,
+// or a new watermark if the table has not been exported there.
+func LoadWatermark(ctx context.Context, db *sqlx.DB, table string, format string, dir string) (*Watermark, error) {
+	name := table + ":" + format + ":" + dir
+
+	out := Watermark{}
+	err := db.GetContext(ctx, &out, "SELECT * FROM ExportWatermark WHERE name = $1", name)
+	if err == sql.ErrNoRows {
+		return &Watermark{Name: name, TableName: table, Format: format, Directory: dir}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// Save inserts or updates this watermark in the ExportWatermark table.
+func (w *Watermark) Save(ctx context.Context, db *sqlx.DB) error {
+	w.UpdatedTime = time.Now()
+
+	var insertStmt string = `INSERT INTO ExportWatermark (
+								name,
+								table_name,
+								format,
+								directory,
+								last_id,
+								num_rows,
+								num_runs,
+								updated_time
+								)`
+
+	var valueStmt string = `VALUES (
+								:name,
+								:table_name,
+								:format,
+								:directory,
+								:last_id,
+								:num_rows,
+								:num_runs,
+								:updated_time
+								)`
+
+	var conflictStmt string = `ON CONFLICT (name) DO UPDATE SET
+								last_id = EXCLUDED.last_id,
+								num_rows = EXCLUDED.num_rows,
+								num_runs = EXCLUDED.num_runs,
+								updated_time = EXCLUDED.updated_time`
+
+	_, err := db.NamedExecContext(ctx, insertStmt+" "+valueStmt+" "+conflictStmt, w)
+
+	return err
+}
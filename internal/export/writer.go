@@ -0,0 +1,300 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	writerfile "github.com/xitongsys/parquet-go-source/writer"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// Output formats
+const (
+	FormatJSONL   = "jsonl"
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+)
+
+// rowWriter writes rows of column values in an output format.
+// Values are nil, int64, int32, bool, string, or time.Time.
+type rowWriter interface {
+	Write(row []interface{}) error
+	Close() error // flushes any buffered rows and footers, does not close the underlying writer
+}
+
+// newRowWriter returns a writer for the format.
+func newRowWriter(format string, w io.Writer, columns []Column) (rowWriter, error) {
+	switch format {
+	case FormatJSONL:
+		return newJSONLWriter(w, columns), nil
+	case FormatCSV:
+		return newCSVWriter(w, columns)
+	case FormatParquet:
+		return newParquetWriter(w, columns)
+	}
+
+	return nil, fmt.Errorf("export: unknown format %s", format)
+}
+
+// jsonlWriter writes each row as a JSON object on its own line, keeping column order.
+type jsonlWriter struct {
+	w       io.Writer
+	columns []Column
+	buf     bytes.Buffer
+}
+
+func newJSONLWriter(w io.Writer, columns []Column) *jsonlWriter {
+	return &jsonlWriter{w: w, columns: columns}
+}
+
+func (j *jsonlWriter) Write(row []interface{}) error {
+	j.buf.Reset()
+	j.buf.WriteByte('{')
+	for i, v := range row {
+		if i > 0 {
+			j.buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(j.columns[i].Name)
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		j.buf.Write(name)
+		j.buf.WriteByte(':')
+		j.buf.Write(value)
+	}
+	j.buf.WriteString("}\n")
+
+	_, err := j.w.Write(j.buf.Bytes())
+	return err
+}
+
+func (j *jsonlWriter) Close() error {
+	return nil
+}
+
+// csvWriter writes a header row of column names and then each row, with nulls as empty fields.
+type csvWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func newCSVWriter(w io.Writer, columns []Column) (*csvWriter, error) {
+	c := &csvWriter{w: csv.NewWriter(w), record: make([]string, len(columns))}
+	for i, col := range columns {
+		c.record[i] = col.Name
+	}
+
+	return c, c.w.Write(c.record)
+}
+
+func (c *csvWriter) Write(row []interface{}) error {
+	for i, v := range row {
+		switch v := v.(type) {
+		case nil:
+			c.record[i] = ""
+		case string:
+			c.record[i] = v
+		case int64:
+			c.record[i] = strconv.FormatInt(v, 10)
+		case int32:
+			c.record[i] = strconv.FormatInt(int64(v), 10)
+		case bool:
+			c.record[i] = strconv.FormatBool(v)
+		case time.Time:
+			c.record[i] = v.UTC().Format(time.RFC3339Nano)
+		default:
+			return fmt.Errorf("export: unexpected csv value %T", v)
+		}
+	}
+
+	return c.w.Write(c.record)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// parquetWriter writes rows with every column optional, times as microsecond timestamps,
+// and numerics as strings so no precision is lost.
+type parquetWriter struct {
+	w *writer.CSVWriter
+}
+
+// parquetTypes maps column types to parquet-go schema types
+var parquetTypes = map[string]string{
+	TypeInt64:   "INT64",
+	TypeInt32:   "INT32",
+	TypeBool:    "BOOLEAN",
+	TypeText:    "UTF8",
+	TypeNumeric: "UTF8",
+	TypeTime:    "TIMESTAMP_MICROS",
+}
+
+func newParquetWriter(w io.Writer, columns []Column) (*parquetWriter, error) {
+	md := make([]string, len(columns))
+	for i, col := range columns {
+		md[i] = fmt.Sprintf("name=%s, type=%s", col.Name, parquetTypes[col.Type])
+	}
+
+	pw, err := writer.NewCSVWriter(md, writerfile.NewWriterFile(w), 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return &parquetWriter{w: pw}, nil
+}
+
+func (p *parquetWriter) Write(row []interface{}) error {
+	rec := make([]interface{}, len(row))
+	for i, v := range row {
+		if t, ok := v.(time.Time); ok {
+			rec[i] = t.UnixNano() / int64(time.Microsecond)
+		} else {
+			rec[i] = v
+		}
+	}
+
+	return p.w.Write(rec)
+}
+
+func (p *parquetWriter) Close() error {
+	return p.w.WriteStop()
+}
+
+// maxOpenParts is the most date partitions with a file open at once. Rows are read in key order,
+// which closely follows the partition date, so the least recently written part is finished
+// when another date is opened. A date that comes back later gets a new part named after its key.
+const maxOpenParts = 8
+
+// partFile is an output file being written for a date partition.
+// Rows go to a temporary file that is renamed into place when the export succeeds.
+type partFile struct {
+	path string
+	file *os.File
+	buf  *bufio.Writer
+	rows rowWriter
+	used int64 // rows written by the run when this part was last written
+}
+
+// finish flushes the part's rows and footers and closes its file, leaving it to be renamed on commit.
+func (part *partFile) finish() error {
+	err := part.rows.Close()
+	if err == nil {
+		err = part.buf.Flush()
+	}
+	if cerr := part.file.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// partitions writes a run's rows to files by date partition, keeping at most maxOpenParts open.
+type partitions struct {
+	dir     string // table output directory
+	prefix  string // file name prefix
+	format  string
+	columns []Column
+	open    map[string]*partFile // parts being written by date
+	done    []*partFile          // finished parts waiting to be committed
+	numRows int64
+}
+
+func newPartitions(dir string, prefix string, format string, columns []Column) *partitions {
+	return &partitions{dir: dir, prefix: prefix, format: format, columns: columns, open: map[string]*partFile{}}
+}
+
+// Write adds the row to the open file for its date, creating a file named after the row's key if needed.
+func (p *partitions) Write(date string, key int64, row []interface{}) error {
+	part, ok := p.open[date]
+	if !ok {
+		if len(p.open) >= maxOpenParts {
+			if err := p.finishOldest(); err != nil {
+				return err
+			}
+		}
+
+		dir := filepath.Join(p.dir, "date="+date)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+
+		part = &partFile{path: filepath.Join(dir, fmt.Sprintf("%s-%d.%s", p.prefix, key, p.format))}
+		file, err := os.Create(part.path + ".tmp")
+		if err != nil {
+			return err
+		}
+		part.file = file
+		part.buf = bufio.NewWriter(file)
+		p.open[date] = part
+
+		if part.rows, err = newRowWriter(p.format, part.buf, p.columns); err != nil {
+			return err
+		}
+	}
+
+	p.numRows++
+	part.used = p.numRows
+
+	return part.rows.Write(row)
+}
+
+// finishOldest finishes the least recently written open part.
+func (p *partitions) finishOldest() error {
+	var oldest string
+	for date, part := range p.open {
+		if len(oldest) == 0 || part.used < p.open[oldest].used {
+			oldest = date
+		}
+	}
+
+	part := p.open[oldest]
+	delete(p.open, oldest)
+	p.done = append(p.done, part)
+
+	return part.finish()
+}
+
+// Commit finishes each open file and moves every file into place.
+func (p *partitions) Commit() error {
+	for len(p.open) > 0 {
+		if err := p.finishOldest(); err != nil {
+			p.Abort()
+			return err
+		}
+	}
+
+	for i, part := range p.done {
+		if err := os.Rename(part.path+".tmp", part.path); err != nil {
+			p.done = p.done[i:]
+			p.Abort()
+			return err
+		}
+	}
+	p.done = nil
+
+	return nil
+}
+
+// Abort removes the files that have not been committed.
+func (p *partitions) Abort() {
+	for date, part := range p.open {
+		part.file.Close()
+		os.Remove(part.path + ".tmp")
+		delete(p.open, date)
+	}
+	for _, part := range p.done {
+		os.Remove(part.path + ".tmp")
+	}
+	p.done = nil
+}
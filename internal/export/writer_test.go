@@ -0,0 +1,214 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// testTime has microsecond precision so it survives parquet timestamps
+var testTime = time.Date(2020, 3, 4, 5, 6, 7, 123456000, time.UTC)
+
+// testRow returns a row of every column of the table scanned the way Export scans them,
+// with the last column null.
+func testRow(t *testing.T, table *Table) []interface{} {
+	row := make([]interface{}, len(table.Columns))
+	for i, col := range table.Columns {
+		var raw interface{}
+		switch col.Type {
+		case TypeInt64, TypeInt32:
+			raw = int64(1000 + i)
+		case TypeBool:
+			raw = i%2 == 0
+		case TypeTime:
+			raw = testTime
+		case TypeNumeric:
+			raw = fmt.Sprintf("%d.0500", i)
+		default:
+			raw = fmt.Sprintf("%s, \"quoted\"\nline", col.Name)
+		}
+		if i == len(table.Columns)-1 {
+			raw = nil
+		}
+
+		dest := scanner(col.Type)
+		if err := dest.(interface{ Scan(interface{}) error }).Scan(raw); err != nil {
+			t.Fatal(err)
+		}
+		row[i] = value(dest, col.Type)
+	}
+
+	return row
+}
+
+// text returns the value as written by the csv writer.
+func text(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+
+	return fmt.Sprint(v)
+}
+
+func writeRow(t *testing.T, format string, columns []Column, row []interface{}) []byte {
+	var buf bytes.Buffer
+	w, err := newRowWriter(format, &buf, columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Write(row); err != nil {
+		t.Fatalf("%s write: %v", format, err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("%s close: %v", format, err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestWritersRoundTrip(t *testing.T) {
+	for name, table := range Tables {
+		row := testRow(t, table)
+
+		// jsonl
+		got := map[string]interface{}{}
+		dec := json.NewDecoder(bytes.NewReader(writeRow(t, FormatJSONL, table.Columns, row)))
+		dec.UseNumber()
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("%s jsonl: %v", name, err)
+		}
+		for i, col := range table.Columns {
+			want := row[i]
+			if tm, ok := want.(time.Time); ok {
+				want = text(tm)
+			}
+			if g := got[col.Name]; fmt.Sprint(g) != fmt.Sprint(want) || (want == nil) != (g == nil) {
+				t.Errorf("%s jsonl %s = %v, want %v", name, col.Name, g, want)
+			}
+		}
+
+		// csv
+		records, err := csv.NewReader(bytes.NewReader(writeRow(t, FormatCSV, table.Columns, row))).ReadAll()
+		if err != nil {
+			t.Fatalf("%s csv: %v", name, err)
+		}
+		if len(records) != 2 {
+			t.Fatalf("%s csv has %d records, want a header and a row", name, len(records))
+		}
+		for i, col := range table.Columns {
+			if records[0][i] != col.Name || records[1][i] != text(row[i]) {
+				t.Errorf("%s csv %s = %q, want %q", name, records[0][i], records[1][i], text(row[i]))
+			}
+		}
+
+		// parquet
+		file, _ := buffer.NewBufferFile(writeRow(t, FormatParquet, table.Columns, row))
+		pr, err := reader.NewParquetColumnReader(file, 1)
+		if err != nil {
+			t.Fatalf("%s parquet: %v", name, err)
+		}
+		if pr.GetNumRows() != 1 {
+			t.Fatalf("%s parquet has %d rows, want 1", name, pr.GetNumRows())
+		}
+		for i, col := range table.Columns {
+			values, _, _, err := pr.ReadColumnByIndex(int64(i), 1)
+			if err != nil || len(values) != 1 {
+				t.Fatalf("%s parquet %s: %v %v", name, col.Name, values, err)
+			}
+			want := row[i]
+			if tm, ok := want.(time.Time); ok {
+				want = tm.UnixNano() / int64(time.Microsecond)
+			}
+			if values[0] != want {
+				t.Errorf("%s parquet %s = %#v, want %#v", name, col.Name, values[0], want)
+			}
+		}
+		pr.ReadStop()
+	}
+}
+
+func TestPartitions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	columns := []Column{{"id", TypeInt64}}
+	p := newPartitions(dir, "test", FormatJSONL, columns)
+
+	// a run over many days in key order, with a late row for an early day
+	want := map[string]int{}
+	var key int64
+	for day := 1; day <= 3*maxOpenParts; day++ {
+		for i := 0; i < 3; i++ {
+			key++
+			date := fmt.Sprintf("2020-01-%02d", day)
+			if day == 2*maxOpenParts && i == 0 {
+				date = "2020-01-01"
+			}
+			if err := p.Write(date, key, []interface{}{key}); err != nil {
+				t.Fatal(err)
+			}
+			want[date]++
+			if len(p.open) > maxOpenParts {
+				t.Fatalf("%d parts open, want at most %d", len(p.open), maxOpenParts)
+			}
+		}
+	}
+	if err := p.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]int{}
+	var numFiles int
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		if filepath.Ext(path) != ".jsonl" {
+			t.Errorf("left %s", path)
+		}
+		b, err := ioutil.ReadFile(path)
+		numFiles++
+		got[filepath.Base(filepath.Dir(path))] += bytes.Count(b, []byte("\n"))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for date, n := range want {
+		if got["date="+date] != n {
+			t.Errorf("date %s has %d rows, want %d", date, got["date="+date], n)
+		}
+	}
+	// the late row starts a second part for its day
+	if numFiles != len(want)+1 {
+		t.Errorf("wrote %d files, want %d", numFiles, len(want)+1)
+	}
+
+	// aborted runs leave nothing behind, including finished parts
+	p = newPartitions(filepath.Join(dir, "aborted"), "test", FormatJSONL, columns)
+	for day := 1; day <= 2*maxOpenParts; day++ {
+		if err := p.Write(fmt.Sprintf("2020-02-%02d", day), int64(day), []interface{}{int64(day)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.Abort()
+	files, _ := filepath.Glob(filepath.Join(dir, "aborted", "*", "*"))
+	if len(files) != 0 {
+		t.Errorf("abort left %v", files)
+	}
+}
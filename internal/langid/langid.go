@@ -0,0 +1,186 @@
+// Package langid identifies the language of a text using character n-gram profiles.
+package langid
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// number of ranked n-grams kept in each profile
+const profileSize = 300
+
+// largest n-gram length used in profiles
+const maxGram = 3
+
+// number of runes of the input that are used, enough for a reliable guess
+const maxRunes = 4000
+
+// minimum number of letters needed to make a guess
+const minLetters = 20
+
+// Unknown is returned when the language can not be identified.
+const Unknown = ""
+
+// profile maps each of the most common n-grams in a language to its rank.
+type profile map[string]int
+
+// marks Cyrillic characters, which are written in several languages that are told apart by profile
+const cyrillic = "cyrillic"
+
+// trained profiles for languages written in the Latin script, keyed by ISO 639-1 code
+var profiles = trainProfiles(samples)
+
+// trained profiles for languages written in the Cyrillic script, keyed by ISO 639-1 code
+var cyrillicProfiles = trainProfiles(cyrillicSamples)
+
+// trainProfiles returns the profile of each sample text.
+func trainProfiles(samples map[string]string) map[string]profile {
+	out := make(map[string]profile, len(samples))
+	for code, sample := range samples {
+		out[code] = newProfile(sample)
+	}
+	return out
+}
+
+// Detect returns the ISO 639-1 code of the text's language, or Unknown if it can not be identified.
+// Scripts used by a single supported language are identified by their characters,
+// while Latin and Cyrillic script languages are compared by their n-gram profiles.
+func Detect(text string) string {
+	runes := []rune(text)
+	if len(runes) > maxRunes {
+		runes = runes[:maxRunes]
+	}
+
+	// count letters by script
+	var letters, latin int
+	scripts := make(map[string]int)
+	for _, r := range runes {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters = letters + 1
+		if unicode.Is(unicode.Latin, r) {
+			latin = latin + 1
+		} else if s := script(r); len(s) > 0 {
+			scripts[s] = scripts[s] + 1
+		}
+	}
+	if letters < minLetters {
+		return Unknown
+	}
+
+	// a non Latin script that makes up most of the text decides the language
+	best, bestCount := Unknown, 0
+	for s, n := range scripts {
+		if n > bestCount {
+			best, bestCount = s, n
+		}
+	}
+	if bestCount*2 > letters {
+		// Japanese mixes Han characters with kana
+		if best == "zh" && scripts["ja"] > 0 {
+			return "ja"
+		} else if best == cyrillic {
+			return closest(newProfile(string(runes)), cyrillicProfiles)
+		}
+		return best
+	}
+	if latin*2 <= letters {
+		return Unknown
+	}
+
+	return closest(newProfile(string(runes)), profiles)
+}
+
+// script returns the language code for characters of scripts that identify a single language,
+// or cyrillic for Cyrillic characters.
+func script(r rune) string {
+	switch {
+	case unicode.Is(unicode.Cyrillic, r):
+		return cyrillic
+	case unicode.Is(unicode.Greek, r):
+		return "el"
+	case unicode.Is(unicode.Arabic, r):
+		return "ar"
+	case unicode.Is(unicode.Hebrew, r):
+		return "he"
+	case unicode.Is(unicode.Devanagari, r):
+		return "hi"
+	case unicode.Is(unicode.Hangul, r):
+		return "ko"
+	case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+		return "ja"
+	case unicode.Is(unicode.Han, r):
+		return "zh"
+	case unicode.Is(unicode.Thai, r):
+		return "th"
+	}
+
+	return ""
+}
+
+// closest returns the language of the profile with the smallest out of place distance to p.
+func closest(p profile, profiles map[string]profile) string {
+	best, bestDistance := Unknown, -1
+	for code, lp := range profiles {
+		var d int
+		for gram, rank := range p {
+			if lr, ok := lp[gram]; ok {
+				if lr > rank {
+					d = d + lr - rank
+				} else {
+					d = d + rank - lr
+				}
+			} else {
+				d = d + profileSize
+			}
+		}
+		if bestDistance < 0 || d < bestDistance || (d == bestDistance && code < best) {
+			best, bestDistance = code, d
+		}
+	}
+
+	return best
+}
+
+// newProfile ranks the most common 1 to 3 letter n-grams in the text.
+// Words are lower cased and padded with spaces so n-grams capture word starts and ends.
+func newProfile(text string) profile {
+	counts := make(map[string]int)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, w := range words {
+		padded := []rune(" " + w + " ")
+		for n := 1; n <= maxGram; n++ {
+			for i := 0; i+n <= len(padded); i++ {
+				gram := string(padded[i : i+n])
+				if gram != " " {
+					counts[gram] = counts[gram] + 1
+				}
+			}
+		}
+	}
+
+	grams := make([]string, 0, len(counts))
+	for g := range counts {
+		grams = append(grams, g)
+	}
+	sort.Slice(grams, func(i, j int) bool {
+		if counts[grams[i]] != counts[grams[j]] {
+			return counts[grams[i]] > counts[grams[j]]
+		}
+		return grams[i] < grams[j]
+	})
+	if len(grams) > profileSize {
+		grams = grams[:profileSize]
+	}
+
+	out := make(profile, len(grams))
+	for i, g := range grams {
+		out[g] = i
+	}
+
+	return out
+}
@@ -0,0 +1,31 @@
+package langid
+
+import (
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		want string
+		text string
+	}{
+		{"en", "The city council voted on Monday to approve a new budget that will pay for more buses and repairs to the old library."},
+		{"es", "El ayuntamiento votó el lunes para aprobar un nuevo presupuesto que pagará más autobuses y las reparaciones de la biblioteca."},
+		{"fr", "Le conseil municipal a voté lundi pour approuver un nouveau budget qui financera plus de bus et la rénovation de la bibliothèque."},
+		{"de", "Der Stadtrat hat am Montag einen neuen Haushalt beschlossen, der mehr Busse und die Reparatur der alten Bibliothek bezahlen soll."},
+		{"ru", "Городской совет в понедельник проголосовал за новый бюджет, который оплатит больше автобусов и ремонт старой библиотеки."},
+		{"uk", "Міська рада в понеділок проголосувала за новий бюджет, який оплатить більше автобусів і ремонт старої бібліотеки."},
+		{"bg", "Общинският съвет гласува в понеделник нов бюджет, който ще плати за повече автобуси и ремонта на старата библиотека."},
+		{"sr", "Градско веће је у понедељак изгласало нови буџет који ће платити више аутобуса и поправку старе библиотеке."},
+		{"el", "Το δημοτικό συμβούλιο ψήφισε τη Δευτέρα έναν νέο προϋπολογισμό για περισσότερα λεωφορεία."},
+		{"ja", "市議会は月曜日、より多くのバスと古い図書館の修理に充てる新しい予算を承認しました。"},
+		{Unknown, "Too short."},
+		{Unknown, "1234567890 1234567890 1234567890 !!! ??? ..."},
+	}
+
+	for _, tt := range tests {
+		if got := Detect(tt.text); got != tt.want {
+			t.Errorf("Detect(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
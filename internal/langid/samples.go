@@ -0,0 +1,134 @@
+package langid
+
+// samples are news style texts used to train the Latin script profiles, keyed by ISO 639-1 code
+var samples = map[string]string{
+	"en": `The government announced on Tuesday that it would increase spending on roads and schools
+		after a year of slow growth. Officials said the plan was designed to support workers and small businesses
+		while keeping the budget under control. Critics argued that the proposal did not go far enough and that
+		prices for food and energy were still rising faster than wages. The central bank is expected to hold
+		interest rates steady at its next meeting, according to analysts who follow the economy closely.
+		Shares of technology companies fell in early trading, while oil prices climbed for the third straight day.
+		The president will travel to the region next week to meet with leaders and discuss trade, security and
+		the response to recent storms that have damaged thousands of homes. Many residents are still waiting for
+		help, and local officials have asked for more money to rebuild bridges and restore power.`,
+
+	"es": `El gobierno anunció el martes que aumentará el gasto en carreteras y escuelas después de un año
+		de crecimiento lento. Los funcionarios dijeron que el plan fue diseñado para apoyar a los trabajadores y a
+		las pequeñas empresas mientras se mantiene el presupuesto bajo control. Los críticos argumentaron que la
+		propuesta no es suficiente y que los precios de los alimentos y la energía siguen subiendo más rápido que
+		los salarios. Se espera que el banco central mantenga las tasas de interés sin cambios en su próxima reunión,
+		según los analistas que siguen de cerca la economía. Las acciones de las empresas de tecnología cayeron en
+		las primeras operaciones, mientras que los precios del petróleo subieron por tercer día consecutivo.
+		El presidente viajará a la región la próxima semana para reunirse con los líderes y hablar sobre comercio,
+		seguridad y la respuesta a las tormentas que han dañado miles de viviendas.`,
+
+	"fr": `Le gouvernement a annoncé mardi qu'il allait augmenter les dépenses pour les routes et les écoles
+		après une année de croissance lente. Les responsables ont déclaré que le plan était conçu pour soutenir
+		les travailleurs et les petites entreprises tout en gardant le budget sous contrôle. Les critiques ont
+		estimé que la proposition n'allait pas assez loin et que les prix de l'alimentation et de l'énergie
+		augmentaient toujours plus vite que les salaires. La banque centrale devrait maintenir ses taux d'intérêt
+		lors de sa prochaine réunion, selon les analystes qui suivent de près l'économie. Les actions des sociétés
+		technologiques ont reculé en début de séance, tandis que les prix du pétrole ont progressé pour le troisième
+		jour consécutif. Le président se rendra dans la région la semaine prochaine pour rencontrer les dirigeants
+		et discuter du commerce, de la sécurité et de la réponse aux tempêtes qui ont endommagé des milliers de maisons.`,
+
+	"de": `Die Regierung kündigte am Dienstag an, dass sie nach einem Jahr mit schwachem Wachstum mehr Geld
+		für Straßen und Schulen ausgeben wird. Die Verantwortlichen sagten, der Plan solle Arbeitnehmer und
+		kleine Unternehmen unterstützen und gleichzeitig den Haushalt unter Kontrolle halten. Kritiker meinten,
+		der Vorschlag gehe nicht weit genug und die Preise für Lebensmittel und Energie würden immer noch
+		schneller steigen als die Löhne. Die Zentralbank wird bei ihrer nächsten Sitzung die Zinsen voraussichtlich
+		nicht verändern, so die Analysten, die die Wirtschaft genau beobachten. Die Aktien von Technologiefirmen
+		fielen zu Beginn des Handels, während der Ölpreis den dritten Tag in Folge stieg. Der Präsident wird in der
+		nächsten Woche in die Region reisen, um sich mit den Regierungschefs zu treffen und über Handel, Sicherheit
+		und die Reaktion auf die Stürme zu sprechen, die tausende Häuser beschädigt haben.`,
+
+	"it": `Il governo ha annunciato martedì che aumenterà la spesa per strade e scuole dopo un anno di crescita
+		lenta. I funzionari hanno detto che il piano è stato pensato per sostenere i lavoratori e le piccole imprese
+		mantenendo il bilancio sotto controllo. I critici hanno sostenuto che la proposta non va abbastanza lontano
+		e che i prezzi del cibo e dell'energia continuano a salire più velocemente dei salari. Secondo gli analisti
+		che seguono da vicino l'economia, la banca centrale dovrebbe lasciare invariati i tassi di interesse nella
+		prossima riunione. Le azioni delle società tecnologiche sono scese nelle prime contrattazioni, mentre il
+		prezzo del petrolio è salito per il terzo giorno consecutivo. Il presidente si recherà nella regione la
+		prossima settimana per incontrare i leader e discutere di commercio, sicurezza e della risposta alle
+		tempeste che hanno danneggiato migliaia di case.`,
+
+	"pt": `O governo anunciou na terça-feira que vai aumentar os gastos com estradas e escolas depois de um ano
+		de crescimento lento. Os responsáveis disseram que o plano foi pensado para apoiar os trabalhadores e as
+		pequenas empresas, mantendo o orçamento sob controle. Os críticos argumentaram que a proposta não vai
+		longe o suficiente e que os preços dos alimentos e da energia continuam subindo mais rápido do que os
+		salários. O banco central deve manter as taxas de juros na próxima reunião, segundo os analistas que
+		acompanham de perto a economia. As ações das empresas de tecnologia caíram no início do pregão, enquanto
+		o preço do petróleo subiu pelo terceiro dia seguido. O presidente vai viajar para a região na próxima
+		semana para se reunir com os líderes e falar sobre comércio, segurança e a resposta às tempestades que
+		danificaram milhares de casas.`,
+
+	"nl": `De regering maakte dinsdag bekend dat zij na een jaar van trage groei meer geld gaat uitgeven aan
+		wegen en scholen. Volgens de betrokken ambtenaren is het plan bedoeld om werknemers en kleine bedrijven te
+		steunen en tegelijk de begroting onder controle te houden. Critici vinden dat het voorstel niet ver genoeg
+		gaat en dat de prijzen van voedsel en energie nog altijd sneller stijgen dan de lonen. De centrale bank
+		zal de rente bij de volgende vergadering naar verwachting niet veranderen, zeggen analisten die de
+		economie op de voet volgen. De aandelen van technologiebedrijven daalden bij de opening van de handel,
+		terwijl de olieprijs voor de derde dag op rij steeg. De president reist volgende week naar de regio om
+		met de leiders te praten over handel, veiligheid en de aanpak van de stormen die duizenden huizen hebben
+		beschadigd.`,
+
+	"sv": `Regeringen meddelade på tisdagen att den ska öka utgifterna för vägar och skolor efter ett år med
+		svag tillväxt. Tjänstemännen sade att planen är tänkt att stödja arbetstagare och små företag samtidigt som
+		budgeten hålls under kontroll. Kritiker menade att förslaget inte går tillräckligt långt och att priserna
+		på mat och energi fortfarande stiger snabbare än lönerna. Centralbanken väntas lämna räntan oförändrad vid
+		nästa möte, enligt de analytiker som följer ekonomin noga. Aktierna i teknikföretagen föll i början av
+		handeln, medan oljepriset steg för tredje dagen i rad. Presidenten reser till regionen nästa vecka för att
+		träffa ledarna och diskutera handel, säkerhet och hur man ska hantera stormarna som har skadat tusentals hus.`,
+
+	"pl": `Rząd ogłosił we wtorek, że po roku powolnego wzrostu zwiększy wydatki na drogi i szkoły. Urzędnicy
+		powiedzieli, że plan ma wspierać pracowników i małe firmy, a jednocześnie utrzymać budżet pod kontrolą.
+		Krytycy twierdzą, że propozycja nie idzie wystarczająco daleko, a ceny żywności i energii wciąż rosną
+		szybciej niż płace. Według analityków, którzy uważnie obserwują gospodarkę, bank centralny na najbliższym
+		posiedzeniu prawdopodobnie nie zmieni stóp procentowych. Akcje spółek technologicznych spadły na początku
+		notowań, podczas gdy ceny ropy rosły trzeci dzień z rzędu. Prezydent w przyszłym tygodniu uda się do
+		regionu, aby spotkać się z przywódcami i rozmawiać o handlu, bezpieczeństwie oraz o pomocy po burzach,
+		które uszkodziły tysiące domów.`,
+}
+
+// cyrillicSamples train the profiles that tell Cyrillic script languages apart, keyed by ISO 639-1 code
+var cyrillicSamples = map[string]string{
+	"ru": `Правительство объявило во вторник, что увеличит расходы на дороги и школы после года медленного роста.
+		Чиновники заявили, что план призван поддержать работников и малый бизнес, сохраняя при этом бюджет под
+		контролем. Критики утверждают, что предложение недостаточно и что цены на продукты питания и энергию
+		по-прежнему растут быстрее, чем зарплаты. Ожидается, что центральный банк сохранит процентные ставки на
+		следующем заседании, по мнению аналитиков, которые внимательно следят за экономикой. Акции технологических
+		компаний упали в начале торгов, а цены на нефть выросли третий день подряд. Президент на следующей неделе
+		посетит регион, чтобы встретиться с руководителями и обсудить торговлю, безопасность и ответ на недавние
+		штормы, которые повредили тысячи домов. Многие жители всё ещё ждут помощи, и местные власти попросили
+		больше денег на восстановление мостов и электроснабжения.`,
+
+	"uk": `Уряд оголосив у вівторок, що збільшить витрати на дороги та школи після року повільного зростання.
+		Посадовці заявили, що план має підтримати працівників і малий бізнес, зберігаючи при цьому бюджет під
+		контролем. Критики вважають, що пропозиція недостатня і що ціни на продукти харчування та енергію й досі
+		зростають швидше, ніж зарплати. Очікується, що центральний банк збереже відсоткові ставки на наступному
+		засіданні, за словами аналітиків, які уважно стежать за економікою. Акції технологічних компаній впали на
+		початку торгів, а ціни на нафту зросли третій день поспіль. Президент наступного тижня відвідає регіон,
+		щоб зустрітися з керівниками та обговорити торгівлю, безпеку і відповідь на нещодавні шторми, які
+		пошкодили тисячі будинків. Багато мешканців досі чекають на допомогу, і місцева влада попросила більше
+		грошей на відновлення мостів та електропостачання.`,
+
+	"bg": `Правителството обяви във вторник, че ще увеличи разходите за пътища и училища след година на бавен
+		растеж. Официални лица заявиха, че планът е предназначен да подкрепи работниците и малкия бизнес, като
+		същевременно запази бюджета под контрол. Критиците смятат, че предложението не е достатъчно и че цените на
+		храните и енергията все още растат по-бързо от заплатите. Очаква се централната банка да запази лихвените
+		проценти без промяна на следващото си заседание, според анализатори, които следят отблизо икономиката.
+		Акциите на технологичните компании поевтиняха в началото на търговията, а цените на петрола се повишиха за
+		трети пореден ден. Президентът ще посети региона следващата седмица, за да се срещне с лидерите и да
+		обсъди търговията, сигурността и отговора на скорошните бури, които повредиха хиляди домове. Много жители
+		все още чакат помощ, а местните власти поискаха повече пари за възстановяване на мостовете.`,
+
+	"sr": `Влада је у уторак објавила да ће повећати издвајања за путеве и школе након године спорог раста.
+		Званичници су рекли да је план осмишљен да подржи раднике и мала предузећа, уз истовремено држање буџета
+		под контролом. Критичари сматрају да предлог није довољан и да цене хране и енергије и даље расту брже од
+		плата. Очекује се да ће централна банка задржати каматне стопе непромењеним на следећој седници, према
+		речима аналитичара који пажљиво прате привреду. Акције технолошких компанија пале су на почетку трговања,
+		док су цене нафте порасле трећи дан заредом. Председник ће следеће недеље посетити регион како би се
+		састао са лидерима и разговарао о трговини, безбедности и одговору на недавне олује које су оштетиле
+		хиљаде кућа. Многи становници још увек чекају помоћ, а локалне власти затражиле су више новца за обнову
+		мостова и снабдевање струјом.`,
+}
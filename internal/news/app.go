@@ -1,12 +1,11 @@
 package news
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"math/rand"
 	"os"
-	"sync"
 	"sync/atomic"
-	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/turnage/graw/reddit"
@@ -14,69 +13,119 @@ import (
 	"github.com/wpwilson10/caterpillar/internal/setup"
 )
 
-// App queries rss news sources for articles and adds new ones to the database
+// App queries rss, sitemap, and hacker news sources for articles and adds new ones to the database
 func App() {
+	ctx := context.Background()
 	// connect to redis cache
-	articleSet := redis.NewSet(setup.Redis(), os.Getenv("NEWSPAPER_SET"))
+	client, err := setup.Redis(ctx)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to Redis")
+	}
+	articleSet := redis.NewSet(client, os.Getenv("NEWSPAPER_SET"))
 	// connect to database
-	db := setup.SQL()
-	// setup blacklist of article hosts to avoid
-	blacklist := NewBlackList()
-	// randomize time between calls
-	rand.Seed(time.Now().UnixNano())
-	// prep for async calls
-	var wg sync.WaitGroup
+	db, err := setup.SQL(ctx)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to SQL database")
+	}
+	// setup blacklist of article links to avoid
+	blacklist, err := BlackListFromEnv(ctx, db)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Failed BlackListFromEnv")
+	}
+	// follow each host's robots.txt
+	robots := NewRobotsCacheFromEnv(client)
+	// crawl hosts in parallel while being polite to each host
+	scheduler := NewSchedulerFromEnv().WithHostDelay(robots.CrawlDelay)
 	var numArticles uint64
 
-	// get data from rss feeds
-	sources := SourceListFromRSS(articleSet)
+	// get data from rss feeds and sitemaps
+	sources := SourceListFromRSS(ctx, db, articleSet, scheduler)
+	sources = uniqueSources(append(sources, SourceListFromSitemaps(ctx, db, articleSet, scheduler)...))
 	// process each source
+	tasks := []CrawlTask{}
 	for _, source := range sources {
-		// async parts - hands off a source for processing
-		wg.Add(1)
-		go func(source *Source) {
-			defer wg.Done()
-			// do the work of getting data and saving it
-			a := Driver(source, db, articleSet, blacklist)
-			// count number of articles successfully returned
-			if a != nil {
-				atomic.AddUint64(&numArticles, 1)
-			}
-
-		}(source)
-
-		// random sleep time to be nice
-		n := rand.Int63n(5000) // n will be between 0 and 5000
-		// base time of one second + [0 - 5] seconds
-		// average = 3.5 seconds = 1 second base + 2.5 second expected
-		t := time.Second + (time.Millisecond * time.Duration(n))
-		time.Sleep(t)
-	}
-
-	// wait to finish
-	wg.Wait()
+		source := source
+		tasks = append(tasks, CrawlTask{
+			Host: source.Host,
+			Run: func() {
+				// do the work of getting data and saving it
+				a := Driver(ctx, source, db, articleSet, blacklist, robots)
+				// count number of articles successfully returned
+				if a != nil {
+					atomic.AddUint64(&numArticles, 1)
+				}
+			},
+		})
+	}
+
+	// get stories from hacker news
+	stories := SourceListFromHackerNews(ctx, NewHNClientFromEnv())
+	for _, item := range stories {
+		item := item
+		tasks = append(tasks, CrawlTask{
+			Host: NewSource(FromHackerNews(item)).Host,
+			Run: func() {
+				a := HackerNewsDriver(ctx, db, articleSet, blacklist, robots, item)
+				if a != nil {
+					atomic.AddUint64(&numArticles, 1)
+				}
+			},
+		})
+	}
+
+	// learn each host's Crawl-delay before its first request
+	robots.Prefetch(ctx, tasks)
+	// blocks until all sources are processed
+	scheduler.Run(ctx, tasks)
 
 	// run summary
+	blacklist.LogCounts()
 	setup.LogCommon(nil).
 		WithField("NumSources", len(sources)).
+		WithField("NumStories", len(stories)).
 		WithField("NumArticles", numArticles).
 		WithField("RunTime", setup.RunTime().String()).
 		Info("RunSummary")
 }
 
+// uniqueSources removes sources with the same link as an earlier source,
+// such as an article listed in both a site's rss feed and its sitemap.
+func uniqueSources(sources []*Source) []*Source {
+	links := make(map[string]bool)
+	out := []*Source{}
+	for _, s := range sources {
+		if !links[s.Link] {
+			links[s.Link] = true
+			out = append(out, s)
+		}
+	}
+
+	return out
+}
+
 // Driver uses a source to retrieve article data and save it into the database.
 // Article will have be inserted into database and cached on successful calls.
 // Returns nil if we have seen article before or failing to get or process article.
-func Driver(source *Source, db *sqlx.DB, articleSet *redis.Set, blacklast *BlackList) *Article {
+func Driver(ctx context.Context, source *Source, db *sqlx.DB, articleSet *redis.Set, blacklast *BlackList, robots *RobotsCache) *Article {
 	// check if we have seen this source before
-	if len(source.Link) > 1 && articleSet.IsMember(source.Link) {
+	if len(source.Link) > 1 && seen(ctx, articleSet, source.Link) {
 		// skip
 		return nil
 	}
-	// check if host is on blacklist
-	if blacklast.IsBlackListed(source.Host) {
-		// host may be updated to canonical, check if this is blacklisted
-		fmt.Println("Black listed", source.Host, source.Source)
+	// check if the link was filtered by the current language settings before
+	if len(source.Link) > 1 && seen(ctx, articleSet, languageFilteredKey(source.Link)) {
+		// skip
+		return nil
+	}
+	// check if link is on blacklist
+	if rule, ok := blacklast.Match(source.Link); ok {
+		fmt.Println("Black listed", rule, source.Link, source.Source)
+		// skip
+		return nil
+	}
+
+	// check that the host allows us to fetch this link
+	if !robotsAllowed(ctx, db, robots, source) {
 		// skip
 		return nil
 	}
@@ -84,15 +133,16 @@ func Driver(source *Source, db *sqlx.DB, articleSet *redis.Set, blacklast *Black
 	// Make sure the server is running
 	setup.CheckPythonServer()
 
-	// call newspaper3k to get data
-	newspaper := NewNewspaper(source)
+	// archive the raw page if enabled, then call newspaper3k to get data
+	html, archiveID := archivePage(ctx, db, source)
+	newspaper := NewNewspaperHTML(source, html)
 	// check we got something
 	if newspaper == nil {
 		return nil
 	}
 
 	// check if we have seen the article before using the canonical link
-	if len(newspaper.Canonical) > 1 && articleSet.IsMember(newspaper.Canonical) {
+	if len(newspaper.Canonical) > 1 && seen(ctx, articleSet, newspaper.Canonical) {
 		// skip
 		return nil
 	}
@@ -107,58 +157,175 @@ func Driver(source *Source, db *sqlx.DB, articleSet *redis.Set, blacklast *Black
 	} else if article.SourceTitle.IsZero() && article.Title.IsZero() {
 		// no titles
 		return nil
-	} else if blacklast.IsBlackListed(article.Host) {
-		// host may be updated to canonical, check if this is blacklisted
-		fmt.Println("Black listed", article.Link, source.Host, source.Source)
+	} else if article.CanonicalLink.Valid {
+		// link may be updated to canonical, check if this is blacklisted
+		if rule, ok := blacklast.Match(article.CanonicalLink.String); ok {
+			fmt.Println("Black listed", rule, article.CanonicalLink.String, source.Link, source.Source)
+			return nil
+		}
+	}
+
+	// skip articles in languages we don't want, remembering them until the language settings change
+	if !HostLanguageAllowed(article.Host, article.Language.String) {
+		fmt.Println("Language filtered", article.Language.String, source.Link, source.Source)
+		cache(ctx, articleSet, languageFilteredKey(source.Link))
+		if len(newspaper.Canonical) > 1 {
+			cache(ctx, articleSet, languageFilteredKey(newspaper.Canonical))
+		}
 		return nil
 	}
 
+	// flag paywalls, bot walls, and truncated bodies
+	classifyArticle(ctx, db, article)
+
 	// Put article in database
 	article.Insert(db)
+	if article.ArticleID != 0 {
+		// track the host's extraction quality
+		recordArticleExtraction(ctx, db, article)
+		// index the archived page by article
+		linkArchive(ctx, db, archiveID, article.ArticleID)
+		// link normalized authors
+		saveArticleAuthors(ctx, db, article.ArticleID, newspaper.Authors)
+		// link mentioned stock listings
+		saveArticleListings(ctx, db, article)
+		// find syndicated copies of the same story
+		fingerprintArticle(ctx, db, article)
+	}
 	// cache known links so we don't duplicate articles
-	articleSet.Add(source.Link)
-	articleSet.Add(newspaper.Canonical)
+	cache(ctx, articleSet, source.Link)
+	cache(ctx, articleSet, newspaper.Canonical)
 
 	return article
 }
 
+// robotsAllowed returns true if the host's robots.txt allows fetching the source.
+// Disallowed sources are recorded so they are not retried.
+func robotsAllowed(ctx context.Context, db *sqlx.DB, robots *RobotsCache, source *Source) bool {
+	// check if robots.txt has disallowed this link before
+	disallowed, err := robots.IsDisallowed(ctx, source.Link)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("link", source.Link).
+			Error("Failed IsDisallowed")
+		return false
+	} else if disallowed {
+		return false
+	}
+
+	// rules match the page that is fetched rather than its standard form
+	allowed, err := robots.Allowed(ctx, source.fetchLink())
+	if err != nil {
+		// rules are unknown so try again on a later run
+		setup.LogCommon(err).
+			WithField("link", source.fetchLink()).
+			Warn("Failed robots.txt")
+		return false
+	} else if !allowed {
+		fmt.Println("Robots disallowed", source.fetchLink())
+		err = robots.Disallow(ctx, db, source)
+		if err != nil {
+			setup.LogCommon(err).
+				WithField("link", source.Link).
+				Error("Failed Disallow")
+		}
+		return false
+	}
+
+	return true
+}
+
+// seen returns true if the link is in the article set.
+// A failed lookup counts as seen so that a Redis outage does not cause duplicate inserts.
+func seen(ctx context.Context, articleSet *redis.Set, link string) bool {
+	isMember, err := articleSet.IsMember(ctx, link)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("link", link).
+			Error("Failed IsMember")
+		return true
+	}
+
+	return isMember
+}
+
+// cache adds the link to the article set, logging on failure.
+func cache(ctx context.Context, articleSet *redis.Set, link string) {
+	err := articleSet.Add(ctx, link)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("link", link).
+			Error("Failed Add")
+	}
+}
+
 // RedditNewsDriver adds news articles from reddit posts to the NewsArticle database
 // and adds a RedditNews relationship entry to the RedditNews table.
-func RedditNewsDriver(db *sqlx.DB, articleSet *redis.Set, blacklist *BlackList, submission *reddit.Post, sID int64) {
+func RedditNewsDriver(ctx context.Context, db *sqlx.DB, articleSet *redis.Set, blacklist *BlackList, robots *RobotsCache, submission *reddit.Post, sID int64) {
 	// quick initial check that submissions have a link
 	if len(submission.URL) <= 2 {
 		// dont log error because it is normal for submissions to not have external link
 		return
 	}
 
-	// submission that has been seen before
-	if articleSet.IsMember(submission.URL) {
-		// get the previous submission
-		article := FindArticle(db, submission.URL)
+	// put into form ArticleDriver expects
+	source := NewSource(FromReddit(submission))
+	redditNews(ctx, db, articleSet, blacklist, robots, source, sID)
+}
+
+// redditNews links the source's article to the reddit submission, getting the article first if it is new.
+// Returns the article if a new one was added.
+func redditNews(ctx context.Context, db *sqlx.DB, articleSet *redis.Set, blacklist *BlackList, robots *RobotsCache, source *Source, sID int64) *Article {
+	return linkArticle(ctx, db, articleSet, blacklist, robots, source, func(articleID int64) {
+		rn := NewRedditNews(articleID, sID)
+		rn.Insert(db)
+	})
+}
+
+// linkArticle calls link with the ID of the source's article, getting the article first if it is new.
+// Sources such as reddit and Hacker News use it to record which of their posts point at an article.
+// Returns the article if a new one was added.
+func linkArticle(ctx context.Context, db *sqlx.DB, articleSet *redis.Set, blacklist *BlackList, robots *RobotsCache,
+	source *Source, link func(articleID int64)) *Article {
+	isMember, err := articleSet.IsMember(ctx, source.Link)
+	if err != nil {
+		// can't tell if this is new, so leave it for a later run
+		setup.LogCommon(err).
+			WithField("link", source.Link).
+			WithField("source", source.Source).
+			Error("Failed IsMember")
+		return nil
+	}
+
+	// link that has been seen before
+	if isMember {
+		article, err := FindArticle(ctx, db, source.Link)
 		// sanity check that article exists
-		if article == nil {
+		if errors.Is(err, ErrArticleNotFound) {
 			setup.LogCommon(nil).
-				WithField("redditID", submission.ID).
-				WithField("redditURL", submission.URL).
-				WithField("SubmissionID", sID).
+				WithField("link", source.Link).
+				WithField("source", source.Source).
 				Error("Failed to find article in articleSet")
+		} else if err != nil {
+			setup.LogCommon(err).
+				WithField("link", source.Link).
+				WithField("source", source.Source).
+				Error("Failed FindArticle")
 		} else {
-			fmt.Println("Found existing article", article.ArticleID, article.Link, submission.Permalink)
-			// create a table entry and insert it
-			rn := NewRedditNews(article.ArticleID, sID)
-			rn.Insert(db)
-		}
-	} else {
-		// submission that has not been seen before
-		// put into form ArticleDriver expects
-		source := NewSource(FromReddit(submission))
-		// get article and add to database
-		article := Driver(source, db, articleSet, blacklist)
-		// check that article exists
-		if article != nil {
-			// create a table entry and insert it
-			rn := NewRedditNews(article.ArticleID, sID)
-			rn.Insert(db)
+			fmt.Println("Found existing article", article.ArticleID, article.Link, source.Source)
+			link(article.ArticleID)
 		}
+
+		return nil
+	}
+
+	// link that has not been seen before
+	// get article and add to database
+	article := Driver(ctx, source, db, articleSet, blacklist, robots)
+	// check that article was stored
+	if article != nil && article.ArticleID != 0 {
+		link(article.ArticleID)
 	}
+
+	return article
 }
@@ -0,0 +1,417 @@
+package news
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/guregu/null.v3"
+
+	"github.com/wpwilson10/caterpillar/internal/setup"
+	"github.com/wpwilson10/caterpillar/internal/warc"
+)
+
+// largest page body that is archived, longer pages are truncated
+const maxPageBytes = 10 << 20
+
+// largest html sent to newspaper3k, under gRPC's default 4 MB message limit.
+// Larger pages are downloaded by newspaper3k instead.
+const maxExtractBytes = 3 << 20
+
+// ArchivedPage indexes a raw HTTP response stored in a WARC file,
+// in a format matching the NewsArchive table schema.
+type ArchivedPage struct {
+	ArchiveID   int64     `db:"archive_id"`
+	ArticleID   null.Int  `db:"article_id"` // null until the page's article is inserted
+	DataTime    time.Time `db:"data_entry_time"`
+	Link        string    `db:"link"`
+	StatusCode  int       `db:"status_code"`
+	ContentType string    `db:"content_type"`
+	WarcFile    string    `db:"warc_file"`
+	WarcOffset  int64     `db:"warc_offset"`
+	WarcLength  int64     `db:"warc_length"`
+}
+
+// pageArchive is the process wide WARC writer, nil if archiving is off
+var pageArchive struct {
+	once sync.Once
+	dir  string
+	w    *warc.Writer
+}
+
+// archiveFromEnv returns the WARC writer for the NEWSPAPER_WARC_DIR directory,
+// rotating files at NEWSPAPER_WARC_MAX_MB megabytes (default 1024).
+// Returns nil if NEWSPAPER_WARC_DIR is not set.
+func archiveFromEnv() (*warc.Writer, string) {
+	pageArchive.once.Do(func() {
+		dir := os.Getenv("NEWSPAPER_WARC_DIR")
+		if len(dir) == 0 {
+			return
+		}
+
+		maxSize := int64(setup.EnvToIntOr("NEWSPAPER_WARC_MAX_MB", 1024)) << 20
+		// process id keeps files from concurrent runs apart
+		w, err := warc.NewWriter(dir, fmt.Sprintf("news-%d", os.Getpid()), maxSize)
+		if err != nil {
+			setup.LogCommon(err).
+				WithField("dir", dir).
+				Error("Failed warc.NewWriter")
+			return
+		}
+		pageArchive.dir, pageArchive.w = dir, w
+	})
+
+	return pageArchive.w, pageArchive.dir
+}
+
+// archivePage fetches the source's page, stores the raw response in the WARC archive, and indexes it.
+// Returns the page's html for extraction and the archive ID, or an empty string and 0
+// if archiving is off, the fetch failed, or the page should be downloaded by newspaper3k.
+func archivePage(ctx context.Context, db *sqlx.DB, source *Source) (string, int64) {
+	w, _ := archiveFromEnv()
+	if w == nil {
+		return "", 0
+	}
+
+	resp, body, err := fetchPage(ctx, source.fetchLink())
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("Link", source.fetchLink()).
+			Warn("Failed fetchPage")
+		return "", 0
+	}
+
+	raw, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("Link", source.Link).
+			Warn("Failed DumpResponse")
+		return "", 0
+	}
+
+	loc, err := w.Write(&warc.Record{
+		Type:        warc.TypeResponse,
+		TargetURI:   resp.Request.URL.String(),
+		ContentType: "application/http;msgtype=response",
+		Block:       raw,
+	})
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("Link", source.Link).
+			Error("Failed warc Write")
+		return "", 0
+	}
+
+	// index the link that was fetched, after redirects, to match the record's WARC-Target-URI
+	page := &ArchivedPage{
+		DataTime:    time.Now(),
+		Link:        resp.Request.URL.String(),
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		WarcFile:    loc.File,
+		WarcOffset:  loc.Offset,
+		WarcLength:  loc.Length,
+	}
+	err = page.Insert(ctx, db)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("Link", source.Link).
+			Error("Failed ArchivedPage Insert")
+		return "", 0
+	}
+
+	if !page.Extractable() || len(body) > maxExtractBytes {
+		return "", page.ArchiveID
+	}
+
+	return string(body), page.ArchiveID
+}
+
+// fetchPage gets the link with our user agent, reading at most maxPageBytes of the body.
+// The returned response's body is replaced with the read bytes so it can be dumped.
+func fetchPage(ctx context.Context, link string) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if agent := os.Getenv("PY_NEWSPAPER_USER_AGENT"); len(agent) > 0 {
+		req.Header.Set("User-Agent", agent)
+	}
+
+	client := &http.Client{Timeout: time.Second * 30}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxPageBytes))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// store the body as read, without the transfer encoding
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.TransferEncoding = nil
+	resp.Header.Del("Content-Length")
+
+	return resp, body, nil
+}
+
+// Extractable returns true if the archived response is a successful html page.
+func (page *ArchivedPage) Extractable() bool {
+	return page.StatusCode >= 200 && page.StatusCode < 300 &&
+		(len(page.ContentType) == 0 || strings.Contains(page.ContentType, "html"))
+}
+
+// Insert adds this page to the NewsArchive table. Updates ArchiveID to the real database value.
+func (page *ArchivedPage) Insert(ctx context.Context, db *sqlx.DB) error {
+	var insertStmt string = `INSERT INTO NewsArchive (
+								article_id,
+								data_entry_time,
+								link,
+								status_code,
+								content_type,
+								warc_file,
+								warc_offset,
+								warc_length
+								)`
+
+	var valueStmt string = `VALUES (
+								:article_id,
+								:data_entry_time,
+								:link,
+								:status_code,
+								:content_type,
+								:warc_file,
+								:warc_offset,
+								:warc_length
+								)`
+
+	stmt, err := db.PrepareNamedContext(ctx, insertStmt+" "+valueStmt+" RETURNING archive_id")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	return stmt.GetContext(ctx, &page.ArchiveID, page)
+}
+
+// linkArchive records the article an archived page belongs to, logging on failure.
+func linkArchive(ctx context.Context, db *sqlx.DB, archiveID int64, articleID int64) {
+	if archiveID == 0 || articleID == 0 {
+		return
+	}
+
+	_, err := db.ExecContext(ctx, "UPDATE NewsArchive SET article_id = $1 WHERE archive_id = $2", articleID, archiveID)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("ArchiveID", archiveID).
+			WithField("ArticleID", articleID).
+			Error("Failed linkArchive")
+	}
+}
+
+// ArchivedHTML reads the page's html body from the WARC archive in dir.
+func ArchivedHTML(dir string, page *ArchivedPage) (string, error) {
+	record, err := warc.ReadRecord(dir, warc.Location{File: page.WarcFile, Offset: page.WarcOffset, Length: page.WarcLength})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(record.Block)), nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	return string(body), nil
+}
+
+// reextract runs newspaper3k on the article's archived page and updates the article if the result changed.
+// Returns true if the article was updated.
+func reextract(ctx context.Context, db *sqlx.DB, dir string, page *ArchivedPage) (bool, error) {
+	html, err := ArchivedHTML(dir, page)
+	if err != nil {
+		return false, err
+	}
+	if len(html) > maxExtractBytes {
+		return false, nil
+	}
+
+	old := Article{}
+	err = db.GetContext(ctx, &old, "SELECT "+ArticleColumns("")+" FROM NewsArticle WHERE article_id = $1", page.ArticleID.Int64)
+	if err == sql.ErrNoRows {
+		return false, ErrArticleNotFound
+	} else if err != nil {
+		return false, err
+	}
+
+	// rebuild the source as it was when the article was first crawled, from the link the page was fetched at
+	source := &Source{
+		Title:     old.SourceTitle.String,
+		Link:      old.Link,
+		FetchLink: page.Link,
+		Source:    old.Source,
+		Host:      old.Host,
+		PubDate:   old.SourcePublishedTime.Ptr(),
+		FeedID:    old.FeedID,
+	}
+
+	// Make sure the server is running
+	setup.CheckPythonServer()
+
+	newspaper := NewNewspaperHTML(source, html)
+	if newspaper == nil {
+		return false, nil
+	}
+	article := NewArticle(newspaper, source)
+	// keep the old extraction if the new one is not usable
+	if article.Body.IsZero() {
+		return false, nil
+	}
+	classifyArticle(ctx, db, article)
+
+	if article.Title == old.Title && article.Body == old.Body && article.Authors == old.Authors &&
+		article.CanonicalLink == old.CanonicalLink && article.PublishedTime.Equal(old.PublishedTime) &&
+		article.ExtractionStatus == old.ExtractionStatus {
+		return false, nil
+	}
+
+	var updateStmt string = `UPDATE NewsArticle SET
+								title = $2,
+								canonical_link = $3,
+								body = $4,
+								authors = $5,
+								published_time = $6,
+								published_time_source = $7,
+								language = $8,
+								extraction_status = $9
+							 WHERE article_id = $1`
+
+	_, err = db.ExecContext(ctx, updateStmt,
+		old.ArticleID,
+		article.Title,
+		article.CanonicalLink,
+		article.Body,
+		article.Authors,
+		article.PublishedTime,
+		article.PublishedTimeSource,
+		article.Language,
+		article.ExtractionStatus)
+	if err != nil {
+		return false, err
+	}
+	saveArticleAuthors(ctx, db, old.ArticleID, newspaper.Authors)
+	article.ArticleID = old.ArticleID
+	saveArticleListings(ctx, db, article)
+
+	return true, nil
+}
+
+// ReextractArticles reruns extraction from the first archived page of each article entered in the
+// backfill's date range, checkpointing after each batch.
+// The backfill's article count is the number of articles that were updated.
+func ReextractArticles(ctx context.Context, db *sqlx.DB, dir string, b *Backfill, batchSize int) error {
+	var selectStmt string = `SELECT DISTINCT ON (n.article_id) n.* FROM NewsArchive n
+							 JOIN NewsArticle a ON a.article_id = n.article_id
+							 WHERE a.data_entry_time >= $1 AND a.data_entry_time < $2
+								AND n.article_id > $3
+							 ORDER BY n.article_id ASC, n.archive_id ASC
+							 LIMIT $4`
+
+	return RunBackfill(ctx, db, b, batchSize, func(lastID int64, batchSize int) (int64, int64, int64, error) {
+		batch := []*ArchivedPage{}
+		err := db.SelectContext(ctx, &batch, selectStmt, b.RangeStart, b.RangeEnd, lastID, batchSize)
+		if err != nil || len(batch) == 0 {
+			return 0, 0, 0, err
+		}
+
+		var numUpdated int64
+		for _, page := range batch {
+			if !page.Extractable() {
+				continue
+			}
+
+			updated, err := reextract(ctx, db, dir, page)
+			if err != nil {
+				setup.LogCommon(err).
+					WithField("ArticleID", page.ArticleID.Int64).
+					WithField("ArchiveID", page.ArchiveID).
+					Warn("Failed reextract")
+			} else if updated {
+				fmt.Println("Reextracted", page.ArticleID.Int64, page.Link)
+				numUpdated = numUpdated + 1
+			}
+		}
+
+		return batch[len(batch)-1].ArticleID.Int64, int64(len(batch)), numUpdated, nil
+	})
+}
+
+// ReextractApp reruns newspaper3k extraction on archived pages of articles entered in [from, to)
+// in batches of batchSize, updating articles whose extraction changed.
+// Pages are read from the NEWSPAPER_WARC_DIR archive. Progress is saved in the NewsBackfill table
+// so that running again with the same range resumes.
+func ReextractApp(from time.Time, to time.Time, batchSize int) {
+	if !from.Before(to) || batchSize < 1 {
+		setup.LogCommon(nil).
+			WithField("from", from).
+			WithField("to", to).
+			WithField("batch", batchSize).
+			Fatal("Invalid backfill range or batch size")
+	}
+
+	dir := os.Getenv("NEWSPAPER_WARC_DIR")
+	if len(dir) == 0 {
+		setup.LogCommon(nil).Fatal("NEWSPAPER_WARC_DIR is not set")
+	}
+
+	ctx := context.Background()
+	// connect to database
+	db, err := setup.SQL(ctx)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to SQL database")
+	}
+
+	b, err := LoadBackfill(ctx, db, "reextract", from, to)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Failed LoadBackfill")
+	}
+	if b.CompletedTime.Valid {
+		fmt.Println("Backfill already complete", b.Name)
+	}
+
+	err = ReextractArticles(ctx, db, dir, b, batchSize)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("backfill", b.Name).
+			WithField("LastID", b.LastID).
+			Fatal("Failed ReextractArticles")
+	}
+
+	// run summary
+	setup.LogCommon(nil).
+		WithField("backfill", b.Name).
+		WithField("NumProcessed", b.NumProcessed).
+		WithField("NumArticles", b.NumArticles).
+		WithField("RunTime", setup.RunTime().String()).
+		Info("RunSummary")
+}
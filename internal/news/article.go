@@ -1,7 +1,10 @@
 package news
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/url"
 	"strings"
 	"time"
@@ -9,55 +12,98 @@ import (
 	"github.com/jmoiron/sqlx"
 	"gopkg.in/guregu/null.v3"
 
+	"github.com/wpwilson10/caterpillar/internal/langid"
 	"github.com/wpwilson10/caterpillar/internal/setup"
 )
 
 // Article stores a news article in a format matching the NewsArticle table schema
 type Article struct {
-	ArticleID           int64       `db:"article_id"`            // updated to database value after call to insert
-	DataTime            time.Time   `db:"data_entry_time"`       //
-	Source              string      `db:"source"`                // source of the link (e.g. RSS, Reddit)
-	Host                string      `db:"host"`                  // host or vendor of article (e.g. www.cnn.com, www.espn.com)
-	Link                string      `db:"link"`                  // original url from the reference
-	SourcePublishedTime null.Time   `db:"source_published_time"` // published time from source if given
-	PublishedTime       null.Time   `db:"published_time"`        // published time from newspaper3k if given
-	SourceTitle         null.String `db:"source_title"`
-	Title               null.String `db:"title"`
-	CanonicalLink       null.String `db:"canonical_link"`
-	Body                null.String `db:"body"`
-	Authors             null.String `db:"authors"`
+	ArticleID           int64       `db:"article_id" json:"article_id"`                       // updated to database value after call to insert
+	DataTime            time.Time   `db:"data_entry_time" json:"data_entry_time"`             //
+	Source              string      `db:"source" json:"source"`                               // source of the link (e.g. RSS, Reddit)
+	Host                string      `db:"host" json:"host"`                                   // host or vendor of article (e.g. www.cnn.com, www.espn.com)
+	Link                string      `db:"link" json:"link"`                                   // original url from the reference
+	SourcePublishedTime null.Time   `db:"source_published_time" json:"source_published_time"` // published time from source if given
+	PublishedTime       null.Time   `db:"published_time" json:"published_time"`               // best known published time
+	PublishedTimeSource null.String `db:"published_time_source" json:"published_time_source"` // where published_time came from (page or source)
+	SourceTitle         null.String `db:"source_title" json:"source_title"`
+	Title               null.String `db:"title" json:"title"`
+	CanonicalLink       null.String `db:"canonical_link" json:"canonical_link"`
+	Body                null.String `db:"body" json:"body"`
+	Authors             null.String `db:"authors" json:"authors"`
+	FeedID              null.Int    `db:"feed_id" json:"feed_id"`                     // catalog feed the link came from if any
+	Language            null.String `db:"language" json:"language"`                   // ISO 639-1 code detected from the body
+	ExtractionStatus    null.String `db:"extraction_status" json:"extraction_status"` // quality of the extracted body (e.g. ok, paywall)
+	FetchLink           null.String `db:"fetch_link" json:"fetch_link"`               // url that was fetched if it differs from link
+}
+
+// articleColumns are the NewsArticle columns stored in Article, in table order.
+// Selects list them instead of using * so the table's search column is left out.
+var articleColumns = []string{
+	"article_id", "data_entry_time", "source", "host", "link", "source_published_time", "published_time",
+	"source_title", "title", "canonical_link", "body", "authors", "feed_id", "language",
+	"published_time_source", "extraction_status", "fetch_link",
+}
+
+// ArticleColumns returns the NewsArticle columns stored in Article as a select list,
+// prefixed with the table alias if one is given.
+func ArticleColumns(alias string) string {
+	if len(alias) == 0 {
+		return strings.Join(articleColumns, ", ")
+	}
+
+	return alias + "." + strings.Join(articleColumns, ", "+alias+".")
 }
 
 // NewArticle parses data from newspaper3k and source into a standard article.
 func NewArticle(raw *Newspaper, source *Source) *Article {
+	crawlTime := time.Now()
+	sourcePublished, published, publishedSource := publishedTimes(raw.PubDate, source, crawlTime)
+
 	var article = Article{
-		DataTime:            time.Now(),
+		DataTime:            crawlTime,
 		Source:              source.Source,
 		Host:                host(source.Host, raw.Canonical),
 		Link:                source.Link,
-		SourcePublishedTime: sourcePublishedTime(source.PubDate),
-		PublishedTime:       publishedTime(raw.PubDate, source),
+		SourcePublishedTime: sourcePublished,
+		PublishedTime:       published,
+		PublishedTimeSource: publishedSource,
 		SourceTitle:         sourceTitle(source.Title),
 		Title:               title(raw.Title),
 		CanonicalLink:       canonicalLink(raw.Canonical),
 		Body:                body(raw.Text, source),
 		Authors:             authors(raw.Authors, source),
+		FeedID:              source.FeedID,
+		Language:            language(raw.Text),
+	}
+	if fetch := source.fetchLink(); fetch != source.Link {
+		article.FetchLink = null.StringFrom(fetch)
 	}
 
 	return &article
 }
 
-// FindArticle returns the article associated with the given URL
-func FindArticle(db *sqlx.DB, url string) *Article {
+// ErrArticleNotFound is returned when no article matches a lookup.
+var ErrArticleNotFound = errors.New("article not found")
+
+// FindArticle returns the article associated with the given URL.
+// Matches on the canonical form of the URL, or the raw URL for articles stored before canonicalization.
+// Returns ErrArticleNotFound if there is no matching article.
+func FindArticle(ctx context.Context, db *sqlx.DB, url string) (*Article, error) {
+	var selectStmt string = `SELECT ` + ArticleColumns("") + ` FROM NewsArticle
+							 WHERE link IN ($1, $2) OR canonical_link IN ($1, $2)
+							 ORDER BY article_id ASC
+							 LIMIT 1`
+
 	out := Article{}
-	err := db.Get(&out, "SELECT * FROM NewsArticle WHERE link=$1 OR canonical_link=$1", url)
-	if err != nil {
-		setup.LogCommon(err).
-			WithField("url", url).
-			Error("Failed Get statement")
+	err := db.GetContext(ctx, &out, selectStmt, Canonicalize(url), url)
+	if err == sql.ErrNoRows {
+		return nil, ErrArticleNotFound
+	} else if err != nil {
+		return nil, err
 	}
 
-	return &out
+	return &out, nil
 }
 
 // Attribute Setters
@@ -117,6 +163,11 @@ func canonicalLink(s string) null.String {
 	return title(s)
 }
 
+func language(s string) null.String {
+	code := langid.Detect(s)
+	return null.NewString(code, code != langid.Unknown)
+}
+
 func sourceTitle(s string) null.String {
 	// not any different than title currently
 	return title(s)
@@ -133,29 +184,6 @@ func title(s string) null.String {
 	return null.StringFromPtr(&clean)
 }
 
-func sourcePublishedTime(t *time.Time) null.Time {
-	return null.TimeFromPtr(t)
-}
-
-func publishedTime(t string, s *Source) null.Time {
-	// published time from newspaper is not reliable so pubDate may be null
-	// case where we know the time parse will not work, so return a null time
-	if len(t) != len("2006-01-02T15:04:05-07:00") {
-		return null.NewTime(time.Time{}, false)
-	}
-
-	// use actual time instead of placeholder
-	pubDate, err := time.Parse("2006-01-02T15:04:05-07:00", t)
-	if err != nil {
-		setup.LogCommon(err).
-			WithField("Link", s.Link).
-			WithField("pubDate", t).
-			Warn("Failed time.Parse")
-	}
-	// this constructor handles cases where pubDate is nil
-	return null.TimeFromPtr(&pubDate)
-}
-
 // Insert adds this article to the NewsArticle database table.
 // Performs no validation.
 // Updates articleID to real database value.
@@ -173,10 +201,15 @@ func (article *Article) Insert(db *sqlx.DB) {
 								title,					-- $7
 								canonical_link,			-- $8
 								body,					-- $9
-								authors					-- $10
+								authors,				-- $10
+								feed_id,				-- $11
+								language,				-- $12
+								published_time_source,	-- $13
+								extraction_status,		-- $14
+								fetch_link				-- $15
 								)`
 
-	var valueStmt string = `VALUES (DEFAULT, Now(), $1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	var valueStmt string = `VALUES (DEFAULT, Now(), $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`
 	var returnStmt string = "RETURNING article_id;"
 	var fullStmt string = insertStmt + " " + valueStmt + " " + returnStmt
 
@@ -195,7 +228,12 @@ func (article *Article) Insert(db *sqlx.DB) {
 		article.Title,               // $7
 		article.CanonicalLink,       // $8
 		article.Body,                // $9
-		article.Authors).            // $10
+		article.Authors,             // $10
+		article.FeedID,              // $11
+		article.Language,            // $12
+		article.PublishedTimeSource, // $13
+		article.ExtractionStatus,    // $14
+		article.FetchLink).          // $15
 		Scan(&id)
 
 	if err != nil {
@@ -0,0 +1,342 @@
+package news
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/wpwilson10/caterpillar/internal/setup"
+)
+
+// Author is a normalized article author in a format matching the NewsAuthor table schema.
+type Author struct {
+	AuthorID int64     `db:"author_id"`
+	DataTime time.Time `db:"data_entry_time"`
+	Name     string    `db:"name"`
+	NameKey  string    `db:"name_key"` // lower case name without punctuation, used to match authors
+}
+
+// words that mean a byline entry is not a person
+var authorStopWords = map[string]bool{
+	"admin": true, "administrator": true, "agency": true, "associated": true, "author": true,
+	"bureau": true, "com": true, "contributor": true, "contributors": true, "correspondent": true,
+	"desk": true, "digital": true, "editor": true, "editorial": true, "editors": true, "guest": true,
+	"inc": true, "llc": true, "media": true, "news": true, "newsroom": true, "online": true,
+	"press": true, "published": true, "reporter": true, "reporters": true, "reuters": true,
+	"services": true, "staff": true, "team": true, "the": true, "updated": true, "wire": true,
+	"wires": true, "writer": true, "www": true,
+}
+
+// separators between names in a combined byline, commas are handled by splitAuthorCommas
+var authorSeparators = regexp.MustCompile(`(?i)\s+and\s+|\s*[&;|/]\s*|\s+-\s+|\n`)
+
+// "and" between names, meaning commas in the byline separate names
+var authorAnd = regexp.MustCompile(`(?i)\s+and\s+|&`)
+
+// affiliations after a name, such as "For The Daily Mail" or "at CNN"
+var authorAffiliation = regexp.MustCompile(`(?i)\s+(for|at|of|from)\s+.*$`)
+
+// leading "By" in a byline
+var authorBy = regexp.MustCompile(`(?i)^by\s+`)
+
+// most words allowed in a name
+const maxAuthorWords = 4
+
+// NormalizeAuthors cleans a list of bylines into person names.
+// Combined bylines are split, outlet affiliations and titles are removed,
+// entries that are not people (e.g. Staff, Associated Press) are dropped, and duplicates are removed.
+func NormalizeAuthors(raw []string) []string {
+	out := []string{}
+	seen := make(map[string]bool)
+
+	for _, byline := range raw {
+		byline = strings.Join(strings.Fields(byline), " ")
+		byline = authorBy.ReplaceAllString(byline, "")
+		hasAnd := authorAnd.MatchString(byline)
+
+		names := []string{}
+		for _, part := range authorSeparators.Split(byline, -1) {
+			names = append(names, splitAuthorCommas(part, hasAnd)...)
+		}
+
+		for _, name := range names {
+			name = authorBy.ReplaceAllString(strings.TrimSpace(name), "")
+			name = authorAffiliation.ReplaceAllString(name, "")
+			name = strings.Trim(name, " .,:-")
+			if !isPersonName(name) {
+				continue
+			}
+
+			name = fixNameCase(name)
+			key := authorKey(name)
+			if !seen[key] {
+				seen[key] = true
+				out = append(out, name)
+			}
+		}
+	}
+
+	return out
+}
+
+// splitAuthorCommas splits a byline entry on commas when they separate names.
+// Commas separate names when the byline contains "and" (e.g. Jane Doe, John Smith and Bob Lee)
+// or when the parts have more than one word. Otherwise single word parts are a reversed name
+// (e.g. Smith, John) or a suffix such as an outlet or title (e.g. Jane Doe, Reuters).
+func splitAuthorCommas(entry string, hasAnd bool) []string {
+	parts := strings.Split(entry, ",")
+	if len(parts) == 1 || hasAnd {
+		return parts
+	}
+
+	out := []string{}
+	for _, p := range parts {
+		if len(strings.Fields(p)) > 1 {
+			out = append(out, p)
+		}
+	}
+
+	// last name, first name
+	if len(out) == 0 && len(parts) == 2 {
+		return []string{strings.TrimSpace(parts[1]) + " " + strings.TrimSpace(parts[0])}
+	}
+
+	return out
+}
+
+// isPersonName returns true if the value looks like a person's name.
+func isPersonName(name string) bool {
+	words := strings.Fields(name)
+	if len(words) < 2 || len(words) > maxAuthorWords {
+		return false
+	}
+
+	for _, w := range words {
+		if authorStopWords[strings.ToLower(strings.Trim(w, ".'"))] {
+			return false
+		}
+		for i, r := range w {
+			// names start with a letter and don't contain digits or address characters
+			if (i == 0 && !unicode.IsLetter(r)) || unicode.IsDigit(r) || r == '@' || r == ':' {
+				return false
+			}
+		}
+		// domains such as Dailymail.com
+		if i := strings.Index(w, "."); i > 0 && i < len(w)-1 && !strings.Contains(w[i+1:], ".") && len(w[i+1:]) > 1 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// fixNameCase title cases names that are all upper or all lower case.
+func fixNameCase(name string) string {
+	if name != strings.ToUpper(name) && name != strings.ToLower(name) {
+		return name
+	}
+
+	words := strings.Fields(strings.ToLower(name))
+	for i, w := range words {
+		runes := []rune(w)
+		runes[0] = unicode.ToUpper(runes[0])
+		words[i] = string(runes)
+	}
+
+	return strings.Join(words, " ")
+}
+
+// authorKey returns the lower case name with only letters, spaces, hyphens, and apostrophes.
+func authorKey(name string) string {
+	key := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || r == ' ' || r == '-' || r == '\'' {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+
+	return strings.Join(strings.Fields(key), " ")
+}
+
+// SaveAuthors normalizes the bylines and links the resulting authors to the article
+// in the NewsAuthor and ArticleAuthor tables. Returns the saved authors.
+func SaveAuthors(ctx context.Context, db *sqlx.DB, articleID int64, raw []string) ([]*Author, error) {
+	var authorStmt string = `INSERT INTO NewsAuthor (data_entry_time, name, name_key)
+							 VALUES (Now(), $1, $2)
+							 ON CONFLICT (name_key) DO UPDATE SET name_key = EXCLUDED.name_key
+							 RETURNING author_id`
+
+	var linkStmt string = `INSERT INTO ArticleAuthor (article_id, author_id, position)
+						   VALUES ($1, $2, $3)
+						   ON CONFLICT (article_id, author_id) DO NOTHING`
+
+	out := []*Author{}
+	for i, name := range NormalizeAuthors(raw) {
+		a := &Author{Name: name, NameKey: authorKey(name)}
+
+		err := db.GetContext(ctx, &a.AuthorID, authorStmt, a.Name, a.NameKey)
+		if err != nil {
+			return out, err
+		}
+
+		_, err = db.ExecContext(ctx, linkStmt, articleID, a.AuthorID, i)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, a)
+	}
+
+	return out, nil
+}
+
+// saveArticleAuthors saves the article's authors, logging on failure.
+func saveArticleAuthors(ctx context.Context, db *sqlx.DB, articleID int64, raw []string) {
+	_, err := SaveAuthors(ctx, db, articleID, raw)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("ArticleID", articleID).
+			WithField("authors", raw).
+			Error("Failed SaveAuthors")
+	}
+}
+
+// ArticlesByAuthor returns the articles written by the named author, newest first.
+func ArticlesByAuthor(ctx context.Context, db *sqlx.DB, name string) ([]*Article, error) {
+	var selectStmt string = `SELECT ` + ArticleColumns("a") + ` FROM NewsArticle a
+							 JOIN ArticleAuthor aa ON aa.article_id = a.article_id
+							 JOIN NewsAuthor au ON au.author_id = aa.author_id
+							 WHERE au.name_key = $1
+							 ORDER BY a.article_id DESC`
+
+	out := []*Article{}
+	err := db.SelectContext(ctx, &out, selectStmt, authorKey(name))
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// articleAuthors is the raw authors json for an article
+type articleAuthors struct {
+	ArticleID int64  `db:"article_id"`
+	Authors   string `db:"authors"`
+}
+
+// BackfillAuthors links authors to articles entered in the backfill's date range
+// from their authors json column, checkpointing after each batch.
+// The backfill's article count is the number of articles that were linked to at least one author.
+func BackfillAuthors(ctx context.Context, db *sqlx.DB, b *Backfill, batchSize int) error {
+	var selectStmt string = `SELECT article_id, authors FROM NewsArticle
+							 WHERE data_entry_time >= $1 AND data_entry_time < $2
+								AND article_id > $3
+								AND authors IS NOT NULL
+							 ORDER BY article_id ASC
+							 LIMIT $4`
+
+	return RunBackfill(ctx, db, b, batchSize, func(lastID int64, batchSize int) (int64, int64, int64, error) {
+		batch := []articleAuthors{}
+		err := db.SelectContext(ctx, &batch, selectStmt, b.RangeStart, b.RangeEnd, lastID, batchSize)
+		if err != nil || len(batch) == 0 {
+			return 0, 0, 0, err
+		}
+
+		var numLinked int64
+		for _, a := range batch {
+			raw := []string{}
+			err := json.Unmarshal([]byte(a.Authors), &raw)
+			if err != nil {
+				setup.LogCommon(err).
+					WithField("ArticleID", a.ArticleID).
+					Warn("Failed json.Unmarshal")
+				continue
+			}
+
+			authors, err := SaveAuthors(ctx, db, a.ArticleID, raw)
+			if err != nil {
+				return 0, 0, 0, err
+			}
+			if len(authors) > 0 {
+				numLinked = numLinked + 1
+			}
+		}
+
+		return batch[len(batch)-1].ArticleID, int64(len(batch)), numLinked, nil
+	})
+}
+
+// AuthorBackfillApp links normalized authors to articles entered in [from, to) in batches of batchSize.
+// Progress is saved in the NewsBackfill table so that running again with the same range resumes.
+func AuthorBackfillApp(from time.Time, to time.Time, batchSize int) {
+	if !from.Before(to) || batchSize < 1 {
+		setup.LogCommon(nil).
+			WithField("from", from).
+			WithField("to", to).
+			WithField("batch", batchSize).
+			Fatal("Invalid backfill range or batch size")
+	}
+
+	ctx := context.Background()
+	// connect to database
+	db, err := setup.SQL(ctx)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to SQL database")
+	}
+
+	b, err := LoadBackfill(ctx, db, "authors", from, to)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Failed LoadBackfill")
+	}
+	if b.CompletedTime.Valid {
+		fmt.Println("Backfill already complete", b.Name)
+	}
+
+	err = BackfillAuthors(ctx, db, b, batchSize)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("backfill", b.Name).
+			WithField("LastID", b.LastID).
+			Fatal("Failed BackfillAuthors")
+	}
+
+	// run summary
+	setup.LogCommon(nil).
+		WithField("backfill", b.Name).
+		WithField("NumProcessed", b.NumProcessed).
+		WithField("NumArticles", b.NumArticles).
+		WithField("RunTime", setup.RunTime().String()).
+		Info("RunSummary")
+}
+
+// AuthorArticlesApp prints the articles written by the named author.
+func AuthorArticlesApp(name string) {
+	ctx := context.Background()
+	// connect to database
+	db, err := setup.SQL(ctx)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to SQL database")
+	}
+
+	articles, err := ArticlesByAuthor(ctx, db, name)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("author", name).
+			Fatal("Failed ArticlesByAuthor")
+	}
+
+	for _, a := range articles {
+		fmt.Println(a.ArticleID, a.PublishedTime.Time.Format("2006-01-02"), a.Title.String, a.Link)
+	}
+
+	setup.LogCommon(nil).
+		WithField("author", name).
+		WithField("NumArticles", len(articles)).
+		WithField("RunTime", setup.RunTime().String()).
+		Info("RunSummary")
+}
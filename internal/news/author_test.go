@@ -0,0 +1,34 @@
+package news
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeAuthors(t *testing.T) {
+	tests := []struct {
+		raw  []string
+		want []string
+	}{
+		{[]string{"By Jane Doe"}, []string{"Jane Doe"}},
+		{[]string{"Jane Doe and John Smith"}, []string{"Jane Doe", "John Smith"}},
+		{[]string{"Jane Doe, John Smith"}, []string{"Jane Doe", "John Smith"}},
+		{[]string{"Jane Doe, John Smith and Bob Lee"}, []string{"Jane Doe", "John Smith", "Bob Lee"}},
+		{[]string{"Jane Doe, John Smith & Bob Lee"}, []string{"Jane Doe", "John Smith", "Bob Lee"}},
+		// a comma in a single name
+		{[]string{"Smith, John"}, []string{"John Smith"}},
+		{[]string{"Jane Doe, Reuters"}, []string{"Jane Doe"}},
+		{[]string{"Jane Doe, Jr."}, []string{"Jane Doe"}},
+		{[]string{"Jane Doe For The Daily Mail"}, []string{"Jane Doe"}},
+		{[]string{"JANE DOE", "jane doe"}, []string{"Jane Doe"}},
+		{[]string{"Associated Press", "Staff", "Dailymail.com Reporter", "Madonna"}, []string{}},
+		{[]string{"Jane Doe; John Smith | Bob Lee"}, []string{"Jane Doe", "John Smith", "Bob Lee"}},
+	}
+
+	for _, tt := range tests {
+		got := NormalizeAuthors(tt.raw)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("NormalizeAuthors(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package news
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/guregu/null.v3"
+
+	"github.com/wpwilson10/caterpillar/internal/redis"
+	"github.com/wpwilson10/caterpillar/internal/setup"
+)
+
+// Backfill stores the progress of a backfill in a format matching the NewsBackfill table schema.
+// A backfill with the same name and date range resumes from its last checkpoint.
+type Backfill struct {
+	Name          string    `db:"name"`
+	RangeStart    time.Time `db:"range_start"`
+	RangeEnd      time.Time `db:"range_end"`
+	LastID        int64     `db:"last_id"` // last source ID that was fully processed
+	NumProcessed  int64     `db:"num_processed"`
+	NumArticles   int64     `db:"num_articles"` // new articles added
+	StartTime     time.Time `db:"start_time"`
+	UpdatedTime   time.Time `db:"updated_time"`
+	CompletedTime null.Time `db:"completed_time"`
+}
+
+// LoadBackfill returns the saved progress of the named backfill over [from, to),
+// or a new backfill if it has not been started.
+func LoadBackfill(ctx context.Context, db *sqlx.DB, kind string, from time.Time, to time.Time) (*Backfill, error) {
+	name := fmt.Sprintf("%s:%s:%s", kind, from.Format("2006-01-02"), to.Format("2006-01-02"))
+
+	out := Backfill{}
+	err := db.GetContext(ctx, &out, "SELECT * FROM NewsBackfill WHERE name = $1", name)
+	if err == sql.ErrNoRows {
+		now := time.Now()
+		return &Backfill{Name: name, RangeStart: from, RangeEnd: to, StartTime: now, UpdatedTime: now}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// Save inserts or updates this backfill's checkpoint in the NewsBackfill table.
+func (b *Backfill) Save(ctx context.Context, db *sqlx.DB) error {
+	b.UpdatedTime = time.Now()
+
+	var insertStmt string = `INSERT INTO NewsBackfill (
+								name,
+								range_start,
+								range_end,
+								last_id,
+								num_processed,
+								num_articles,
+								start_time,
+								updated_time,
+								completed_time
+								)`
+
+	var valueStmt string = `VALUES (
+								:name,
+								:range_start,
+								:range_end,
+								:last_id,
+								:num_processed,
+								:num_articles,
+								:start_time,
+								:updated_time,
+								:completed_time
+								)`
+
+	var conflictStmt string = `ON CONFLICT (name) DO UPDATE SET
+								last_id = EXCLUDED.last_id,
+								num_processed = EXCLUDED.num_processed,
+								num_articles = EXCLUDED.num_articles,
+								updated_time = EXCLUDED.updated_time,
+								completed_time = EXCLUDED.completed_time`
+
+	_, err := db.NamedExecContext(ctx, insertStmt+" "+valueStmt+" "+conflictStmt, b)
+	return err
+}
+
+// BackfillBatch processes the next batch of up to batchSize items after lastID.
+// Returns the ID of the batch's last item, the number of items read, and the number of items
+// to add to the backfill's article count. Reading no items completes the backfill.
+type BackfillBatch func(lastID int64, batchSize int) (int64, int64, int64, error)
+
+// RunBackfill calls batch until the backfill is complete, checkpointing after each batch
+// so that running again resumes after the last fully processed batch.
+func RunBackfill(ctx context.Context, db *sqlx.DB, b *Backfill, batchSize int, batch BackfillBatch) error {
+	for !b.CompletedTime.Valid {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		lastID, numRead, numArticles, err := batch(b.LastID, batchSize)
+		if err != nil {
+			return err
+		}
+
+		// nothing left in the range
+		if numRead == 0 {
+			b.CompletedTime = null.TimeFrom(time.Now())
+			return b.Save(ctx, db)
+		}
+
+		// only checkpoint batches that were fully processed
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		b.LastID = lastID
+		b.NumProcessed = b.NumProcessed + numRead
+		b.NumArticles = b.NumArticles + numArticles
+		err = b.Save(ctx, db)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Backfill checkpoint", b.Name, b.LastID, b.NumProcessed, b.NumArticles)
+	}
+
+	return nil
+}
+
+// BackfillReddit gets missing articles from reddit submissions created in the backfill's date range
+// and links them to their submissions, checkpointing after each batch.
+func BackfillReddit(ctx context.Context, db *sqlx.DB, articleSet *redis.Set, blacklist *BlackList, robots *RobotsCache,
+	scheduler *Scheduler, b *Backfill, batchSize int) error {
+	return RunBackfill(ctx, db, b, batchSize, func(lastID int64, batchSize int) (int64, int64, int64, error) {
+		batch, err := newRedditArticles(ctx, db, b.RangeStart, b.RangeEnd, lastID, batchSize)
+		if err != nil || len(batch) == 0 {
+			return 0, 0, 0, err
+		}
+
+		// process the batch while being polite to each host
+		var numArticles int64
+		tasks := []CrawlTask{}
+		for _, r := range batch {
+			r := r
+			// skip links that are not urls
+			r.Link = strings.TrimSpace(r.Link)
+			if !setup.IsValidURL(r.Link) {
+				continue
+			}
+
+			source := NewSource(FromRedditArticle(r))
+			tasks = append(tasks, CrawlTask{
+				Host: source.Host,
+				Run: func() {
+					if redditNews(ctx, db, articleSet, blacklist, robots, source, r.SubmissionID) != nil {
+						atomic.AddInt64(&numArticles, 1)
+					}
+				},
+			})
+		}
+		robots.Prefetch(ctx, tasks)
+		scheduler.Run(ctx, tasks)
+
+		return batch[len(batch)-1].SubmissionID, int64(len(batch)), numArticles, nil
+	})
+}
+
+// RedditBackfillApp gets missing articles from reddit submissions created in [from, to) in batches of batchSize.
+// Progress is saved in the NewsBackfill table so that running again with the same range resumes.
+func RedditBackfillApp(from time.Time, to time.Time, batchSize int) {
+	if !from.Before(to) || batchSize < 1 {
+		setup.LogCommon(nil).
+			WithField("from", from).
+			WithField("to", to).
+			WithField("batch", batchSize).
+			Fatal("Invalid backfill range or batch size")
+	}
+
+	ctx := context.Background()
+	// connect to redis cache
+	client, err := setup.Redis(ctx)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to Redis")
+	}
+	articleSet := redis.NewSet(client, os.Getenv("NEWSPAPER_SET"))
+	// connect to database
+	db, err := setup.SQL(ctx)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to SQL database")
+	}
+	// setup blacklist of article links to avoid
+	blacklist, err := BlackListFromEnv(ctx, db)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Failed BlackListFromEnv")
+	}
+	// follow each host's robots.txt
+	robots := NewRobotsCacheFromEnv(client)
+	// crawl hosts in parallel while being polite to each host
+	scheduler := NewSchedulerFromEnv().WithHostDelay(robots.CrawlDelay)
+
+	b, err := LoadBackfill(ctx, db, "reddit", from, to)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Failed LoadBackfill")
+	}
+	if b.CompletedTime.Valid {
+		fmt.Println("Backfill already complete", b.Name)
+	}
+
+	err = BackfillReddit(ctx, db, articleSet, blacklist, robots, scheduler, b, batchSize)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("backfill", b.Name).
+			WithField("LastID", b.LastID).
+			Fatal("Failed BackfillReddit")
+	}
+
+	// run summary
+	blacklist.LogCounts()
+	setup.LogCommon(nil).
+		WithField("backfill", b.Name).
+		WithField("NumProcessed", b.NumProcessed).
+		WithField("NumArticles", b.NumArticles).
+		WithField("RunTime", setup.RunTime().String()).
+		Info("RunSummary")
+}
@@ -0,0 +1,71 @@
+package news
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestRunBackfill(t *testing.T) {
+	conn := &recordConn{}
+	db := sqlx.NewDb(sql.OpenDB(recordDriver{conn}), "postgres")
+	defer db.Close()
+
+	// three batches of ids 1-5, 6-10 and 11-12
+	b := &Backfill{Name: "test"}
+	calls := []int64{}
+	err := RunBackfill(context.Background(), db, b, 5, func(lastID int64, batchSize int) (int64, int64, int64, error) {
+		calls = append(calls, lastID)
+		n := int64(batchSize)
+		if lastID+n > 12 {
+			n = 12 - lastID
+		}
+		if n <= 0 {
+			return 0, 0, 0, nil
+		}
+		return lastID + n, n, 1, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(calls) != 4 || calls[0] != 0 || calls[1] != 5 || calls[2] != 10 || calls[3] != 12 {
+		t.Errorf("batches started after %v, want [0 5 10 12]", calls)
+	}
+	if b.LastID != 12 || b.NumProcessed != 12 || b.NumArticles != 3 || !b.CompletedTime.Valid {
+		t.Errorf("backfill = %+v", b)
+	}
+	// a checkpoint per batch and one on completion
+	if len(conn.queries) != 4 {
+		t.Errorf("saved %d times, want 4", len(conn.queries))
+	}
+}
+
+func TestRunBackfillStops(t *testing.T) {
+	conn := &recordConn{}
+	db := sqlx.NewDb(sql.OpenDB(recordDriver{conn}), "postgres")
+	defer db.Close()
+
+	// a failed batch is not checkpointed
+	b := &Backfill{Name: "test", LastID: 7}
+	failed := errors.New("failed")
+	err := RunBackfill(context.Background(), db, b, 5, func(lastID int64, batchSize int) (int64, int64, int64, error) {
+		return 0, 0, 0, failed
+	})
+	if err != failed || b.LastID != 7 || len(conn.queries) != 0 {
+		t.Errorf("err = %v, LastID = %d, saves = %d", err, b.LastID, len(conn.queries))
+	}
+
+	// a batch interrupted by cancellation is not checkpointed
+	ctx, cancel := context.WithCancel(context.Background())
+	err = RunBackfill(ctx, db, b, 5, func(lastID int64, batchSize int) (int64, int64, int64, error) {
+		cancel()
+		return lastID + 5, 5, 5, nil
+	})
+	if err != context.Canceled || b.LastID != 7 || b.NumProcessed != 0 || len(conn.queries) != 0 {
+		t.Errorf("err = %v, LastID = %d, NumProcessed = %d, saves = %d", err, b.LastID, b.NumProcessed, len(conn.queries))
+	}
+}
@@ -1,20 +1,66 @@
 package news
 
 import (
+	"context"
 	"encoding/csv"
+	"fmt"
 	"io"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/net/publicsuffix"
+	"gopkg.in/guregu/null.v3"
 
 	"github.com/wpwilson10/caterpillar/internal/setup"
 )
 
-// BlackList is a list of hosts that should be excluded from the NewsArticle database.
+// Blacklist rule types
+const (
+	BlockHost   = "host"   // exact hostname, the default when no type is given
+	BlockDomain = "domain" // registrable domain and all of its subdomains (e.g. example.co.uk)
+	BlockSuffix = "suffix" // hostname suffix (e.g. *.example.com or .example.com)
+	BlockPath   = "path"   // url path prefix on any host (e.g. /video/)
+	BlockRegex  = "regex"  // regular expression matched against the full url
+)
+
+// how often the blacklist file is checked for changes
+const blacklistReloadInterval = 30 * time.Second
+
+// BlackListRule is a single rule for links that should be excluded from the NewsArticle database.
+type BlackListRule struct {
+	RuleType string    `db:"rule_type"`
+	Value    string    `db:"value"`
+	Reason   string    `db:"reason"`
+	Expires  null.Time `db:"expires"` // rule is ignored after this time, null for never
+	re       *regexp.Regexp
+	hits     *uint64 // number of links blocked by this rule
+}
+
+// String describes the rule for logging and reports.
+func (r *BlackListRule) String() string {
+	return r.RuleType + ":" + r.Value
+}
+
+// BlackList is a list of rules for links that should be excluded from the NewsArticle database.
+// Rules loaded from a file are reloaded when the file changes.
 type BlackList struct {
-	blacklist map[string]int
+	mu        sync.RWMutex
+	rules     []*BlackListRule
+	path      string    // file the rules came from, empty for database rules
+	modTime   time.Time // file modification time when last loaded
+	lastCheck time.Time // last time the file was checked for changes
 }
 
-// NewBlackList creates a list of hosts that should be excluded from the NewsArticle database.
+// NewBlackList creates a blacklist from the file at NEWSPAPER_BLACKLIST_FILEPATH.
+// Each csv record is value[,type[,reason[,expires]]], where a record with only a value is a host rule.
+// Records from older files whose second column is not a rule type are also host rules.
 func NewBlackList() *BlackList {
 	// get filepath
 	absPath, err := filepath.Abs(os.Getenv("NEWSPAPER_BLACKLIST_FILEPATH"))
@@ -22,17 +68,181 @@ func NewBlackList() *BlackList {
 		setup.LogCommon(err).Fatal("Filepath")
 	}
 
+	b := &BlackList{path: absPath}
+	err = b.reload()
+	if err != nil {
+		setup.LogCommon(err).Fatal("Read csv")
+	}
+
+	return b
+}
+
+// BlackListFromEnv creates a blacklist from the file at NEWSPAPER_BLACKLIST_FILEPATH if it is set,
+// otherwise from the NewsBlackList table.
+func BlackListFromEnv(ctx context.Context, db *sqlx.DB) (*BlackList, error) {
+	if len(os.Getenv("NEWSPAPER_BLACKLIST_FILEPATH")) > 0 {
+		return NewBlackList(), nil
+	}
+
+	return NewBlackListFromDB(ctx, db)
+}
+
+// NewBlackListFromDB creates a blacklist from the rules in the NewsBlackList table.
+func NewBlackListFromDB(ctx context.Context, db *sqlx.DB) (*BlackList, error) {
+	rules := []*BlackListRule{}
+	// columns are nullable, rules without a value match nothing and are skipped
+	var selectStmt string = `SELECT COALESCE(rule_type, 'host') AS rule_type, value, COALESCE(reason, '') AS reason, expires
+							 FROM NewsBlackList
+							 WHERE value IS NOT NULL AND trim(value) <> ''`
+
+	err := db.SelectContext(ctx, &rules, selectStmt)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &BlackList{}
+	b.rules, err = prepareRules(rules, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Match returns the first unexpired rule that blocks the given link.
+func (b *BlackList) Match(link string) (*BlackListRule, bool) {
+	b.checkReload()
+
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, false
+	}
+	host := strings.ToLower(u.Hostname())
+	now := time.Now()
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, r := range b.rules {
+		if r.Expires.Valid && now.After(r.Expires.Time) {
+			continue
+		}
+		if r.matches(link, host, u.Path) {
+			atomic.AddUint64(r.hits, 1)
+			return r, true
+		}
+	}
+
+	return nil, false
+}
+
+// Counts returns the number of links blocked by each rule that has blocked at least one link.
+func (b *BlackList) Counts() map[*BlackListRule]uint64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	out := make(map[*BlackListRule]uint64)
+	for _, r := range b.rules {
+		if n := atomic.LoadUint64(r.hits); n > 0 {
+			out[r] = n
+		}
+	}
+
+	return out
+}
+
+// LogCounts logs the number of links blocked by each rule.
+func (b *BlackList) LogCounts() {
+	for r, n := range b.Counts() {
+		setup.LogCommon(nil).
+			WithField("rule", r.String()).
+			WithField("reason", r.Reason).
+			WithField("count", n).
+			Info("BlackList blocked")
+	}
+}
+
+// matches returns true if the rule blocks the link with the given host and path.
+func (r *BlackListRule) matches(link string, host string, path string) bool {
+	switch r.RuleType {
+	case BlockHost:
+		return host == r.Value
+	case BlockDomain:
+		domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+		return err == nil && domain == r.Value
+	case BlockSuffix:
+		return host == r.Value || strings.HasSuffix(host, "."+r.Value)
+	case BlockPath:
+		return strings.HasPrefix(strings.ToLower(path), r.Value)
+	case BlockRegex:
+		return r.re.MatchString(link)
+	}
+
+	return false
+}
+
+// checkReload reloads the rules if the file has changed, checking at most every blacklistReloadInterval.
+func (b *BlackList) checkReload() {
+	if len(b.path) == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	due := time.Since(b.lastCheck) >= blacklistReloadInterval
+	if due {
+		b.lastCheck = time.Now()
+	}
+	b.mu.Unlock()
+	if !due {
+		return
+	}
+
+	info, err := os.Stat(b.path)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("filepath", b.path).
+			Warn("Failed Stat")
+		return
+	}
+
+	b.mu.RLock()
+	changed := !info.ModTime().Equal(b.modTime)
+	b.mu.RUnlock()
+
+	if changed {
+		err = b.reload()
+		if err != nil {
+			// keep using the old rules
+			setup.LogCommon(err).
+				WithField("filepath", b.path).
+				Error("Failed blacklist reload")
+		} else {
+			setup.LogCommon(nil).
+				WithField("filepath", b.path).
+				Info("Reloaded blacklist")
+		}
+	}
+}
+
+// reload reads the rules from the blacklist file, keeping counts for rules that still exist.
+func (b *BlackList) reload() error {
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return err
+	}
+
 	// Open the file
-	csvfile, err := os.Open(absPath)
+	csvfile, err := os.Open(b.path)
 	if err != nil {
-		setup.LogCommon(err).Fatal("Open csv")
+		return err
 	}
+	defer csvfile.Close()
 
-	// Parse the file
+	// Parse the file, records may have one to four fields
 	r := csv.NewReader(csvfile)
+	r.FieldsPerRecord = -1
 
-	m := make(map[string]int)
-
+	rules := []*BlackListRule{}
 	// Iterate through the records
 	for {
 		// Read each record from csv
@@ -41,24 +251,124 @@ func NewBlackList() *BlackList {
 			break
 		}
 		if err != nil {
-			setup.LogCommon(err).Fatal("Read csv")
+			return err
+		}
+
+		rule, err := ruleFromRecord(record)
+		if err != nil {
+			return err
+		}
+		if rule != nil {
+			rules = append(rules, rule)
 		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rules, err = prepareRules(rules, b.rules)
+	if err != nil {
+		return err
+	}
+	b.modTime = info.ModTime()
+	b.lastCheck = time.Now()
+
+	return nil
+}
 
-		// add to list
-		if len(record) > 0 {
-			m[record[0]] = 1
+// ruleFromRecord parses a value[,type[,reason[,expires]]] csv record.
+// Older blacklist files only used the first column, so a record whose second column
+// is not a rule type is read the old way, as a host rule.
+// Returns nil for empty records.
+func ruleFromRecord(record []string) (*BlackListRule, error) {
+	if len(record) == 0 || len(strings.TrimSpace(record[0])) == 0 {
+		return nil, nil
+	}
+
+	rule := &BlackListRule{RuleType: BlockHost, Value: record[0]}
+	if len(record) > 1 && len(strings.TrimSpace(record[1])) > 0 {
+		if !isRuleType(record[1]) {
+			setup.LogCommon(nil).
+				WithField("record", strings.Join(record, ",")).
+				Warn("Blacklist record has no rule type, using host")
+			return rule, nil
 		}
+		rule.RuleType = record[1]
+	}
+	if len(record) > 2 {
+		rule.Reason = strings.TrimSpace(record[2])
+	}
+	if len(record) > 3 && len(strings.TrimSpace(record[3])) > 0 {
+		expires, err := parseExpires(strings.TrimSpace(record[3]))
+		if err != nil {
+			return nil, err
+		}
+		rule.Expires = null.TimeFrom(expires)
 	}
 
-	// put map in blacklist
-	blacklist := BlackList{blacklist: m}
+	return rule, nil
+}
+
+// isRuleType returns true if the value names a blacklist rule type.
+func isRuleType(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case BlockHost, BlockDomain, BlockSuffix, BlockPath, BlockRegex:
+		return true
+	}
 
-	return &blacklist
+	return false
 }
 
-// IsBlackListed returns true if the given host is in the blacklist.
-// False otherwise.
-func (b *BlackList) IsBlackListed(host string) bool {
-	_, prs := b.blacklist[host]
-	return prs
+// parseExpires accepts a date or an RFC 3339 time.
+func parseExpires(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
+
+// prepareRules normalizes rule values, compiles regexes, and carries over hit counts from old rules.
+func prepareRules(rules []*BlackListRule, old []*BlackListRule) ([]*BlackListRule, error) {
+	// previous counts by rule
+	counts := make(map[string]*uint64)
+	for _, r := range old {
+		counts[r.String()] = r.hits
+	}
+
+	for _, r := range rules {
+		r.RuleType = strings.ToLower(strings.TrimSpace(r.RuleType))
+		r.Value = strings.TrimSpace(r.Value)
+
+		switch r.RuleType {
+		case BlockHost:
+			r.Value = strings.ToLower(r.Value)
+		case BlockDomain:
+			domain, err := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(r.Value))
+			if err != nil {
+				return nil, fmt.Errorf("blacklist domain %s: %w", r.Value, err)
+			}
+			r.Value = domain
+		case BlockSuffix:
+			r.Value = strings.TrimLeft(strings.TrimPrefix(strings.ToLower(r.Value), "*"), ".")
+		case BlockPath:
+			r.Value = strings.ToLower(r.Value)
+		case BlockRegex:
+			re, err := regexp.Compile(r.Value)
+			if err != nil {
+				return nil, fmt.Errorf("blacklist regex %s: %w", r.Value, err)
+			}
+			r.re = re
+		default:
+			return nil, fmt.Errorf("unknown blacklist rule type %s", r.RuleType)
+		}
+
+		if hits, ok := counts[r.String()]; ok {
+			r.hits = hits
+		} else {
+			r.hits = new(uint64)
+		}
+	}
+
+	return rules, nil
 }
@@ -0,0 +1,102 @@
+package news
+
+import (
+	"testing"
+)
+
+func TestRuleFromRecord(t *testing.T) {
+	tests := []struct {
+		name   string
+		record []string
+		want   string // rule type and value, empty for no rule
+		reason string
+		err    bool
+	}{
+		{"empty", []string{}, "", "", false},
+		{"blank value", []string{" ", "domain"}, "", "", false},
+		{"value only", []string{"www.example.com"}, "host:www.example.com", "", false},
+		{"empty type", []string{"www.example.com", " "}, "host:www.example.com", "", false},
+		{"typed", []string{"example.co.uk", "domain", " spam "}, "domain:example.co.uk", "spam", false},
+		{"typed upper", []string{"/video/", " PATH "}, " PATH :/video/", "", false},
+		{"legacy second column", []string{"www.example.com", "sports site"}, "host:www.example.com", "", false},
+		{"expires date", []string{"www.example.com", "host", "", "2030-01-02"}, "host:www.example.com", "", false},
+		{"expires time", []string{"www.example.com", "host", "", "2030-01-02T03:04:05Z"}, "host:www.example.com", "", false},
+		{"bad expires", []string{"www.example.com", "host", "", "soon"}, "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ruleFromRecord(tt.record)
+			if (err != nil) != tt.err {
+				t.Fatalf("error = %v, want error %v", err, tt.err)
+			}
+			got := ""
+			if rule != nil {
+				got = rule.String()
+				if rule.Reason != tt.reason {
+					t.Errorf("Reason = %q, want %q", rule.Reason, tt.reason)
+				}
+			}
+			if got != tt.want {
+				t.Errorf("rule = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlackListMatch(t *testing.T) {
+	rules := []*BlackListRule{
+		{RuleType: BlockHost, Value: "WWW.Blocked.com"},
+		{RuleType: BlockDomain, Value: "news.example.co.uk"},
+		{RuleType: BlockSuffix, Value: "*.ads.net"},
+		{RuleType: BlockPath, Value: "/Video/"},
+		{RuleType: BlockRegex, Value: `/live-updates?/`},
+		{RuleType: BlockHost, Value: "old.example.com", Reason: "expired"},
+	}
+	rules[5].Expires.Valid = true
+
+	b := &BlackList{}
+	var err error
+	b.rules, err = prepareRules(rules, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		link string
+		want string
+	}{
+		{"https://www.blocked.com/story", "host:www.blocked.com"},
+		{"https://blocked.com/story", ""},
+		{"https://sport.example.co.uk/story", "domain:example.co.uk"},
+		{"https://example.com/story", ""},
+		{"https://ads.net/story", "suffix:ads.net"},
+		{"https://cdn.ads.net/story", "suffix:ads.net"},
+		{"https://badads.net/story", ""},
+		{"https://www.example.com/video/clip", "path:/video/"},
+		{"https://www.example.com/news/video/clip", ""},
+		{"https://www.example.com/2020/live-update/story", "regex:/live-updates?/"},
+		{"https://old.example.com/story", ""},
+	}
+
+	for _, tt := range tests {
+		got := ""
+		if rule, ok := b.Match(tt.link); ok {
+			got = rule.String()
+		}
+		if got != tt.want {
+			t.Errorf("Match(%q) = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+
+	if n := b.Counts()[b.rules[2]]; n != 2 {
+		t.Errorf("suffix rule count = %d, want 2", n)
+	}
+}
+
+func TestPrepareRulesUnknownType(t *testing.T) {
+	_, err := prepareRules([]*BlackListRule{{RuleType: "host", Value: "a.com"}, {RuleType: "domian", Value: "b.com"}}, nil)
+	if err == nil {
+		t.Error("expected error for unknown rule type")
+	}
+}
@@ -0,0 +1,237 @@
+package news
+
+import (
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gocarina/gocsv"
+
+	"github.com/wpwilson10/caterpillar/internal/setup"
+)
+
+// Canonical rule actions
+const (
+	RuleStrip = "strip" // remove a query parameter, a trailing * matches a prefix (e.g. utm_*)
+	RuleAlias = "alias" // replace the host with the rule's value (e.g. m.cnn.com -> www.cnn.com)
+	RuleAMP   = "amp"   // remove a path segment used for AMP pages (e.g. amp)
+)
+
+// CanonicalRule is a single url canonicalization rule for a host.
+// Strip and amp rules match the exact host and its subdomains, use * to match all hosts.
+// Alias rules match the exact host only, since a subdomain is usually a different site.
+type CanonicalRule struct {
+	Host   string `csv:"Host"`
+	Action string `csv:"Action"`
+	Value  string `csv:"Value"`
+}
+
+// defaultCanonicalRules apply to every host in addition to the configured rules.
+var defaultCanonicalRules = []CanonicalRule{
+	{Host: "*", Action: RuleStrip, Value: "utm_*"},
+	{Host: "*", Action: RuleStrip, Value: "fbclid"},
+	{Host: "*", Action: RuleStrip, Value: "gclid"},
+	{Host: "*", Action: RuleStrip, Value: "dclid"},
+	{Host: "*", Action: RuleStrip, Value: "igshid"},
+	{Host: "*", Action: RuleStrip, Value: "mc_cid"},
+	{Host: "*", Action: RuleStrip, Value: "mc_eid"},
+	{Host: "*", Action: RuleStrip, Value: "_ga"},
+	{Host: "*", Action: RuleStrip, Value: "ocid"},
+	{Host: "*", Action: RuleStrip, Value: "cmpid"},
+	{Host: "*", Action: RuleStrip, Value: "smid"},
+	{Host: "*", Action: RuleStrip, Value: "ref_src"},
+	{Host: "*", Action: RuleStrip, Value: "amp"},
+	{Host: "*", Action: RuleAMP, Value: "amp"},
+}
+
+// mobilePrefixes are host prefixes for mobile and AMP versions of a site.
+// Hosts starting with these are mapped to www unless an alias rule exists.
+var mobilePrefixes = []string{"m.", "mobile.", "amp."}
+
+// Canonicalizer turns urls into a standard form so the same article
+// is recognized regardless of tracking parameters, fragments, or mobile hosts.
+type Canonicalizer struct {
+	rules []CanonicalRule
+}
+
+// NewCanonicalizer creates a Canonicalizer using the default rules plus the given rules.
+func NewCanonicalizer(rules []CanonicalRule) *Canonicalizer {
+	all := append([]CanonicalRule{}, defaultCanonicalRules...)
+	for _, r := range rules {
+		r.Host = strings.ToLower(strings.TrimSpace(r.Host))
+		r.Action = strings.ToLower(strings.TrimSpace(r.Action))
+		r.Value = strings.TrimSpace(r.Value)
+		all = append(all, r)
+	}
+
+	return &Canonicalizer{rules: all}
+}
+
+// the canonicalizer used by Canonicalize, loaded on first use
+var (
+	canonicalOnce sync.Once
+	canonical     *Canonicalizer
+)
+
+// Canonicalize returns the standard form of the given url using the rules
+// from the file at NEWSPAPER_CANONICAL_FILEPATH, if configured.
+// Use the result for all article dedupe keys.
+func Canonicalize(link string) string {
+	canonicalOnce.Do(func() {
+		canonical = NewCanonicalizer(canonicalRulesFromFile())
+	})
+
+	return canonical.Canonicalize(link)
+}
+
+// Canonicalize returns the standard form of the given url.
+// Links that cannot be parsed are returned trimmed but otherwise unchanged.
+func (c *Canonicalizer) Canonicalize(link string) string {
+	link = strings.TrimSpace(link)
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" {
+		return link
+	}
+
+	// url.Parse already lowercases the scheme
+	u.Host = c.host(u)
+
+	// clean up the path
+	u.Path = c.path(u.Host, u.Path)
+	u.RawPath = ""
+
+	// remove tracking parameters, Encode also sorts the remaining keys
+	u.RawQuery = c.query(u.Host, u.Query()).Encode()
+	u.ForceQuery = false
+
+	// fragments never change the article
+	u.Fragment = ""
+
+	return u.String()
+}
+
+// host returns the lowercase host without default ports and with aliases applied.
+func (c *Canonicalizer) host(u *url.URL) string {
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+
+	// explicit aliases take priority
+	for _, r := range c.rules {
+		if r.Action == RuleAlias && r.Host == host && len(r.Value) > 0 {
+			host = strings.ToLower(r.Value)
+			break
+		}
+	}
+
+	// then map common mobile hosts to the main site
+	for _, p := range mobilePrefixes {
+		if strings.HasPrefix(host, p) && strings.Count(host, ".") > 1 {
+			host = "www." + strings.TrimPrefix(host, p)
+			break
+		}
+	}
+
+	// drop ports that match the scheme
+	if port == "" || (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		return host
+	}
+
+	return net.JoinHostPort(host, port)
+}
+
+// path removes AMP markers and trailing slashes.
+func (c *Canonicalizer) path(host string, path string) string {
+	segments := strings.Split(path, "/")
+	out := []string{}
+	for _, s := range segments {
+		if !c.isAMP(host, s) {
+			out = append(out, s)
+		}
+	}
+
+	// pages like /story.amp or /story.amp.html
+	if last := len(out) - 1; last >= 0 {
+		if strings.HasSuffix(out[last], ".amp") {
+			out[last] = strings.TrimSuffix(out[last], ".amp")
+		} else if strings.Contains(out[last], ".amp.") {
+			out[last] = strings.Replace(out[last], ".amp.", ".", 1)
+		}
+	}
+
+	clean := strings.Join(out, "/")
+	// treat /story/ and /story as the same page
+	return strings.TrimRight(clean, "/")
+}
+
+// isAMP returns true if the path segment marks an AMP page for the host.
+func (c *Canonicalizer) isAMP(host string, segment string) bool {
+	for _, r := range c.rules {
+		if r.Action == RuleAMP && matchHost(r.Host, host) && strings.EqualFold(r.Value, segment) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// query removes stripped parameters from the query values.
+func (c *Canonicalizer) query(host string, values url.Values) url.Values {
+	for key := range values {
+		for _, r := range c.rules {
+			if r.Action == RuleStrip && matchHost(r.Host, host) && matchParam(r.Value, key) {
+				values.Del(key)
+				break
+			}
+		}
+	}
+
+	return values
+}
+
+// matchHost returns true if the rule host applies to the given host.
+func matchHost(ruleHost string, host string) bool {
+	return ruleHost == "*" || ruleHost == host || strings.HasSuffix(host, "."+ruleHost)
+}
+
+// matchParam returns true if the rule value matches the query parameter name.
+func matchParam(value string, key string) bool {
+	key = strings.ToLower(key)
+	value = strings.ToLower(value)
+	if strings.HasSuffix(value, "*") {
+		return strings.HasPrefix(key, strings.TrimSuffix(value, "*"))
+	}
+
+	return key == value
+}
+
+// canonicalRulesFromFile loads rules from the file at NEWSPAPER_CANONICAL_FILEPATH.
+// Returns no rules if the file is not configured.
+func canonicalRulesFromFile() []CanonicalRule {
+	filepath := os.Getenv("NEWSPAPER_CANONICAL_FILEPATH")
+	if len(filepath) == 0 {
+		return nil
+	}
+
+	// Get the file
+	ruleFile, err := os.Open(filepath)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("filepath", filepath).
+			Error("Open")
+		return nil
+	}
+	defer ruleFile.Close()
+
+	// Read from file to array
+	rules := []CanonicalRule{}
+	if err := gocsv.UnmarshalFile(ruleFile, &rules); err != nil {
+		setup.LogCommon(err).
+			WithField("filepath", filepath).
+			Error("UnmarshalFile")
+		return nil
+	}
+
+	return rules
+}
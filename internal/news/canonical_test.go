@@ -0,0 +1,85 @@
+package news
+
+import (
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+)
+
+func TestCanonicalize(t *testing.T) {
+	c := NewCanonicalizer([]CanonicalRule{
+		{Host: " Edition.CNN.com ", Action: " ALIAS ", Value: "www.cnn.com"},
+		{Host: "example.com", Action: RuleStrip, Value: "session"},
+		{Host: "news.example.org", Action: RuleAMP, Value: "mobile"},
+	})
+
+	tests := []struct {
+		name string
+		link string
+		want string
+	}{
+		{"unchanged", "https://www.example.com/story", "https://www.example.com/story"},
+		{"whitespace", "  https://www.example.com/story \n", "https://www.example.com/story"},
+		{"unparsable", " not a url ", "not a url"},
+		{"no host", "/relative/story", "/relative/story"},
+		{"lowercase host", "https://WWW.Example.COM/Story", "https://www.example.com/Story"},
+		{"default https port", "https://www.example.com:443/story", "https://www.example.com/story"},
+		{"default http port", "http://www.example.com:80/story", "http://www.example.com/story"},
+		{"other port", "https://www.example.com:8443/story", "https://www.example.com:8443/story"},
+		{"fragment", "https://www.example.com/story#comments", "https://www.example.com/story"},
+		{"trailing slash", "https://www.example.com/story/", "https://www.example.com/story"},
+		{"tracking params", "https://www.example.com/story?utm_source=x&utm_medium=y&fbclid=z", "https://www.example.com/story"},
+		{"sorted params", "https://www.example.com/story?page=2&id=1&gclid=x", "https://www.example.com/story?id=1&page=2"},
+		{"empty query", "https://www.example.com/story?", "https://www.example.com/story"},
+		{"host strip rule", "https://example.com/story?session=1&id=2", "https://example.com/story?id=2"},
+		{"host strip rule subdomain", "https://www.example.com/story?session=1", "https://www.example.com/story"},
+		{"host strip rule other host", "https://www.example.org/story?session=1", "https://www.example.org/story?session=1"},
+		{"amp segment", "https://www.example.com/amp/story", "https://www.example.com/story"},
+		{"amp suffix", "https://www.example.com/story.amp", "https://www.example.com/story"},
+		{"amp extension", "https://www.example.com/story.amp.html", "https://www.example.com/story.html"},
+		{"host amp rule", "https://news.example.org/mobile/story", "https://news.example.org/story"},
+		{"host amp rule other host", "https://www.example.org/mobile/story", "https://www.example.org/mobile/story"},
+		{"mobile host", "https://m.example.com/story", "https://www.example.com/story"},
+		{"mobile host prefix", "https://mobile.example.com/story", "https://www.example.com/story"},
+		{"mobile domain", "https://m.com/story", "https://m.com/story"},
+		{"alias", "https://edition.cnn.com/story", "https://www.cnn.com/story"},
+		{"alias exact host only", "https://us.edition.cnn.com/story", "https://us.edition.cnn.com/story"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Canonicalize(tt.link); got != tt.want {
+				t.Errorf("Canonicalize(%q) = %q, want %q", tt.link, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSourceFetchesOriginalLink(t *testing.T) {
+	link := " https://m.example.com/amp/story.amp?utm_source=feed "
+	s := NewSource(FromFeed(&gofeed.Item{Title: "Story", Link: link}))
+
+	if want := "https://www.example.com/story"; s.Link != want {
+		t.Errorf("Link = %q, want %q", s.Link, want)
+	}
+	if want := "https://m.example.com/amp/story.amp?utm_source=feed"; s.fetchLink() != want {
+		t.Errorf("fetchLink() = %q, want %q", s.fetchLink(), want)
+	}
+	if s.Host != "www.example.com" {
+		t.Errorf("Host = %q, want %q", s.Host, "www.example.com")
+	}
+
+	// sources made without an original link fetch their stored link
+	stored := &Source{Link: "https://www.example.com/story"}
+	if stored.fetchLink() != stored.Link {
+		t.Errorf("fetchLink() = %q, want %q", stored.fetchLink(), stored.Link)
+	}
+
+	// articles keep the fetched link for recrawls only when it differs from the stored link
+	if a := NewArticle(&Newspaper{}, s); a.FetchLink.String != s.fetchLink() {
+		t.Errorf("FetchLink = %v, want %q", a.FetchLink, s.fetchLink())
+	}
+	if a := NewArticle(&Newspaper{}, stored); a.FetchLink.Valid {
+		t.Errorf("FetchLink = %v, want null", a.FetchLink)
+	}
+}
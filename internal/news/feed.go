@@ -0,0 +1,317 @@
+package news
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gocarina/gocsv"
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/guregu/null.v3"
+
+	"github.com/wpwilson10/caterpillar/internal/setup"
+)
+
+// Feed types in the feed catalog
+const (
+	FeedRSS     = "rss"     // rss or atom feed
+	FeedSitemap = "sitemap" // sitemap.xml, sitemap index, or Google News sitemap
+)
+
+// Feed is an rss feed or sitemap in the feed catalog in a format matching the NewsFeed table schema.
+type Feed struct {
+	FeedID       int64       `db:"feed_id"`         // updated to database value after call to save
+	DataTime     null.Time   `db:"data_entry_time"` // when the feed was added
+	Name         string      `db:"name"`
+	Link         null.String `db:"link"` // website of the feed's publisher
+	RSS          string      `db:"rss"`  // rss feed or sitemap url
+	FeedType     string      `db:"feed_type"`
+	Category     null.String `db:"category"`
+	Language     null.String `db:"language"` // ISO 639-1 code (e.g. en)
+	Region       null.String `db:"region"`   // ISO 3166-1 alpha-2 code (e.g. US)
+	Enabled      bool        `db:"enabled"`
+	PollInterval int         `db:"poll_interval"` // minimum minutes between polls, zero for every run
+}
+
+// ErrFeedNotFound is returned when no feed matches a lookup.
+var ErrFeedNotFound = errors.New("feed not found")
+
+// NewFeed creates an enabled feed. Empty optional values are stored as null.
+func NewFeed(name string, link string, rss string, category string) *Feed {
+	return &Feed{
+		DataTime: null.TimeFrom(time.Now()),
+		Name:     strings.TrimSpace(name),
+		Link:     optionalString(link),
+		RSS:      strings.TrimSpace(rss),
+		FeedType: FeedRSS,
+		Category: optionalString(category),
+		Enabled:  true,
+	}
+}
+
+// optionalString returns a null string for empty values.
+func optionalString(s string) null.String {
+	clean := strings.TrimSpace(s)
+	return null.NewString(clean, len(clean) > 0)
+}
+
+// LoadFeeds returns the enabled feeds of the given type in the NewsFeed table.
+// Feeds in languages that are not allowed for the feed's host are skipped, see HostLanguageAllowed.
+// Null columns are read as their table defaults.
+func LoadFeeds(ctx context.Context, db *sqlx.DB, feedType string) ([]*Feed, error) {
+	var selectStmt string = `SELECT feed_id, data_entry_time, COALESCE(name, '') AS name, link, rss,
+								COALESCE(feed_type, 'rss') AS feed_type, category, language, region,
+								COALESCE(enabled, true) AS enabled, COALESCE(poll_interval, 0) AS poll_interval
+							 FROM NewsFeed
+							 WHERE COALESCE(enabled, true) AND COALESCE(feed_type, 'rss') = $1
+							 ORDER BY feed_id ASC`
+
+	feeds := []*Feed{}
+	err := db.SelectContext(ctx, &feeds, selectStmt, feedType)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []*Feed{}
+	for _, f := range feeds {
+		if HostLanguageAllowed(linkHost(f.RSS), f.Language.String) {
+			out = append(out, f)
+		}
+	}
+
+	return out, nil
+}
+
+// Due returns true if the feed should be polled at the given time.
+// Waits for both the feed's poll interval and any failure backoff in its state.
+func (f *Feed) Due(state *FeedState, now time.Time) bool {
+	if f.PollInterval > 0 && state.LastAttemptTime.Valid {
+		next := state.LastAttemptTime.Time.Add(time.Duration(f.PollInterval) * time.Minute)
+		if now.Before(next) {
+			return false
+		}
+	}
+
+	return state.Due(now)
+}
+
+// Save inserts this feed into the NewsFeed table, or updates it if the rss url already exists.
+// Updates FeedID to the real database value.
+func (f *Feed) Save(ctx context.Context, db *sqlx.DB) error {
+	var insertStmt string = `INSERT INTO NewsFeed (
+								data_entry_time,
+								name,
+								link,
+								rss,
+								feed_type,
+								category,
+								language,
+								region,
+								enabled,
+								poll_interval
+								)`
+
+	var valueStmt string = `VALUES (
+								:data_entry_time,
+								:name,
+								:link,
+								:rss,
+								:feed_type,
+								:category,
+								:language,
+								:region,
+								:enabled,
+								:poll_interval
+								)`
+
+	var conflictStmt string = `ON CONFLICT (rss) DO UPDATE SET
+								name = EXCLUDED.name,
+								link = EXCLUDED.link,
+								feed_type = EXCLUDED.feed_type,
+								category = EXCLUDED.category,
+								language = EXCLUDED.language,
+								region = EXCLUDED.region,
+								enabled = EXCLUDED.enabled,
+								poll_interval = EXCLUDED.poll_interval`
+
+	var returnStmt string = "RETURNING feed_id"
+
+	stmt, err := db.PrepareNamedContext(ctx, insertStmt+" "+valueStmt+" "+conflictStmt+" "+returnStmt)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	return stmt.GetContext(ctx, &f.FeedID, f)
+}
+
+// DisableFeed stops the feed with the given rss url from being polled.
+// Returns ErrFeedNotFound if there is no matching feed.
+func DisableFeed(ctx context.Context, db *sqlx.DB, rss string) error {
+	res, err := db.ExecContext(ctx, "UPDATE NewsFeed SET enabled = false WHERE rss = $1", strings.TrimSpace(rss))
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	} else if n == 0 {
+		return ErrFeedNotFound
+	}
+
+	return nil
+}
+
+// ImportFeeds saves each feed in the csv file to the NewsFeed table.
+// Uses the same columns as the old NEWSPAPER_RSS_FILEPATH file, with optional Type, Language and Region columns.
+// Returns the number of feeds saved.
+func ImportFeeds(ctx context.Context, db *sqlx.DB, filepath string) (int, error) {
+	list, err := rssFromFile(filepath)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for _, r := range list {
+		// sanity check that there is an rss link, arbitrary length value used
+		if len(strings.TrimSpace(r.RSS)) < 5 {
+			setup.LogCommon(nil).
+				WithField("RSS", r.Name).
+				Warn("No RSS feed")
+			continue
+		}
+
+		feed := NewFeed(r.Name, r.Link, r.RSS, r.Category)
+		if t := strings.ToLower(strings.TrimSpace(r.Type)); len(t) > 0 {
+			feed.FeedType = t
+		}
+		feed.Language = optionalString(r.Language)
+		feed.Region = optionalString(r.Region)
+
+		err = feed.Save(ctx, db)
+		if err != nil {
+			return n, fmt.Errorf("save feed %s: %w", feed.RSS, err)
+		}
+		n = n + 1
+	}
+
+	return n, nil
+}
+
+// temp struct to parse rss source file
+type rss struct {
+	Name     string `csv:"Name"`
+	Link     string `csv:"Link"`
+	RSS      string `csv:"RSS"`
+	Category string `csv:"Category"`
+	Type     string `csv:"Type"`
+	Language string `csv:"Language"`
+	Region   string `csv:"Region"`
+}
+
+// rssFromFile creates an array of rss structs from the csv file at filepath.
+func rssFromFile(filepath string) ([]*rss, error) {
+	// Get the file
+	sourceFile, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer sourceFile.Close()
+
+	// Read from file to array
+	s := []*rss{}
+	if err := gocsv.UnmarshalFile(sourceFile, &s); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// FeedAddApp adds the feed to the feed catalog, or updates it if the rss url already exists.
+func FeedAddApp(feed *Feed) {
+	ctx := context.Background()
+	// connect to database
+	db, err := setup.SQL(ctx)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to SQL database")
+	}
+
+	if len(feed.RSS) < 5 {
+		setup.LogCommon(nil).
+			WithField("RSS", feed.Name).
+			Fatal("No RSS feed")
+	} else if feed.FeedType != FeedRSS && feed.FeedType != FeedSitemap {
+		setup.LogCommon(nil).
+			WithField("RSS", feed.Name).
+			WithField("type", feed.FeedType).
+			Fatal("Unknown feed type")
+	}
+
+	err = feed.Save(ctx, db)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("RSS", feed.Name).
+			WithField("URL", feed.RSS).
+			Fatal("Failed Feed Save")
+	}
+
+	fmt.Println("Saved feed", feed.FeedID, feed.Name, feed.RSS)
+	setup.LogCommon(nil).
+		WithField("FeedID", feed.FeedID).
+		WithField("RSS", feed.Name).
+		WithField("URL", feed.RSS).
+		WithField("RunTime", setup.RunTime().String()).
+		Info("RunSummary")
+}
+
+// FeedDisableApp stops the feed with the given rss url from being polled.
+func FeedDisableApp(rss string) {
+	ctx := context.Background()
+	// connect to database
+	db, err := setup.SQL(ctx)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to SQL database")
+	}
+
+	err = DisableFeed(ctx, db, rss)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("URL", rss).
+			Fatal("Failed DisableFeed")
+	}
+
+	fmt.Println("Disabled feed", rss)
+	setup.LogCommon(nil).
+		WithField("URL", rss).
+		WithField("RunTime", setup.RunTime().String()).
+		Info("RunSummary")
+}
+
+// FeedImportApp saves each feed in the csv file to the feed catalog.
+func FeedImportApp(filepath string) {
+	ctx := context.Background()
+	// connect to database
+	db, err := setup.SQL(ctx)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to SQL database")
+	}
+
+	n, err := ImportFeeds(ctx, db, filepath)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("filepath", filepath).
+			WithField("NumFeeds", n).
+			Fatal("Failed ImportFeeds")
+	}
+
+	fmt.Println("Imported feeds", n)
+	setup.LogCommon(nil).
+		WithField("filepath", filepath).
+		WithField("NumFeeds", n).
+		WithField("RunTime", setup.RunTime().String()).
+		Info("RunSummary")
+}
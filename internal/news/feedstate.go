@@ -0,0 +1,280 @@
+package news
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mmcdole/gofeed"
+	"gopkg.in/guregu/null.v3"
+
+	"github.com/wpwilson10/caterpillar/internal/setup"
+)
+
+// base wait time after a feed fails, doubled for each consecutive failure
+const feedBackoffBase = time.Hour
+
+// longest wait time between attempts for a failing feed
+const feedBackoffMax = 7 * 24 * time.Hour
+
+// Feed polling thresholds
+const (
+	feedDeadFailures = 5                  // consecutive failures before a feed is dead
+	feedStaleAge     = 7 * 24 * time.Hour // time since the newest item before a feed is stale
+	feedMinItemSpan  = time.Hour          // shortest item span used to estimate items per day
+	feedMaxItemsDay  = float64(24 * 60)   // cap on items per day estimates
+	feedItemsWeight  = 0.2                // weight of the newest value in the items per day average
+)
+
+// Feed health values used by the feed health report
+const (
+	FeedHealthOK    = "ok"    // nothing wrong
+	FeedHealthDead  = "dead"  // feed keeps failing
+	FeedHealthStale = "stale" // feed works but has no recent items
+	FeedHealthEmpty = "empty" // feed works but returned no items
+)
+
+// FeedState stores the polling state of an rss feed in a format matching the NewsFeedState table schema.
+type FeedState struct {
+	RSS                 string      `db:"rss"` // rss feed url
+	Name                string      `db:"name"`
+	ETag                null.String `db:"etag"`          // ETag header from the last successful response
+	LastModified        null.String `db:"last_modified"` // Last-Modified header from the last successful response
+	LastAttemptTime     null.Time   `db:"last_attempt_time"`
+	LastSuccessTime     null.Time   `db:"last_success_time"`
+	LastItemTime        null.Time   `db:"last_item_time"` // publish time of the newest item seen
+	ConsecutiveFailures int         `db:"consecutive_failures"`
+	LastError           null.String `db:"last_error"`
+	LastItemCount       null.Int    `db:"last_item_count"` // items in the last full response
+	ItemsPerDay         null.Float  `db:"items_per_day"`   // moving average of items published per day
+}
+
+// NewFeedState creates the state for a feed that has never been polled.
+func NewFeedState(rss string, name string) *FeedState {
+	return &FeedState{RSS: rss, Name: name}
+}
+
+// LoadFeedStates returns the saved state of every feed keyed by rss url.
+func LoadFeedStates(ctx context.Context, db *sqlx.DB) (map[string]*FeedState, error) {
+	states := []FeedState{}
+	err := db.SelectContext(ctx, &states, "SELECT * FROM NewsFeedState")
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]*FeedState, len(states))
+	for i := range states {
+		out[states[i].RSS] = &states[i]
+	}
+
+	return out, nil
+}
+
+// Save inserts or updates this feed's row in the NewsFeedState table.
+func (s *FeedState) Save(ctx context.Context, db *sqlx.DB) error {
+	var insertStmt string = `INSERT INTO NewsFeedState (
+								rss,
+								name,
+								etag,
+								last_modified,
+								last_attempt_time,
+								last_success_time,
+								last_item_time,
+								consecutive_failures,
+								last_error,
+								last_item_count,
+								items_per_day
+								)`
+
+	var valueStmt string = `VALUES (
+								:rss,
+								:name,
+								:etag,
+								:last_modified,
+								:last_attempt_time,
+								:last_success_time,
+								:last_item_time,
+								:consecutive_failures,
+								:last_error,
+								:last_item_count,
+								:items_per_day
+								)`
+
+	var conflictStmt string = `ON CONFLICT (rss) DO UPDATE SET
+								name = EXCLUDED.name,
+								etag = EXCLUDED.etag,
+								last_modified = EXCLUDED.last_modified,
+								last_attempt_time = EXCLUDED.last_attempt_time,
+								last_success_time = EXCLUDED.last_success_time,
+								last_item_time = EXCLUDED.last_item_time,
+								consecutive_failures = EXCLUDED.consecutive_failures,
+								last_error = EXCLUDED.last_error,
+								last_item_count = EXCLUDED.last_item_count,
+								items_per_day = EXCLUDED.items_per_day`
+
+	_, err := db.NamedExecContext(ctx, insertStmt+" "+valueStmt+" "+conflictStmt, s)
+	return err
+}
+
+// Due returns true if the feed should be polled at the given time.
+// Failing feeds wait twice as long after each consecutive failure.
+func (s *FeedState) Due(now time.Time) bool {
+	if s.ConsecutiveFailures == 0 || !s.LastAttemptTime.Valid {
+		return true
+	}
+
+	return !now.Before(s.LastAttemptTime.Time.Add(s.backoff()))
+}
+
+// backoff returns the wait time after the last failed attempt.
+func (s *FeedState) backoff() time.Duration {
+	wait := float64(feedBackoffBase) * math.Pow(2, float64(s.ConsecutiveFailures-1))
+	if wait > float64(feedBackoffMax) {
+		return feedBackoffMax
+	}
+
+	return time.Duration(wait)
+}
+
+// NotModified records a successful poll where the server returned 304 Not Modified.
+func (s *FeedState) NotModified(now time.Time) {
+	s.LastAttemptTime = null.TimeFrom(now)
+	s.LastSuccessTime = null.TimeFrom(now)
+	s.ConsecutiveFailures = 0
+	s.LastError = null.String{}
+}
+
+// Success records a successful poll that returned the given feed and cache headers.
+func (s *FeedState) Success(now time.Time, feed *gofeed.Feed, etag string, lastModified string) {
+	s.NotModified(now)
+	s.ETag = null.NewString(etag, len(etag) > 0)
+	s.LastModified = null.NewString(lastModified, len(lastModified) > 0)
+	s.LastItemCount = null.IntFrom(int64(len(feed.Items)))
+
+	// find the time span covered by the feed's items
+	var oldest, newest time.Time
+	for _, item := range feed.Items {
+		if item.PublishedParsed == nil {
+			continue
+		}
+		t := *item.PublishedParsed
+		if oldest.IsZero() || t.Before(oldest) {
+			oldest = t
+		}
+		if newest.IsZero() || t.After(newest) {
+			newest = t
+		}
+	}
+
+	if !newest.IsZero() && (!s.LastItemTime.Valid || newest.After(s.LastItemTime.Time)) {
+		s.LastItemTime = null.TimeFrom(newest)
+	}
+
+	// update the items per day moving average
+	if len(feed.Items) > 1 && !oldest.IsZero() {
+		span := newest.Sub(oldest)
+		if span < feedMinItemSpan {
+			span = feedMinItemSpan
+		}
+		rate := math.Min(float64(len(feed.Items))/span.Hours()*24, feedMaxItemsDay)
+
+		if s.ItemsPerDay.Valid {
+			rate = feedItemsWeight*rate + (1-feedItemsWeight)*s.ItemsPerDay.Float64
+		}
+		s.ItemsPerDay = null.FloatFrom(rate)
+	}
+}
+
+// Polled records a successful poll of a feed that is not rss, such as a sitemap, that listed the given number of items.
+func (s *FeedState) Polled(now time.Time, items int) {
+	s.NotModified(now)
+	s.LastItemCount = null.IntFrom(int64(items))
+}
+
+// Failure records a failed poll.
+func (s *FeedState) Failure(now time.Time, err error) {
+	s.LastAttemptTime = null.TimeFrom(now)
+	s.ConsecutiveFailures = s.ConsecutiveFailures + 1
+	s.LastError = null.StringFrom(err.Error())
+}
+
+// Health returns whether the feed is dead, stale, empty, or ok at the given time.
+func (s *FeedState) Health(now time.Time) string {
+	switch {
+	case s.ConsecutiveFailures >= feedDeadFailures:
+		return FeedHealthDead
+	case s.LastItemCount.Valid && s.LastItemCount.Int64 == 0:
+		return FeedHealthEmpty
+	case s.LastItemTime.Valid && now.Sub(s.LastItemTime.Time) > feedStaleAge:
+		return FeedHealthStale
+	}
+
+	return FeedHealthOK
+}
+
+// FeedHealthReport returns the saved feed states grouped by their health, sorted by rss url.
+func FeedHealthReport(ctx context.Context, db *sqlx.DB) (map[string][]*FeedState, error) {
+	states, err := LoadFeedStates(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	// sort for consistent output
+	all := []*FeedState{}
+	for _, s := range states {
+		all = append(all, s)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].RSS < all[j].RSS
+	})
+
+	now := time.Now()
+	out := make(map[string][]*FeedState)
+	for _, s := range all {
+		health := s.Health(now)
+		out[health] = append(out[health], s)
+	}
+
+	return out, nil
+}
+
+// FeedHealthApp prints and logs the feeds that are dead, stale, or empty.
+func FeedHealthApp() {
+	ctx := context.Background()
+	// connect to database
+	db, err := setup.SQL(ctx)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to SQL database")
+	}
+
+	report, err := FeedHealthReport(ctx, db)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Failed FeedHealthReport")
+	}
+
+	for _, health := range []string{FeedHealthDead, FeedHealthStale, FeedHealthEmpty} {
+		for _, s := range report[health] {
+			fmt.Println(health, s.Name, s.RSS, s.ConsecutiveFailures, s.LastSuccessTime.Time, s.LastItemTime.Time)
+
+			setup.LogCommon(nil).
+				WithField("health", health).
+				WithField("RSS", s.Name).
+				WithField("URL", s.RSS).
+				WithField("failures", s.ConsecutiveFailures).
+				WithField("lastError", s.LastError.String).
+				Warn("Unhealthy feed")
+		}
+	}
+
+	// run summary
+	setup.LogCommon(nil).
+		WithField("NumOK", len(report[FeedHealthOK])).
+		WithField("NumDead", len(report[FeedHealthDead])).
+		WithField("NumStale", len(report[FeedHealthStale])).
+		WithField("NumEmpty", len(report[FeedHealthEmpty])).
+		WithField("RunTime", setup.RunTime().String()).
+		Info("RunSummary")
+}
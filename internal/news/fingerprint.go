@@ -0,0 +1,271 @@
+package news
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/wpwilson10/caterpillar/internal/setup"
+)
+
+// number of bands the simhash is split into for the LSH index.
+// Two fingerprints within (numBands - 1) bits of each other always share a band.
+const numBands = 4
+
+// number of words per shingle
+const shingleSize = 3
+
+// minimum number of words needed for a meaningful fingerprint
+const minFingerprintWords = 20
+
+// default max Hamming distance between near duplicate articles
+const defaultSimHashDistance = 3
+
+// max Hamming distance between near duplicate articles, loaded on first use
+var (
+	simHashDistanceOnce sync.Once
+	simHashDistance     int
+)
+
+// SimHashDistance returns the max Hamming distance between near duplicate articles from NEWSPAPER_SIMHASH_DISTANCE.
+// Matches are only found through a shared band, so the distance is clamped to [0, numBands-1]
+// where every match is guaranteed to be found.
+func SimHashDistance() int {
+	simHashDistanceOnce.Do(func() {
+		simHashDistance = clampSimHashDistance(setup.EnvToIntOr("NEWSPAPER_SIMHASH_DISTANCE", defaultSimHashDistance))
+	})
+
+	return simHashDistance
+}
+
+// clampSimHashDistance limits the distance to what the band index can find, warning if it was changed.
+func clampSimHashDistance(distance int) int {
+	out := distance
+	if out < 0 {
+		out = 0
+	} else if out > numBands-1 {
+		out = numBands - 1
+	}
+
+	if out != distance {
+		setup.LogCommon(nil).
+			WithField("NEWSPAPER_SIMHASH_DISTANCE", distance).
+			WithField("distance", out).
+			Warn("SimHash distance out of range")
+	}
+
+	return out
+}
+
+// ErrShortBody is returned when an article body is too short to fingerprint.
+var ErrShortBody = errors.New("body too short to fingerprint")
+
+// Fingerprint represents the NewsFingerprint table used to find near duplicate articles.
+type Fingerprint struct {
+	ArticleID   int64     `db:"article_id"`
+	DataTime    time.Time `db:"data_entry_time"`
+	SimHash     int64     `db:"simhash"` // uint64 simhash stored as a signed bigint
+	Band0       int       `db:"band_0"`
+	Band1       int       `db:"band_1"`
+	Band2       int       `db:"band_2"`
+	Band3       int       `db:"band_3"`
+	DuplicateOf *int64    `db:"duplicate_of"` // earliest near duplicate article, nil if none
+	ClusterID   int64     `db:"cluster_id"`   // article_id of the first article in the cluster
+}
+
+// NewFingerprint computes the fingerprint for the article's body.
+// Returns ErrShortBody if there is not enough text.
+func NewFingerprint(article *Article) (*Fingerprint, error) {
+	words := tokenize(article.Body.ValueOrZero())
+	if len(words) < minFingerprintWords {
+		return nil, ErrShortBody
+	}
+
+	hash := SimHash(words)
+	bands := splitBands(hash)
+
+	return &Fingerprint{
+		ArticleID: article.ArticleID,
+		DataTime:  time.Now(),
+		SimHash:   int64(hash),
+		Band0:     bands[0],
+		Band1:     bands[1],
+		Band2:     bands[2],
+		Band3:     bands[3],
+		ClusterID: article.ArticleID,
+	}, nil
+}
+
+// SimHash returns a 64 bit locality sensitive hash of the word shingles.
+// Similar texts produce hashes with a small Hamming distance.
+func SimHash(words []string) uint64 {
+	var vote [64]int
+
+	for i := 0; i+shingleSize <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+shingleSize], " ")))
+		sum := h.Sum64()
+
+		// each shingle votes on every bit
+		for b := 0; b < 64; b++ {
+			if sum&(1<<uint(b)) != 0 {
+				vote[b]++
+			} else {
+				vote[b]--
+			}
+		}
+	}
+
+	var out uint64
+	for b := 0; b < 64; b++ {
+		if vote[b] > 0 {
+			out |= 1 << uint(b)
+		}
+	}
+
+	return out
+}
+
+// Distance returns the number of bits that differ between the two fingerprints.
+func (f *Fingerprint) Distance(other *Fingerprint) int {
+	return bits.OnesCount64(uint64(f.SimHash) ^ uint64(other.SimHash))
+}
+
+// Insert finds the earliest near duplicate within threshold bits, sets the
+// DuplicateOf and ClusterID fields, and adds this fingerprint to the NewsFingerprint table.
+func (f *Fingerprint) Insert(ctx context.Context, db *sqlx.DB, threshold int) error {
+	// candidates share at least one band with this fingerprint
+	var selectStmt string = `SELECT * FROM NewsFingerprint
+							 WHERE article_id != $1
+								AND (band_0=$2 OR band_1=$3 OR band_2=$4 OR band_3=$5)
+							 ORDER BY article_id ASC`
+
+	candidates := []Fingerprint{}
+	err := db.SelectContext(ctx, &candidates, selectStmt, f.ArticleID, f.Band0, f.Band1, f.Band2, f.Band3)
+	if err != nil {
+		return err
+	}
+
+	// join the cluster of the earliest match
+	for i := range candidates {
+		c := &candidates[i]
+		if f.Distance(c) <= threshold {
+			f.DuplicateOf = &c.ArticleID
+			f.ClusterID = c.ClusterID
+			break
+		}
+	}
+
+	var insertStmt string = `INSERT INTO NewsFingerprint (
+								article_id,
+								data_entry_time,
+								simhash,
+								band_0,
+								band_1,
+								band_2,
+								band_3,
+								duplicate_of,
+								cluster_id
+								)`
+
+	var valueStmt string = `VALUES (
+								:article_id,
+								Now(),
+								:simhash,
+								:band_0,
+								:band_1,
+								:band_2,
+								:band_3,
+								:duplicate_of,
+								:cluster_id
+								)`
+
+	_, err = db.NamedExecContext(ctx, insertStmt+" "+valueStmt, f)
+	return err
+}
+
+// Syndications returns all articles in the same near duplicate cluster as the given article,
+// including the article itself, ordered from oldest to newest.
+func Syndications(ctx context.Context, db *sqlx.DB, articleID int64) ([]Article, error) {
+	var selectStmt string = `SELECT ` + ArticleColumns("a") + ` FROM NewsArticle a
+							 JOIN NewsFingerprint f ON f.article_id = a.article_id
+							 WHERE f.cluster_id = (SELECT cluster_id FROM NewsFingerprint WHERE article_id=$1)
+							 ORDER BY a.article_id ASC`
+
+	articles := []Article{}
+	err := db.SelectContext(ctx, &articles, selectStmt, articleID)
+
+	return articles, err
+}
+
+// SyndicationsApp prints the articles in the same near duplicate cluster as an article, oldest first.
+func SyndicationsApp(articleID int64) {
+	ctx := context.Background()
+	// connect to database
+	db, err := setup.SQL(ctx)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to SQL database")
+	}
+
+	articles, err := Syndications(ctx, db, articleID)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("ArticleID", articleID).
+			Fatal("Failed Syndications")
+	}
+
+	for _, a := range articles {
+		fmt.Println(a.ArticleID, a.DataTime.Format(time.RFC3339), a.Host, a.Title.String, a.Link)
+	}
+
+	setup.LogCommon(nil).
+		WithField("ArticleID", articleID).
+		WithField("NumArticles", len(articles)).
+		WithField("RunTime", setup.RunTime().String()).
+		Info("RunSummary")
+}
+
+// fingerprintArticle adds the article's fingerprint to the database, logging on failure.
+func fingerprintArticle(ctx context.Context, db *sqlx.DB, article *Article) {
+	fingerprint, err := NewFingerprint(article)
+	if err == ErrShortBody {
+		return
+	}
+
+	err = fingerprint.Insert(ctx, db, SimHashDistance())
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("articleID", article.ArticleID).
+			Error("Failed fingerprint Insert")
+	} else if fingerprint.DuplicateOf != nil {
+		setup.LogCommon(nil).
+			WithField("articleID", article.ArticleID).
+			WithField("duplicateOf", *fingerprint.DuplicateOf).
+			Info("Near duplicate article")
+	}
+}
+
+// tokenize splits text into lowercase words without punctuation.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// splitBands divides the hash into numBands 16 bit values.
+func splitBands(hash uint64) [numBands]int {
+	var out [numBands]int
+	for i := 0; i < numBands; i++ {
+		out[i] = int((hash >> uint(16*i)) & 0xFFFF)
+	}
+
+	return out
+}
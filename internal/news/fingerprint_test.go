@@ -0,0 +1,183 @@
+package news
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"math/bits"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestSplitBands(t *testing.T) {
+	tests := []struct {
+		hash uint64
+		want [numBands]int
+	}{
+		{0, [numBands]int{0, 0, 0, 0}},
+		{0xFFFFFFFFFFFFFFFF, [numBands]int{0xFFFF, 0xFFFF, 0xFFFF, 0xFFFF}},
+		{0x0004000300020001, [numBands]int{1, 2, 3, 4}},
+		{0x8000000000000001, [numBands]int{1, 0, 0, 0x8000}},
+	}
+
+	for _, tt := range tests {
+		if got := splitBands(tt.hash); got != tt.want {
+			t.Errorf("splitBands(%#x) = %v, want %v", tt.hash, got, tt.want)
+		}
+	}
+}
+
+// Fingerprints within numBands-1 bits always share a band, however the differing bits are spread.
+func TestSplitBandsShareBand(t *testing.T) {
+	hash := uint64(0x0123456789ABCDEF)
+	for a := 0; a < 64; a++ {
+		for b := a; b < 64; b++ {
+			for c := b; c < 64; c++ {
+				other := hash ^ (1 << uint(a)) ^ (1 << uint(b)) ^ (1 << uint(c))
+				if bits.OnesCount64(hash^other) > numBands-1 {
+					continue
+				}
+
+				x, y := splitBands(hash), splitBands(other)
+				shared := false
+				for i := range x {
+					shared = shared || x[i] == y[i]
+				}
+				if !shared {
+					t.Fatalf("%#x and %#x share no band", hash, other)
+				}
+			}
+		}
+	}
+}
+
+func TestClampSimHashDistance(t *testing.T) {
+	tests := []struct {
+		distance int
+		want     int
+	}{
+		{-1, 0},
+		{0, 0},
+		{defaultSimHashDistance, defaultSimHashDistance},
+		{numBands - 1, numBands - 1},
+		{numBands, numBands - 1},
+		{64, numBands - 1},
+	}
+
+	for _, tt := range tests {
+		if got := clampSimHashDistance(tt.distance); got != tt.want {
+			t.Errorf("clampSimHashDistance(%d) = %d, want %d", tt.distance, got, tt.want)
+		}
+	}
+}
+
+func TestSimHash(t *testing.T) {
+	story := strings.Repeat("the central bank raised interest rates by a quarter point on wednesday citing inflation ", 4)
+	edited := strings.Replace(story, "wednesday", "thursday", 1)
+	other := strings.Repeat("the home team won the championship after a late goal in extra time on sunday night ", 4)
+
+	a, b, c := SimHash(tokenize(story)), SimHash(tokenize(edited)), SimHash(tokenize(other))
+	if a != SimHash(tokenize(strings.ToUpper(story))) {
+		t.Error("SimHash depends on case")
+	}
+	if near, far := bits.OnesCount64(a^b), bits.OnesCount64(a^c); near >= far {
+		t.Errorf("edited distance %d is not less than unrelated distance %d", near, far)
+	}
+}
+
+// fingerprintRows returns NewsFingerprint rows for the article IDs, hashes, and cluster IDs.
+func fingerprintRows(rows ...[3]int64) *recordRows {
+	out := &recordRows{columns: []string{"article_id", "data_entry_time", "simhash",
+		"band_0", "band_1", "band_2", "band_3", "duplicate_of", "cluster_id"}}
+	for _, r := range rows {
+		bands := splitBands(uint64(r[1]))
+		out.values = append(out.values, []driver.Value{r[0], time.Now(), r[1],
+			int64(bands[0]), int64(bands[1]), int64(bands[2]), int64(bands[3]), nil, r[2]})
+	}
+
+	return out
+}
+
+func TestFingerprintInsertCluster(t *testing.T) {
+	hash := int64(0x0123456789ABCDEF)
+	newFingerprint := func() *Fingerprint {
+		bands := splitBands(uint64(hash))
+		return &Fingerprint{ArticleID: 30, SimHash: hash, ClusterID: 30,
+			Band0: bands[0], Band1: bands[1], Band2: bands[2], Band3: bands[3]}
+	}
+
+	tests := []struct {
+		name        string
+		candidates  *recordRows
+		duplicateOf int64 // zero for none
+		clusterID   int64
+	}{
+		{"no candidates", fingerprintRows(), 0, 30},
+		{"too far", fingerprintRows([3]int64{10, hash ^ 0x1F, 10}), 0, 30},
+		{
+			// article 10 is too far, 20 is the earliest match, and 25 is closer but later
+			"earliest match",
+			fingerprintRows([3]int64{10, hash ^ 0x1F, 10}, [3]int64{20, hash ^ 0x3, 15}, [3]int64{25, hash ^ 0x1, 25}),
+			20, 15,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &recordConn{results: []*recordRows{tt.candidates}}
+			db := sqlx.NewDb(sql.OpenDB(recordDriver{conn}), "postgres")
+			defer db.Close()
+
+			f := newFingerprint()
+			if err := f.Insert(context.Background(), db, 3); err != nil {
+				t.Fatal(err)
+			}
+
+			if (f.DuplicateOf == nil) != (tt.duplicateOf == 0) || (f.DuplicateOf != nil && *f.DuplicateOf != tt.duplicateOf) {
+				t.Errorf("DuplicateOf = %v, want %d", f.DuplicateOf, tt.duplicateOf)
+			}
+			if f.ClusterID != tt.clusterID {
+				t.Errorf("ClusterID = %d, want %d", f.ClusterID, tt.clusterID)
+			}
+
+			// candidates are found by band, then the fingerprint is stored with its cluster
+			if len(conn.queries) != 2 || !strings.Contains(conn.queries[1].query, "INSERT INTO NewsFingerprint") {
+				t.Fatalf("queries = %v", conn.queries)
+			}
+			args := conn.queries[1].args
+			if args[len(args)-1] != tt.clusterID {
+				t.Errorf("inserted cluster_id = %v, want %d", args[len(args)-1], tt.clusterID)
+			}
+		})
+	}
+}
+
+func TestSyndications(t *testing.T) {
+	rows := &recordRows{columns: articleColumns}
+	for _, id := range []int64{20, 30} {
+		row := make([]driver.Value, len(articleColumns))
+		row[0], row[1], row[2], row[3], row[4] = id, time.Now(), "RSS", "www.example.com", "https://www.example.com/story"
+		rows.values = append(rows.values, row)
+	}
+	conn := &recordConn{results: []*recordRows{rows}}
+	db := sqlx.NewDb(sql.OpenDB(recordDriver{conn}), "postgres")
+	defer db.Close()
+
+	articles, err := Syndications(context.Background(), db, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(articles) != 2 || articles[0].ArticleID != 20 || articles[1].ArticleID != 30 {
+		t.Errorf("Syndications = %+v", articles)
+	}
+
+	// the cluster is looked up from the article's fingerprint
+	q := conn.queries[0]
+	if !strings.Contains(q.query, "cluster_id = (SELECT cluster_id FROM NewsFingerprint WHERE article_id=$1)") ||
+		len(q.args) != 1 || q.args[0] != int64(30) {
+		t.Errorf("query %s with %v", q.query, q.args)
+	}
+}
@@ -0,0 +1,269 @@
+package news
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/wpwilson10/caterpillar/internal/redis"
+	"github.com/wpwilson10/caterpillar/internal/setup"
+)
+
+// default Hacker News API location
+const hnDefaultBaseURL = "https://hacker-news.firebaseio.com/v0"
+
+// number of item requests made at once
+const hnWorkers = 8
+
+// Hacker News story lists
+var hnStoryLists = []string{"topstories", "newstories", "beststories"}
+
+// HNItem is a Hacker News item from the API.
+type HNItem struct {
+	ID          int64  `json:"id"`
+	Type        string `json:"type"`
+	By          string `json:"by"`
+	Time        int64  `json:"time"` // unix time
+	Title       string `json:"title"`
+	URL         string `json:"url"` // empty for Ask HN and other text posts
+	Score       int    `json:"score"`
+	Descendants int    `json:"descendants"` // total comment count
+	Dead        bool   `json:"dead"`
+	Deleted     bool   `json:"deleted"`
+}
+
+// HNClient makes requests to the Hacker News API.
+type HNClient struct {
+	client  *http.Client
+	baseURL string
+}
+
+// NewHNClient creates a client for the Hacker News API at baseURL.
+func NewHNClient(baseURL string) *HNClient {
+	return &HNClient{
+		client:  &http.Client{Timeout: time.Second * 10},
+		baseURL: strings.TrimRight(baseURL, "/"),
+	}
+}
+
+// NewHNClientFromEnv creates a client for the API at HN_BASE_URL, or the public API if not set.
+func NewHNClientFromEnv() *HNClient {
+	baseURL := os.Getenv("HN_BASE_URL")
+	if len(baseURL) == 0 {
+		baseURL = hnDefaultBaseURL
+	}
+
+	return NewHNClient(baseURL)
+}
+
+// get decodes the json response at the path into v.
+func (c *HNClient) get(ctx context.Context, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("http status %d", resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// StoryIDs returns the item IDs in a story list such as topstories, newstories, or beststories.
+func (c *HNClient) StoryIDs(ctx context.Context, list string) ([]int64, error) {
+	ids := []int64{}
+	err := c.get(ctx, "/"+list+".json", &ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// ErrHNItemNotFound is returned when the API has no item for an ID.
+var ErrHNItemNotFound = errors.New("hacker news item not found")
+
+// Item returns the item with the given ID.
+func (c *HNClient) Item(ctx context.Context, id int64) (*HNItem, error) {
+	// the api returns null for unknown items
+	var item *HNItem
+	err := c.get(ctx, fmt.Sprintf("/item/%d.json", id), &item)
+	if err != nil {
+		return nil, err
+	} else if item == nil {
+		return nil, ErrHNItemNotFound
+	}
+
+	return item, nil
+}
+
+// Stories returns the live link stories from the first limit IDs of each story list.
+// Stories in more than one list are only returned once.
+func (c *HNClient) Stories(ctx context.Context, limit int) []*HNItem {
+	// collect unique IDs in list order
+	ids := []int64{}
+	known := make(map[int64]bool)
+	for _, list := range hnStoryLists {
+		listIDs, err := c.StoryIDs(ctx, list)
+		if err != nil {
+			setup.LogCommon(err).
+				WithField("list", list).
+				Error("Failed StoryIDs")
+			continue
+		}
+		if len(listIDs) > limit {
+			listIDs = listIDs[:limit]
+		}
+		for _, id := range listIDs {
+			if !known[id] {
+				known[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	// get items in parallel
+	items := make([]*HNItem, len(ids))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < hnWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				item, err := c.Item(ctx, ids[i])
+				if err != nil {
+					setup.LogCommon(err).
+						WithField("itemID", ids[i]).
+						Warn("Failed HN Item")
+					continue
+				}
+				items[i] = item
+			}
+		}()
+	}
+	for i := range ids {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	// keep stories that link to articles
+	out := []*HNItem{}
+	for _, item := range items {
+		if item != nil && item.Type == "story" && !item.Dead && !item.Deleted && setup.IsValidURL(item.URL) {
+			out = append(out, item)
+		}
+	}
+
+	return out
+}
+
+// FromHackerNews uses Hacker News stories to make a source
+func FromHackerNews(item *HNItem) SourceOption {
+	// fetch the link as given, using its standard form for dedupe
+	fetchLink := strings.TrimSpace(item.URL)
+	link := Canonicalize(fetchLink)
+	// get host from link
+	host := linkHost(link)
+
+	// convert time
+	cTime := time.Unix(item.Time, 0)
+
+	return func(s *Source) {
+		s.Title = item.Title
+		s.Link = link
+		s.FetchLink = fetchLink
+		s.Source = "Hacker News"
+		s.Host = host
+		s.PubDate = &cTime
+	}
+}
+
+// HackerNews represents the database table for linking Hacker News stories to news articles.
+type HackerNews struct {
+	ArticleID int64     `db:"article_id"`
+	ItemID    int64     `db:"item_id"`
+	Score     int       `db:"score"`
+	Comments  int       `db:"comments"`
+	DataTime  time.Time `db:"data_entry_time"`
+}
+
+// NewHackerNews creates an entry for the HackerNews database table
+func NewHackerNews(articleID int64, item *HNItem) *HackerNews {
+	return &HackerNews{
+		ArticleID: articleID,
+		ItemID:    item.ID,
+		Score:     item.Score,
+		Comments:  item.Descendants,
+	}
+}
+
+// Insert adds this hackernews relationship to the database table,
+// or updates the score and comment count if it already exists.
+func (link *HackerNews) Insert(ctx context.Context, db *sqlx.DB) {
+	// Setup
+	var insertStmt string = `INSERT INTO HackerNews (
+								data_entry_time,
+								article_id,
+								item_id,
+								score,
+								comments
+								)`
+
+	var valueStmt string = `VALUES (
+								Now(),
+								:article_id,
+								:item_id,
+								:score,
+								:comments
+								)`
+
+	var conflictStmt string = `ON CONFLICT (article_id, item_id) DO UPDATE SET
+								data_entry_time = EXCLUDED.data_entry_time,
+								score = EXCLUDED.score,
+								comments = EXCLUDED.comments`
+
+	_, err := db.NamedExecContext(ctx, insertStmt+" "+valueStmt+" "+conflictStmt, link)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("ArticleID", link.ArticleID).
+			WithField("ItemID", link.ItemID).
+			Error("db.NamedExec")
+	}
+}
+
+// SourceListFromHackerNews returns the link stories from the Hacker News story lists.
+// Uses the first HN_STORY_LIMIT stories of each list, default 30, where zero turns the source off.
+func SourceListFromHackerNews(ctx context.Context, client *HNClient) []*HNItem {
+	limit := setup.EnvToIntOr("HN_STORY_LIMIT", 30)
+	if limit <= 0 {
+		return nil
+	}
+
+	return client.Stories(ctx, limit)
+}
+
+// HackerNewsDriver adds the news article from a Hacker News story to the NewsArticle database
+// and adds a HackerNews relationship entry to the HackerNews table.
+func HackerNewsDriver(ctx context.Context, db *sqlx.DB, articleSet *redis.Set, blacklist *BlackList, robots *RobotsCache, item *HNItem) *Article {
+	source := NewSource(FromHackerNews(item))
+	return linkArticle(ctx, db, articleSet, blacklist, robots, source, func(articleID int64) {
+		NewHackerNews(articleID, item).Insert(ctx, db)
+	})
+}
@@ -0,0 +1,289 @@
+package news
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// hnTestItems are served by hnTestServer by item ID, an empty value is served as null.
+var hnTestItems = map[string]string{
+	"1": `{"id":1,"type":"story","by":"alice","time":1600000000,"title":"Story","url":"https://www.example.com/story","score":42,"descendants":7}`,
+	"2": `{"id":2,"type":"story","by":"bob","time":1600000100,"title":"Ask HN: Text post","score":5}`,
+	"3": `{"id":3,"type":"story","title":"Dead","url":"https://www.example.com/dead","dead":true}`,
+	"4": `{"id":4,"type":"comment","by":"carol","time":1600000200}`,
+	"5": ``,
+	"6": `{"id":6,"type":"story","title":"Best","url":"https://www.example.org/best","score":300,"descendants":120}`,
+	"7": `{"id":7,"type":"story","title":"Past limit","url":"https://www.example.org/limit"}`,
+	"8": `{"id":8,"type":"story","title":"Deleted","url":"https://www.example.org/deleted","deleted":true}`,
+}
+
+func hnTestServer(t *testing.T) (*httptest.Server, *[]string) {
+	var mu sync.Mutex
+	paths := []string{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/v0/topstories.json":
+			w.Write([]byte(`[1,2,3,7]`))
+		case "/v0/newstories.json":
+			w.Write([]byte(`[4,5,1,8]`))
+		case "/v0/beststories.json":
+			w.Write([]byte(`[6,1]`))
+		default:
+			id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v0/item/"), ".json")
+			item, ok := hnTestItems[id]
+			if !ok {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			} else if len(item) == 0 {
+				item = "null"
+			}
+			w.Write([]byte(item))
+		}
+	}))
+
+	return server, &paths
+}
+
+func TestHNClientItem(t *testing.T) {
+	server, _ := hnTestServer(t)
+	defer server.Close()
+
+	c := NewHNClient(server.URL + "/v0/")
+	ctx := context.Background()
+
+	item, err := c.Item(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := HNItem{ID: 1, Type: "story", By: "alice", Time: 1600000000, Title: "Story",
+		URL: "https://www.example.com/story", Score: 42, Descendants: 7}
+	if *item != want {
+		t.Errorf("Item(1) = %+v, want %+v", *item, want)
+	}
+
+	if _, err = c.Item(ctx, 5); err != ErrHNItemNotFound {
+		t.Errorf("Item(5) error = %v, want ErrHNItemNotFound", err)
+	}
+	if _, err = c.Item(ctx, 99); err == nil {
+		t.Error("Item(99) expected error for server error")
+	}
+}
+
+func TestHNClientStoryIDs(t *testing.T) {
+	server, _ := hnTestServer(t)
+	defer server.Close()
+
+	c := NewHNClient(server.URL + "/v0")
+	tests := map[string][]int64{
+		"topstories":  {1, 2, 3, 7},
+		"newstories":  {4, 5, 1, 8},
+		"beststories": {6, 1},
+	}
+	for list, want := range tests {
+		ids, err := c.StoryIDs(context.Background(), list)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ids) != len(want) {
+			t.Fatalf("StoryIDs(%s) = %v, want %v", list, ids, want)
+		}
+		for i := range ids {
+			if ids[i] != want[i] {
+				t.Errorf("StoryIDs(%s) = %v, want %v", list, ids, want)
+				break
+			}
+		}
+	}
+}
+
+func TestHNClientStories(t *testing.T) {
+	server, paths := hnTestServer(t)
+	defer server.Close()
+
+	stories := NewHNClient(server.URL+"/v0").Stories(context.Background(), 3)
+
+	// only live link stories, once each, in list order
+	got := []int64{}
+	for _, s := range stories {
+		got = append(got, s.ID)
+	}
+	if len(got) != 2 || got[0] != 1 || got[1] != 6 {
+		t.Errorf("Stories = %v, want [1 6]", got)
+	}
+
+	// items past the limit are not requested, and items in several lists are requested once
+	requested := []string{}
+	for _, p := range *paths {
+		if strings.HasPrefix(p, "/v0/item/") {
+			requested = append(requested, p)
+		}
+	}
+	sort.Strings(requested)
+	want := []string{"/v0/item/1.json", "/v0/item/2.json", "/v0/item/3.json", "/v0/item/4.json", "/v0/item/5.json", "/v0/item/6.json"}
+	if strings.Join(requested, " ") != strings.Join(want, " ") {
+		t.Errorf("requested %v, want %v", requested, want)
+	}
+}
+
+func TestFromHackerNews(t *testing.T) {
+	item := &HNItem{ID: 1, Time: 1600000000, Title: "Story", URL: " https://m.example.com/story/?utm_source=hn "}
+	s := NewSource(FromHackerNews(item))
+
+	if s.Link != "https://www.example.com/story" || s.FetchLink != "https://m.example.com/story/?utm_source=hn" {
+		t.Errorf("Link = %q, FetchLink = %q", s.Link, s.FetchLink)
+	}
+	if s.Host != "www.example.com" || s.Source != "Hacker News" || s.Title != "Story" {
+		t.Errorf("Host = %q, Source = %q, Title = %q", s.Host, s.Source, s.Title)
+	}
+	if s.PubDate == nil || s.PubDate.Unix() != 1600000000 {
+		t.Errorf("PubDate = %v", s.PubDate)
+	}
+}
+
+func TestHackerNewsInsert(t *testing.T) {
+	conn := &recordConn{}
+	raw := sql.OpenDB(recordDriver{conn})
+	db := sqlx.NewDb(raw, "postgres")
+	defer db.Close()
+
+	item := &HNItem{ID: 8863, Score: 111, Descendants: 71}
+	NewHackerNews(12, item).Insert(context.Background(), db)
+
+	if len(conn.queries) != 1 {
+		t.Fatalf("ran %d queries, want 1", len(conn.queries))
+	}
+	q := conn.queries[0]
+	if !strings.Contains(q.query, "ON CONFLICT (article_id, item_id) DO UPDATE SET") ||
+		!strings.Contains(q.query, "score = EXCLUDED.score") || !strings.Contains(q.query, "comments = EXCLUDED.comments") {
+		t.Errorf("query is not an upsert of the score and comments: %s", q.query)
+	}
+
+	want := []driver.Value{int64(12), int64(8863), int64(111), int64(71)}
+	if len(q.args) != len(want) {
+		t.Fatalf("args = %v, want %v", q.args, want)
+	}
+	for i := range want {
+		if q.args[i] != want[i] {
+			t.Errorf("args = %v, want %v", q.args, want)
+			break
+		}
+	}
+}
+
+// recordDriver is a database/sql connector that records statements instead of running them.
+// Queries return the connection's canned results in order.
+type recordDriver struct {
+	conn *recordConn
+}
+
+func (d recordDriver) Connect(ctx context.Context) (driver.Conn, error) {
+	return d.conn, nil
+}
+
+func (d recordDriver) Driver() driver.Driver {
+	return d
+}
+
+func (d recordDriver) Open(name string) (driver.Conn, error) {
+	return d.conn, nil
+}
+
+type recordedQuery struct {
+	query string
+	args  []driver.Value
+}
+
+type recordConn struct {
+	queries []recordedQuery
+	results []*recordRows // returned by the next queries
+}
+
+func (c *recordConn) Prepare(query string) (driver.Stmt, error) {
+	return &recordStmt{conn: c, query: query}, nil
+}
+
+func (c *recordConn) Close() error {
+	return nil
+}
+
+func (c *recordConn) Begin() (driver.Tx, error) {
+	return c, nil
+}
+
+func (c *recordConn) Commit() error {
+	return nil
+}
+
+func (c *recordConn) Rollback() error {
+	return nil
+}
+
+type recordStmt struct {
+	conn  *recordConn
+	query string
+}
+
+func (s *recordStmt) Close() error {
+	return nil
+}
+
+func (s *recordStmt) NumInput() int {
+	return -1
+}
+
+func (s *recordStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.conn.queries = append(s.conn.queries, recordedQuery{query: s.query, args: args})
+	return driver.RowsAffected(1), nil
+}
+
+func (s *recordStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if len(s.conn.results) == 0 {
+		return nil, driver.ErrSkip
+	}
+
+	s.conn.queries = append(s.conn.queries, recordedQuery{query: s.query, args: args})
+	rows := s.conn.results[0]
+	s.conn.results = s.conn.results[1:]
+
+	return rows, nil
+}
+
+// recordRows is a canned query result.
+type recordRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *recordRows) Columns() []string {
+	return r.columns
+}
+
+func (r *recordRows) Close() error {
+	return nil
+}
+
+func (r *recordRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package news
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	languagesOnce sync.Once
+	languages     map[string]bool
+	// allowed languages by host, replacing languages for the host and its subdomains
+	hostLanguages map[string]map[string]bool
+	// short hash of the language settings, changes whenever the settings do
+	languageSettings string
+)
+
+// loadLanguages reads the language settings from NEWSPAPER_LANGUAGES and NEWSPAPER_HOST_LANGUAGES.
+func loadLanguages() {
+	languagesOnce.Do(func() {
+		languages = parseLanguages(os.Getenv("NEWSPAPER_LANGUAGES"), ",")
+		hostLanguages = parseHostLanguages(os.Getenv("NEWSPAPER_HOST_LANGUAGES"))
+		languageSettings = hashLanguages(languages, hostLanguages)
+	})
+}
+
+// LanguageAllowed returns true if articles in the language with the given ISO 639-1 code should be kept.
+// Allowed languages are a comma separated list in NEWSPAPER_LANGUAGES (e.g. en,es).
+// All languages are allowed if the list is empty, and unknown languages are always allowed.
+func LanguageAllowed(code string) bool {
+	loadLanguages()
+
+	return allowedIn(languages, code)
+}
+
+// HostLanguageAllowed is LanguageAllowed for articles from a host. Hosts in NEWSPAPER_HOST_LANGUAGES
+// use their own list instead of NEWSPAPER_LANGUAGES. The setting is a comma separated list of host=codes,
+// where codes are separated by | (e.g. www.lemonde.fr=fr,www.elpais.com=es|en).
+// A host also matches its subdomains, and the longest matching host is used.
+func HostLanguageAllowed(host string, code string) bool {
+	loadLanguages()
+
+	return allowedIn(languagesForHost(hostLanguages, languages, host), code)
+}
+
+// allowedIn returns true if the code is in the allowed list, or if the list or code is empty.
+func allowedIn(allowed map[string]bool, code string) bool {
+	if len(allowed) == 0 || len(code) == 0 {
+		return true
+	}
+
+	return allowed[strings.ToLower(code)]
+}
+
+// languagesForHost returns the allowed languages of the longest host setting that matches the host,
+// or the default languages if none does.
+func languagesForHost(byHost map[string]map[string]bool, defaults map[string]bool, host string) map[string]bool {
+	host = strings.ToLower(host)
+	out, longest := defaults, -1
+	for h, allowed := range byHost {
+		if (host == h || strings.HasSuffix(host, "."+h)) && len(h) > longest {
+			out, longest = allowed, len(h)
+		}
+	}
+
+	return out
+}
+
+// parseLanguages reads a list of ISO 639-1 codes separated by sep.
+func parseLanguages(s string, sep string) map[string]bool {
+	out := make(map[string]bool)
+	for _, l := range strings.Split(s, sep) {
+		if l = strings.ToLower(strings.TrimSpace(l)); len(l) > 0 {
+			out[l] = true
+		}
+	}
+
+	return out
+}
+
+// parseHostLanguages reads a comma separated list of host=codes, where codes are separated by |.
+func parseHostLanguages(s string) map[string]map[string]bool {
+	out := make(map[string]map[string]bool)
+	for _, entry := range strings.Split(s, ",") {
+		i := strings.Index(entry, "=")
+		if i < 0 {
+			continue
+		}
+		host := strings.ToLower(strings.TrimSpace(entry[:i]))
+		if len(host) > 0 {
+			out[host] = parseLanguages(entry[i+1:], "|")
+		}
+	}
+
+	return out
+}
+
+// hashLanguages returns a short hash of the language settings that does not depend on their order.
+func hashLanguages(defaults map[string]bool, byHost map[string]map[string]bool) string {
+	list := func(m map[string]bool) string {
+		codes := []string{}
+		for c := range m {
+			codes = append(codes, c)
+		}
+		sort.Strings(codes)
+		return strings.Join(codes, "|")
+	}
+
+	entries := []string{list(defaults)}
+	for host, allowed := range byHost {
+		entries = append(entries, host+"="+list(allowed))
+	}
+	sort.Strings(entries[1:])
+
+	h := fnv.New32a()
+	h.Write([]byte(strings.Join(entries, ",")))
+
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// languageFilteredKey returns the article set member that marks a link as filtered by language.
+// The member includes the language settings, so filtered links are fetched again once the settings change.
+func languageFilteredKey(link string) string {
+	loadLanguages()
+
+	return "language:" + languageSettings + ":" + link
+}
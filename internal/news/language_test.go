@@ -0,0 +1,60 @@
+package news
+
+import (
+	"testing"
+)
+
+func TestLanguagesForHost(t *testing.T) {
+	defaults := parseLanguages(" EN, es ,,", ",")
+	byHost := parseHostLanguages("www.lemonde.fr=fr, example.com = de|EN ,news.example.com=,bad entry")
+
+	tests := []struct {
+		host string
+		code string
+		want bool
+	}{
+		{"www.nytimes.com", "en", true},
+		{"www.nytimes.com", "ES", true},
+		{"www.nytimes.com", "fr", false},
+		{"www.nytimes.com", "", true},
+		{"www.lemonde.fr", "fr", true},
+		{"www.lemonde.fr", "en", false},
+		{"WWW.LEMONDE.FR", "fr", true},
+		{"lemonde.fr", "fr", false},
+		{"example.com", "de", true},
+		{"www.example.com", "de", true},
+		{"www.example.com", "es", false},
+		{"badexample.com", "de", false},
+		// an empty host list allows every language
+		{"news.example.com", "ja", true},
+		{"a.news.example.com", "ja", true},
+	}
+
+	for _, tt := range tests {
+		if got := allowedIn(languagesForHost(byHost, defaults, tt.host), tt.code); got != tt.want {
+			t.Errorf("allowed(%s, %s) = %v, want %v", tt.host, tt.code, got, tt.want)
+		}
+	}
+
+	if allowedIn(parseLanguages("", ","), "ja") != true {
+		t.Error("empty list should allow every language")
+	}
+}
+
+func TestHashLanguages(t *testing.T) {
+	a := hashLanguages(parseLanguages("en,es", ","), parseHostLanguages("a.com=fr,b.com=de|en"))
+	b := hashLanguages(parseLanguages("es,en", ","), parseHostLanguages("b.com=en|de,a.com=fr"))
+	if a != b {
+		t.Errorf("hash depends on order: %s != %s", a, b)
+	}
+
+	for _, changed := range []string{
+		hashLanguages(parseLanguages("en", ","), parseHostLanguages("a.com=fr,b.com=de|en")),
+		hashLanguages(parseLanguages("en,es", ","), parseHostLanguages("a.com=fr")),
+		hashLanguages(parseLanguages("en,es", ","), parseHostLanguages("a.com=fr,b.com=de")),
+	} {
+		if changed == a {
+			t.Errorf("hash %s did not change with the settings", a)
+		}
+	}
+}
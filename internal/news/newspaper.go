@@ -27,8 +27,15 @@ type Newspaper struct {
 // This will usually be a slow call; good to make async.
 // Can return nil if calls failed. Caller should check.
 func NewNewspaper(source *Source) *Newspaper {
+	return NewNewspaperHTML(source, "")
+}
+
+// NewNewspaperHTML is NewNewspaper for a page that has already been fetched.
+// newspaper3k downloads the page itself if html is empty.
+func NewNewspaperHTML(source *Source, html string) *Newspaper {
+	link := source.fetchLink()
 	setup.LogCommon(nil).
-		WithField("Link", source.Link).
+		WithField("Link", link).
 		Info("Processing article")
 
 	// address to call for the newspaper3k application
@@ -47,7 +54,7 @@ func NewNewspaper(source *Source) *Newspaper {
 
 	// Make request
 	response, err := client.Newspaper(context.Background(),
-		&protobuf.NewspaperRequest{Link: source.Link})
+		&protobuf.NewspaperRequest{Link: link, Html: html})
 
 	// handle possible failure codes
 	if err != nil {
@@ -61,16 +68,16 @@ func NewNewspaper(source *Source) *Newspaper {
 
 		// else handle as an error
 		setup.LogCommon(err).
-			WithField("Link", source.Link).
+			WithField("Link", link).
 			Warn("Failed gRPC request")
 
 		return nil
 	}
 
 	// perform link consistency checks
-	if strings.Compare(source.Link, response.GetLink()) != 0 {
+	if strings.Compare(link, response.GetLink()) != 0 {
 		setup.LogCommon(nil).
-			WithField("Link", source.Link).
+			WithField("Link", link).
 			WithField("response", response.GetLink).
 			Error("Links do not match")
 
@@ -86,7 +93,7 @@ func NewNewspaper(source *Source) *Newspaper {
 		Title:     response.GetTitle(),
 		Text:      response.GetText(),
 		Authors:   response.GetAuthors(),
-		Canonical: response.GetCanonical(),
+		Canonical: Canonicalize(response.GetCanonical()),
 		PubDate:   response.GetPubdate(),
 	}
 }
@@ -0,0 +1,278 @@
+package news
+
+import (
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/guregu/null.v3"
+
+	"github.com/wpwilson10/caterpillar/internal/setup"
+)
+
+// Values of published_time_source, the origin of an article's published_time
+const (
+	PublishedFromPage   = "page"   // the article page's metadata, parsed by newspaper3k
+	PublishedFromSource = "source" // the feed, sitemap, or submission that linked the article
+)
+
+// earliest published time accepted, older dates are parsing errors
+var minPublished = time.Date(1995, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// how far past the crawl time a published time may be, allowing for a wrong timezone
+const maxPublishedSkew = 24 * time.Hour
+
+// date layouts tried in order, layouts without a zone are read in the host's timezone
+var dateLayouts = []string{
+	// ISO 8601 and RFC 3339 variants
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999 -0700",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"20060102T150405Z0700",
+	"20060102",
+	// RFC 1123 and other email style dates, with zone names replaced by offsets
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"Mon, 2 Jan 2006 15:04:05",
+	"2 Jan 2006 15:04:05 -0700",
+	"Monday, 02-Jan-06 15:04:05 -0700",
+	"Mon Jan 2 15:04:05 -0700 2006",
+	"Mon Jan 2 15:04:05 2006",
+	// human formats
+	"Monday, January 2, 2006 3:04 PM -0700",
+	"Monday, January 2, 2006 3:04 PM",
+	"Monday, January 2, 2006",
+	"January 2, 2006 3:04 PM -0700",
+	"January 2, 2006 3:04 PM",
+	"January 2, 2006 15:04 -0700",
+	"January 2, 2006 15:04",
+	"January 2, 2006",
+	"Jan 2, 2006 3:04 PM -0700",
+	"Jan 2, 2006 3:04 PM",
+	"Jan 2, 2006 15:04",
+	"Jan 2, 2006",
+	"2 January 2006 15:04 -0700",
+	"2 January 2006 15:04",
+	"2 January 2006",
+	"2 Jan 2006 15:04",
+	"2 Jan 2006",
+	"3:04 PM -0700, January 2, 2006",
+	"3:04 PM, January 2, 2006",
+	"1/2/2006 3:04 PM",
+	"1/2/2006 15:04",
+	"1/2/2006",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+	"2.1.2006 15:04",
+	"2.1.2006",
+}
+
+// zone abbreviations commonly found in dates, as numeric offsets
+var zoneOffsets = map[string]string{
+	"UT": "+0000", "UTC": "+0000", "GMT": "+0000", "Z": "+0000",
+	"EST": "-0500", "EDT": "-0400", "CST": "-0600", "CDT": "-0500",
+	"MST": "-0700", "MDT": "-0600", "PST": "-0800", "PDT": "-0700",
+	"BST": "+0100", "CET": "+0100", "CEST": "+0200", "IST": "+0530",
+	"JST": "+0900", "KST": "+0900", "AEST": "+1000", "AEDT": "+1100",
+}
+
+// zone names without a fixed offset, read in the named timezone
+var zoneNames = map[string]string{
+	"ET": "America/New_York",
+	"CT": "America/Chicago",
+	"MT": "America/Denver",
+	"PT": "America/Los_Angeles",
+}
+
+// timezones by lower case ISO 3166-1 alpha-2 code, used for feed regions and country code hosts
+var countryZones = map[string]string{
+	"au": "Australia/Sydney", "br": "America/Sao_Paulo", "ca": "America/Toronto", "ch": "Europe/Zurich",
+	"cn": "Asia/Shanghai", "de": "Europe/Berlin", "es": "Europe/Madrid", "fr": "Europe/Paris",
+	"gb": "Europe/London", "hk": "Asia/Hong_Kong", "ie": "Europe/Dublin", "in": "Asia/Kolkata",
+	"it": "Europe/Rome", "jp": "Asia/Tokyo", "kr": "Asia/Seoul", "mx": "America/Mexico_City",
+	"nl": "Europe/Amsterdam", "nz": "Pacific/Auckland", "pl": "Europe/Warsaw", "pt": "Europe/Lisbon",
+	"ru": "Europe/Moscow", "se": "Europe/Stockholm", "sg": "Asia/Singapore", "us": "America/New_York",
+	"za": "Africa/Johannesburg",
+}
+
+// text around dates that is not part of the date
+var dateReplacer = strings.NewReplacer(
+	" at ", " ",
+	" @ ", " ",
+	"Sept.", "Sep",
+	"Sept ", "Sep ",
+	"a.m.", "AM",
+	"p.m.", "PM",
+	" am", " AM",
+	" pm", " PM",
+	"Jan.", "Jan", "Feb.", "Feb", "Mar.", "Mar", "Apr.", "Apr", "Jun.", "Jun", "Jul.", "Jul",
+	"Aug.", "Aug", "Sep.", "Sep", "Oct.", "Oct", "Nov.", "Nov", "Dec.", "Dec",
+)
+
+// prefixes before dates on article pages
+var datePrefixes = []string{"published:", "published", "updated:", "updated", "posted:", "posted", "on"}
+
+// ParseDate parses a publication date in RFC 1123, ISO 8601, Unix epoch, or a common human format.
+// Dates without a timezone are read in loc. Returns false if the date can not be parsed.
+func ParseDate(s string, loc *time.Location) (time.Time, bool) {
+	s = strings.Join(strings.Fields(s), " ")
+	for _, p := range datePrefixes {
+		if len(s) > len(p) && strings.EqualFold(s[:len(p)], p) && s[len(p)] == ' ' {
+			s = s[len(p)+1:]
+		}
+	}
+	if len(s) == 0 {
+		return time.Time{}, false
+	}
+
+	// Unix epoch in seconds or milliseconds
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		switch len(s) {
+		case 10:
+			return time.Unix(n, 0).UTC(), true
+		case 13:
+			return time.Unix(0, n*int64(time.Millisecond)).UTC(), true
+		}
+	}
+
+	s = dateReplacer.Replace(s)
+	// replace a trailing zone name
+	if i := strings.LastIndexAny(s, " +-"); i > 0 && s[i] == ' ' {
+		zone := strings.Trim(s[i+1:], "()")
+		if offset, ok := zoneOffsets[zone]; ok {
+			s = s[:i] + " " + offset
+		} else if name, ok := zoneNames[zone]; ok {
+			s = s[:i]
+			loc = loadLocation(name)
+		}
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// loaded timezones by name
+var locations = struct {
+	sync.Mutex
+	m map[string]*time.Location
+}{m: make(map[string]*time.Location)}
+
+// loadLocation returns the named timezone, or UTC if it is not available.
+func loadLocation(name string) *time.Location {
+	locations.Lock()
+	defer locations.Unlock()
+
+	if loc, ok := locations.m[name]; ok {
+		return loc
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("timezone", name).
+			Warn("Failed time.LoadLocation")
+		loc = time.UTC
+	}
+	locations.m[name] = loc
+
+	return loc
+}
+
+// HostLocation infers the timezone of a news site for dates that do not give one.
+// Uses the catalog feed's region if known, then the link's country code domain,
+// then NEWSPAPER_TIMEZONE, and defaults to UTC.
+func HostLocation(link string, region string) *time.Location {
+	if name, ok := countryZones[strings.ToLower(region)]; ok {
+		return loadLocation(name)
+	}
+
+	if u, err := url.Parse(link); err == nil {
+		tld := u.Hostname()
+		if i := strings.LastIndex(tld, "."); i >= 0 {
+			tld = tld[i+1:]
+		}
+		if tld == "uk" {
+			tld = "gb"
+		}
+		if name, ok := countryZones[tld]; ok {
+			return loadLocation(name)
+		}
+	}
+
+	if name := os.Getenv("NEWSPAPER_TIMEZONE"); len(name) > 0 {
+		return loadLocation(name)
+	}
+
+	return time.UTC
+}
+
+// plausiblePublished returns true if the time could be a publication time for an article crawled at crawlTime.
+func plausiblePublished(t time.Time, crawlTime time.Time) bool {
+	return !t.Before(minPublished) && !t.After(crawlTime.Add(maxPublishedSkew))
+}
+
+// publishedTimes returns the article's source published time, published time, and published time source.
+// Times that are impossible for the crawl time are dropped. The page's time is used as the published time
+// unless it is missing or later than the source's time, since a link can not be shared before it is published,
+// in which case the source's time is used.
+func publishedTimes(pubDate string, source *Source, crawlTime time.Time) (null.Time, null.Time, null.String) {
+	var sourceTime, pageTime null.Time
+
+	if source.PubDate != nil {
+		if plausiblePublished(*source.PubDate, crawlTime) {
+			sourceTime = null.TimeFrom(*source.PubDate)
+		} else {
+			setup.LogCommon(nil).
+				WithField("Link", source.Link).
+				WithField("pubDate", *source.PubDate).
+				Warn("Implausible source published time")
+		}
+	}
+
+	if len(strings.TrimSpace(pubDate)) > 0 {
+		t, ok := ParseDate(pubDate, HostLocation(source.Link, source.Region))
+		if !ok {
+			setup.LogCommon(nil).
+				WithField("Link", source.Link).
+				WithField("pubDate", pubDate).
+				Warn("Failed ParseDate")
+		} else if !plausiblePublished(t, crawlTime) {
+			setup.LogCommon(nil).
+				WithField("Link", source.Link).
+				WithField("pubDate", pubDate).
+				Warn("Implausible published time")
+		} else if sourceTime.Valid && t.After(sourceTime.Time.Add(maxPublishedSkew)) {
+			setup.LogCommon(nil).
+				WithField("Link", source.Link).
+				WithField("pubDate", pubDate).
+				WithField("sourcePubDate", sourceTime.Time).
+				Warn("Published time after source published time")
+		} else {
+			pageTime = null.TimeFrom(t)
+		}
+	}
+
+	switch {
+	case pageTime.Valid:
+		return sourceTime, pageTime, null.StringFrom(PublishedFromPage)
+	case sourceTime.Valid:
+		return sourceTime, sourceTime, null.StringFrom(PublishedFromSource)
+	}
+
+	return sourceTime, null.Time{}, null.String{}
+}
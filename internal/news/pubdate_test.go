@@ -0,0 +1,89 @@
+package news
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	ny := loadLocation("America/New_York")
+	tests := []struct {
+		s    string
+		want time.Time
+	}{
+		{"2020-03-04T05:06:07Z", time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)},
+		{"2020-03-04T05:06:07.123-05:00", time.Date(2020, 3, 4, 10, 6, 7, 123000000, time.UTC)},
+		{"2020-03-04T05:06:07+0100", time.Date(2020, 3, 4, 4, 6, 7, 0, time.UTC)},
+		{"2020-03-04 05:06:07", time.Date(2020, 3, 4, 10, 6, 7, 0, time.UTC)},
+		{"2020-03-04", time.Date(2020, 3, 4, 5, 0, 0, 0, time.UTC)},
+		{"Wed, 4 Mar 2020 05:06:07 GMT", time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)},
+		{"Wed, 04 Mar 2020 05:06:07 EST", time.Date(2020, 3, 4, 10, 6, 7, 0, time.UTC)},
+		{"Wed, 04 Mar 2020 05:06:07 +0000", time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)},
+		{"1583298367", time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)},
+		{"1583298367123", time.Date(2020, 3, 4, 5, 6, 7, 123000000, time.UTC)},
+		{"March 4, 2020", time.Date(2020, 3, 4, 5, 0, 0, 0, time.UTC)},
+		{"Published: Sept. 4, 2020 at 5:06 p.m. ET", time.Date(2020, 9, 4, 21, 6, 0, 0, time.UTC)},
+		{"Updated  Mar. 4, 2020  3:04 PM", time.Date(2020, 3, 4, 20, 4, 0, 0, time.UTC)},
+		{"4 March 2020", time.Date(2020, 3, 4, 5, 0, 0, 0, time.UTC)},
+		{"3/4/2020", time.Date(2020, 3, 4, 5, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParseDate(tt.s, ny)
+		if !ok || !got.Equal(tt.want) {
+			t.Errorf("ParseDate(%q) = %v, %v, want %v", tt.s, got.UTC(), ok, tt.want)
+		}
+	}
+
+	for _, s := range []string{"", "yesterday", "12345", "2020-13-45"} {
+		if got, ok := ParseDate(s, ny); ok {
+			t.Errorf("ParseDate(%q) = %v, want failure", s, got)
+		}
+	}
+}
+
+func TestHostLocation(t *testing.T) {
+	tests := []struct {
+		link   string
+		region string
+		want   string
+	}{
+		{"https://www.lemonde.fr/a", "", "Europe/Paris"},
+		{"https://www.bbc.co.uk/a", "", "Europe/London"},
+		{"https://www.example.com/a", "jp", "Asia/Tokyo"},
+		{"https://www.lemonde.fr/a", "US", "America/New_York"},
+	}
+
+	for _, tt := range tests {
+		if got := HostLocation(tt.link, tt.region).String(); got != tt.want {
+			t.Errorf("HostLocation(%q, %q) = %s, want %s", tt.link, tt.region, got, tt.want)
+		}
+	}
+}
+
+func TestPublishedTimes(t *testing.T) {
+	crawl := time.Date(2020, 3, 5, 0, 0, 0, 0, time.UTC)
+	shared := time.Date(2020, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		pubDate    string
+		sourceTime *time.Time
+		want       time.Time
+		from       string
+	}{
+		{"2020-03-04T05:06:07Z", &shared, time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC), PublishedFromPage},
+		{"", &shared, shared, PublishedFromSource},
+		{"not a date", &shared, shared, PublishedFromSource},
+		// in the future, or before the link was shared
+		{"2020-03-09T00:00:00Z", &shared, shared, PublishedFromSource},
+		{"2020-03-05T13:00:00Z", &shared, shared, PublishedFromSource},
+		{"1970-01-01T00:00:00Z", nil, time.Time{}, ""},
+	}
+
+	for _, tt := range tests {
+		_, published, from := publishedTimes(tt.pubDate, &Source{Link: "https://www.example.com/a", PubDate: tt.sourceTime}, crawl)
+		if !published.Time.Equal(tt.want) || from.String != tt.from {
+			t.Errorf("publishedTimes(%q) = %v, %q, want %v, %q", tt.pubDate, published.Time, from.String, tt.want, tt.from)
+		}
+	}
+}
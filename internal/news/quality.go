@@ -0,0 +1,250 @@
+package news
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/guregu/null.v3"
+
+	"github.com/wpwilson10/caterpillar/internal/setup"
+)
+
+// Extraction status values stored in NewsArticle.extraction_status
+const (
+	ExtractionOK        = "ok"        // looks like a full article
+	ExtractionPaywall   = "paywall"   // subscription teaser instead of the article
+	ExtractionBotWall   = "botwall"   // cookie wall, javascript wall, or bot check instead of the article
+	ExtractionTruncated = "truncated" // article cut off with a read more marker
+	ExtractionShort     = "short"     // much shorter than the host's typical article
+)
+
+// Quality thresholds
+const (
+	minTypicalSamples = 10   // ok articles needed before a host's typical length is trusted
+	shortRatio        = 0.25 // bodies below this share of the host's typical length are short
+	shortLength       = 200  // bodies below this length are short for hosts without a typical length
+	wallMaxLength     = 1500 // longest body that can be a wall page, longer ones are articles that mention the phrase
+	tailLength        = 300  // characters at the end of the body checked for paywall and truncation markers
+	tailMaxLength     = 4000 // longest body that can be a teaser, longer ones are articles with a footer
+	typicalWeight     = 0.1  // weight of the newest value in the typical length average
+)
+
+// phrases in cookie walls, javascript walls, and bot checks
+var botWallPhrases = []string{
+	"enable javascript", "javascript is disabled", "javascript is required", "browser does not support javascript",
+	"enable cookies", "cookies are disabled", "we use cookies", "cookie settings", "accept all cookies",
+	"are you a robot", "not a robot", "verify you are human", "verify that you are human", "captcha",
+	"access denied", "unusual traffic", "checking your browser", "request unsuccessful",
+}
+
+// phrases in subscription teasers
+var paywallPhrases = []string{
+	"subscribe to continue", "subscribe to read", "subscribers only", "for subscribers", "subscriber-only",
+	"already a subscriber", "already have an account", "sign in to continue", "sign in to read",
+	"log in to continue", "create a free account", "register to continue", "to continue reading",
+	"unlock this article", "premium content", "premium article", "become a member",
+	"free articles remaining", "reached your limit", "reached the limit", "subscription required",
+}
+
+// markers at the end of a truncated body
+var truncationMarkers = []string{
+	"[…]", "[...]", "(continued)", "read more", "continue reading", "read the full story",
+	"read the full article", "read full article", "full story", "click here to read",
+}
+
+// HostQuality stores extraction statistics for a host in a format matching the NewsHostQuality table schema.
+type HostQuality struct {
+	Host          string     `db:"host"`
+	NumArticles   int64      `db:"num_articles"`
+	NumOK         int64      `db:"num_ok"`
+	NumPaywall    int64      `db:"num_paywall"`
+	NumBotWall    int64      `db:"num_botwall"`
+	NumTruncated  int64      `db:"num_truncated"`
+	NumShort      int64      `db:"num_short"`
+	TypicalLength null.Float `db:"typical_length"` // moving average body length of ok articles
+	UpdatedTime   time.Time  `db:"updated_time"`
+}
+
+// ClassifyBody returns the extraction status of an article body given its host's statistics,
+// which may be nil for a host that has not been seen.
+func ClassifyBody(body string, host *HostQuality) string {
+	clean := strings.ToLower(strings.TrimSpace(body))
+	tail := clean
+	if len(tail) > tailLength {
+		tail = tail[len(tail)-tailLength:]
+	}
+
+	// wall pages are short, long articles may mention the same phrases
+	if len(clean) <= wallMaxLength && containsAny(clean, botWallPhrases) {
+		return ExtractionBotWall
+	}
+	// teasers put the subscription prompt at the end, or are nothing but the prompt
+	if (len(clean) <= tailMaxLength && containsAny(tail, paywallPhrases)) ||
+		(len(clean) <= wallMaxLength && containsAny(clean, paywallPhrases)) {
+		return ExtractionPaywall
+	}
+	if len(clean) <= tailMaxLength && endsWithAny(tail, truncationMarkers) {
+		return ExtractionTruncated
+	}
+
+	if host != nil && host.NumOK >= minTypicalSamples && host.TypicalLength.Valid {
+		if float64(len(clean)) < host.TypicalLength.Float64*shortRatio {
+			return ExtractionShort
+		}
+	} else if len(clean) < shortLength {
+		return ExtractionShort
+	}
+
+	return ExtractionOK
+}
+
+// containsAny returns true if s contains any of the phrases.
+func containsAny(s string, phrases []string) bool {
+	for _, p := range phrases {
+		if strings.Contains(s, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// endsWithAny returns true if s ends with any of the markers, ignoring trailing punctuation after words.
+func endsWithAny(s string, markers []string) bool {
+	s = strings.TrimSpace(s)
+	trimmed := strings.TrimRight(s, " .:!>»)")
+	for _, m := range markers {
+		if strings.HasSuffix(s, m) {
+			return true
+		}
+		if word := strings.TrimRight(m, ".)"); len(word) > 0 && strings.HasSuffix(trimmed, word) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LoadHostQuality returns the host's extraction statistics, or nil if the host has none.
+func LoadHostQuality(ctx context.Context, db *sqlx.DB, host string) (*HostQuality, error) {
+	out := HostQuality{}
+	err := db.GetContext(ctx, &out, "SELECT * FROM NewsHostQuality WHERE host = $1", host)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// RecordExtraction adds an article's extraction status to its host's statistics.
+// The body length of ok articles updates the host's typical length.
+func RecordExtraction(ctx context.Context, db *sqlx.DB, host string, status string, bodyLength int) error {
+	var insertStmt string = `INSERT INTO NewsHostQuality (
+								host, num_articles, num_ok, num_paywall, num_botwall, num_truncated, num_short,
+								typical_length, updated_time
+								)
+							 VALUES ($1, 1, $2, $3, $4, $5, $6, $7, Now())
+							 ON CONFLICT (host) DO UPDATE SET
+								num_articles = NewsHostQuality.num_articles + 1,
+								num_ok = NewsHostQuality.num_ok + EXCLUDED.num_ok,
+								num_paywall = NewsHostQuality.num_paywall + EXCLUDED.num_paywall,
+								num_botwall = NewsHostQuality.num_botwall + EXCLUDED.num_botwall,
+								num_truncated = NewsHostQuality.num_truncated + EXCLUDED.num_truncated,
+								num_short = NewsHostQuality.num_short + EXCLUDED.num_short,
+								typical_length = CASE
+									WHEN EXCLUDED.typical_length IS NULL THEN NewsHostQuality.typical_length
+									WHEN NewsHostQuality.typical_length IS NULL THEN EXCLUDED.typical_length
+									ELSE NewsHostQuality.typical_length * (1 - $8) + EXCLUDED.typical_length * $8
+								END,
+								updated_time = EXCLUDED.updated_time`
+
+	// one counter is incremented for the status
+	counts := map[string]int{status: 1}
+	var length null.Float
+	if status == ExtractionOK {
+		length = null.FloatFrom(float64(bodyLength))
+	}
+
+	_, err := db.ExecContext(ctx, insertStmt, host,
+		counts[ExtractionOK],
+		counts[ExtractionPaywall],
+		counts[ExtractionBotWall],
+		counts[ExtractionTruncated],
+		counts[ExtractionShort],
+		length,
+		typicalWeight)
+
+	return err
+}
+
+// classifyArticle sets the article's extraction status using its host's statistics.
+// Host statistics are optional, so failing to load them only logs.
+func classifyArticle(ctx context.Context, db *sqlx.DB, article *Article) {
+	host, err := LoadHostQuality(ctx, db, article.Host)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("host", article.Host).
+			Warn("Failed LoadHostQuality")
+	}
+
+	article.ExtractionStatus = null.StringFrom(ClassifyBody(article.Body.String, host))
+}
+
+// recordArticleExtraction adds the article to its host's statistics, logging on failure.
+func recordArticleExtraction(ctx context.Context, db *sqlx.DB, article *Article) {
+	err := RecordExtraction(ctx, db, article.Host, article.ExtractionStatus.String, len(article.Body.String))
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("host", article.Host).
+			Error("Failed RecordExtraction")
+	}
+}
+
+// HostQualityApp prints the hosts with at least minArticles articles, ordered by their share of
+// paywalled and walled articles.
+func HostQualityApp(minArticles int) {
+	ctx := context.Background()
+	// connect to database
+	db, err := setup.SQL(ctx)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to SQL database")
+	}
+
+	hosts := []*HostQuality{}
+	err = db.SelectContext(ctx, &hosts,
+		`SELECT * FROM NewsHostQuality WHERE num_articles >= $1
+		 ORDER BY (num_paywall + num_botwall)::float / num_articles DESC, num_articles DESC`, minArticles)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Failed select NewsHostQuality")
+	}
+
+	for _, h := range hosts {
+		fmt.Printf("%s articles=%d paywall=%.0f%% botwall=%.0f%% truncated=%.0f%% short=%.0f%% typical=%.0f\n",
+			h.Host, h.NumArticles,
+			percent(h.NumPaywall, h.NumArticles),
+			percent(h.NumBotWall, h.NumArticles),
+			percent(h.NumTruncated, h.NumArticles),
+			percent(h.NumShort, h.NumArticles),
+			h.TypicalLength.Float64)
+	}
+
+	setup.LogCommon(nil).
+		WithField("NumHosts", len(hosts)).
+		WithField("RunTime", setup.RunTime().String()).
+		Info("RunSummary")
+}
+
+// percent returns n as a percentage of total.
+func percent(n int64, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return float64(n) * 100 / float64(total)
+}
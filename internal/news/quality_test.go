@@ -0,0 +1,43 @@
+package news
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestClassifyBody(t *testing.T) {
+	para := "The city council voted on Monday to approve a new budget for buses and library repairs. "
+	article := strings.Repeat(para, 10)
+	long := strings.Repeat(para, 60)
+	typical := &HostQuality{NumOK: minTypicalSamples, TypicalLength: null.FloatFrom(float64(len(long)))}
+
+	tests := []struct {
+		name string
+		body string
+		host *HostQuality
+		want string
+	}{
+		{"article", article, nil, ExtractionOK},
+		{"empty", "", nil, ExtractionShort},
+		{"short", para, nil, ExtractionShort},
+		{"short for host", article, typical, ExtractionShort},
+		{"typical for host", long, typical, ExtractionOK},
+		{"bot wall", "Please enable JavaScript and cookies to continue. " + para, nil, ExtractionBotWall},
+		{"long article about cookies", long + "We use cookies. ", nil, ExtractionOK},
+		{"paywall teaser", article + "Subscribe to continue reading.", nil, ExtractionPaywall},
+		{"paywall prompt", "This article is for subscribers only.", nil, ExtractionPaywall},
+		{"long article with footer", long + "Already have an account? Sign in.", nil, ExtractionOK},
+		{"truncated", article + "Read more »", nil, ExtractionTruncated},
+		{"truncated brackets", article + "[...]", nil, ExtractionTruncated},
+		{"long article ending in read more", long + "Read more", nil, ExtractionOK},
+		{"ellipsis", article + "And then...", nil, ExtractionOK},
+	}
+
+	for _, tt := range tests {
+		if got := ClassifyBody(tt.body, tt.host); got != tt.want {
+			t.Errorf("%s: ClassifyBody = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
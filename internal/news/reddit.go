@@ -1,12 +1,11 @@
 package news
 
 import (
-	"strings"
+	"context"
 	"time"
 
 	"github.com/jmoiron/sqlx"
 
-	"github.com/wpwilson10/caterpillar/internal/redis"
 	"github.com/wpwilson10/caterpillar/internal/setup"
 )
 
@@ -28,9 +27,9 @@ func NewRedditNews(articleID int64, submissionID int64) *RedditNews {
 	return &link
 }
 
-// Insert adds this redditnews relationship to the database table.
-// Performs no validation.
-func (link *RedditNews) Insert(db *sqlx.DB) {
+// Insert adds this redditnews relationship to the database table, doing nothing if it already exists.
+// Performs no validation. Returns true if the relationship was created.
+func (link *RedditNews) Insert(db *sqlx.DB) bool {
 	// Setup
 	var insertStmt string = `INSERT INTO RedditNews (
 								data_entry_time, 	-- Now()
@@ -44,87 +43,67 @@ func (link *RedditNews) Insert(db *sqlx.DB) {
 								:submission_id
 								)`
 
-	var fullStmt string = insertStmt + " " + valueStmt
+	// a relationship has nothing to update, so seeing the same pair again keeps the first entry time
+	var conflictStmt string = "ON CONFLICT (article_id, submission_id) DO NOTHING"
 
-	// Insert article
-	_, err := db.NamedExec(fullStmt, link)
+	var fullStmt string = insertStmt + " " + valueStmt + " " + conflictStmt
 
+	// Insert article
+	result, err := db.NamedExec(fullStmt, link)
 	if err != nil {
 		setup.LogCommon(err).
 			WithField("ArticleID", link.ArticleID).
 			WithField("SubmissionID", link.SubmissionID).
 			Error("db.NamedExec")
+		return false
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("ArticleID", link.ArticleID).
+			WithField("SubmissionID", link.SubmissionID).
+			Error("Failed RowsAffected")
+		return false
 	}
 
 	setup.LogCommon(nil).
 		WithField("ArticleID", link.ArticleID).
 		WithField("SubmissionID", link.SubmissionID).
+		WithField("created", n > 0).
 		Info("Inserting article")
 
+	return n > 0
 }
 
-// SourceListFromReddit returns source objects from reddit submissions.
-func SourceListFromReddit(articleSet *redis.Set, db *sqlx.DB) []*Source {
-	// get links from reddit submissions
-	redditArticles := newRedditArticles(db)
-
-	// iterate through each reddit link to create source structs
-	// and filter out links we have already seen
-	sources := []*Source{}
-	for _, r := range redditArticles {
-		// check if we have seen this link before
-		if !articleSet.IsMember(r.Link) {
-			// convert feed article to standard source
-			source := NewSource(FromRedditArticle(r))
-			// add to list if we got something
-			if len(source.Link) > 1 {
-				sources = append(sources, source)
-			}
-		}
-	}
-
-	return sources
-}
-
-// For future backfills below
-
 // RedditArticle represents external links from reddit submissions.
 type RedditArticle struct {
-	Title    string     `db:"title"`
-	Link     string     `db:"url"`
-	PubDate  *time.Time `db:"created_time"`
-	DataTime *time.Time `db:"data_entry_time"`
+	SubmissionID int64      `db:"submission_id"`
+	Title        string     `db:"title"`
+	Link         string     `db:"url"`
+	PubDate      *time.Time `db:"created_time"`
+	DataTime     *time.Time `db:"data_entry_time"`
 }
 
-// newRedditArticles returns a list of objects representing valid links from reddit submissions.
-func newRedditArticles(db *sqlx.DB) []*RedditArticle {
-	// --- Get Submissions
-	/* var selectStmt string = `SELECT title, url, created_time, data_entry_time FROM RedditSubmission
-	WHERE data_entry_time BETWEEN CURRENT_DATE - 2 AND CURRENT_DATE - 1
-		AND LENGTH(url) > 2
-	ORDER BY created_time ASC`
-	*/
-
-	var selectStmt string = `SELECT title, url, created_time, data_entry_time FROM RedditSubmission
-							 WHERE LENGTH(url) > 2
-							 ORDER BY created_time ASC`
-
-	submissions := []RedditArticle{}
+// newRedditArticles returns up to limit valid links from reddit submissions created in [from, to),
+// ordered by submission ID and starting after the given submission ID.
+// Self posts and links to reddit hosted media are left out, matching the links the reddit driver crawls.
+func newRedditArticles(ctx context.Context, db *sqlx.DB, from time.Time, to time.Time, after int64, limit int) ([]*RedditArticle, error) {
+	var selectStmt string = `SELECT submission_id, title, url, created_time, data_entry_time FROM RedditSubmission
+							 WHERE created_time >= $1 AND created_time < $2
+								AND submission_id > $3
+								AND LENGTH(url) > 2
+								AND NOT COALESCE(is_self, false)
+								AND url !~* '^[a-z]+://([^/?#]*\.)?(reddit\.com|redd\.it)([:/?#]|$)'
+							 ORDER BY submission_id ASC
+							 LIMIT $4`
+
+	submissions := []*RedditArticle{}
 
 	// pull submissions from database
-	err := db.Select(&submissions, selectStmt)
+	err := db.SelectContext(ctx, &submissions, selectStmt, from, to, after, limit)
 	if err != nil {
-		setup.LogCommon(err).Fatal("Failed Select Statement")
-	}
-
-	// url cleanup and validation that URL is good
-	out := []*RedditArticle{}
-	for _, s := range submissions {
-		s.Link = strings.TrimSpace(s.Link)
-		if setup.IsValidURL(s.Link) {
-			out = append(out, &s)
-		}
+		return nil, err
 	}
 
-	return out
+	return submissions, nil
 }
@@ -0,0 +1,417 @@
+package news
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/guregu/null.v3"
+
+	"github.com/wpwilson10/caterpillar/internal/setup"
+)
+
+// default ages at which articles are crawled again
+const defaultRecrawlAges = "1h,24h,7d"
+
+// how long after the last recrawl age an article may still be recrawled, so late runs catch up
+const recrawlGrace = 24 * time.Hour
+
+// largest body in lines that is diffed line by line, longer bodies are diffed as a single replacement
+const maxDiffLines = 2000
+
+// Revision stores a changed version of an article in a format matching the ArticleRevision table schema.
+type Revision struct {
+	RevisionID     int64       `db:"revision_id"`
+	ArticleID      int64       `db:"article_id"`
+	DataTime       time.Time   `db:"data_entry_time"`
+	RevisionNumber int         `db:"revision_number"` // 1 for the first change after the original
+	RecrawlAge     string      `db:"recrawl_age"`     // age of the article when it was recrawled (e.g. 24h)
+	Title          null.String `db:"title"`
+	Body           null.String `db:"body"`
+	TitleChanged   bool        `db:"title_changed"`
+	BodyChanged    bool        `db:"body_changed"`
+	Diff           string      `db:"diff"` // line diff from the previous version
+}
+
+// recrawlCandidate is an article due for a recrawl along with its latest known title and body.
+type recrawlCandidate struct {
+	ArticleID int64       `db:"article_id"`
+	Source    string      `db:"source"`
+	Host      string      `db:"host"`
+	Link      string      `db:"link"`
+	FetchLink null.String `db:"fetch_link"` // url the article was first fetched at if it differs from link
+	Title     null.String `db:"title"`
+	Body      null.String `db:"body"`
+	stage     int         // recrawl stage this candidate is due for, starting at 1
+	age       string      // label of the stage's age
+}
+
+// RecrawlAges parses a comma separated list of ages such as 1h,24h,7d into ascending durations.
+// Accepts any time.ParseDuration value plus a d suffix for days.
+func RecrawlAges(s string) ([]time.Duration, []string, error) {
+	type age struct {
+		d     time.Duration
+		label string
+	}
+
+	ages := []age{}
+	for _, label := range strings.Split(s, ",") {
+		label = strings.TrimSpace(label)
+		if len(label) == 0 {
+			continue
+		}
+
+		var d time.Duration
+		if strings.HasSuffix(label, "d") {
+			days, err := strconv.Atoi(strings.TrimSuffix(label, "d"))
+			if err != nil {
+				return nil, nil, fmt.Errorf("recrawl age %s: %w", label, err)
+			}
+			d = time.Duration(days) * 24 * time.Hour
+		} else {
+			var err error
+			d, err = time.ParseDuration(label)
+			if err != nil {
+				return nil, nil, fmt.Errorf("recrawl age %s: %w", label, err)
+			}
+		}
+		if d <= 0 {
+			return nil, nil, fmt.Errorf("recrawl age %s is not positive", label)
+		}
+		ages = append(ages, age{d: d, label: label})
+	}
+
+	sort.Slice(ages, func(i, j int) bool {
+		return ages[i].d < ages[j].d
+	})
+
+	durations := make([]time.Duration, len(ages))
+	labels := make([]string, len(ages))
+	for i, a := range ages {
+		durations[i] = a.d
+		labels[i] = a.label
+	}
+
+	return durations, labels, nil
+}
+
+// recrawlCandidates returns up to limit articles for each recrawl stage that have reached
+// the stage's age but not the next one, and have not yet been recrawled for that stage.
+func recrawlCandidates(ctx context.Context, db *sqlx.DB, ages []time.Duration, labels []string, now time.Time, limit int) ([]*recrawlCandidate, error) {
+	// latest title and body come from the newest revision if there is one
+	var selectStmt string = `SELECT a.article_id, a.source, a.host, a.link, a.fetch_link,
+								COALESCE(rev.title, a.title) AS title,
+								COALESCE(rev.body, a.body) AS body
+							 FROM NewsArticle a
+							 LEFT JOIN ArticleRecrawl r ON r.article_id = a.article_id
+							 LEFT JOIN LATERAL (
+								SELECT title, body FROM ArticleRevision
+								WHERE article_id = a.article_id
+								ORDER BY revision_id DESC
+								LIMIT 1
+							 ) rev ON true
+							 WHERE a.data_entry_time <= $1 AND a.data_entry_time > $2
+								AND COALESCE(r.recrawl_count, 0) < $3
+							 ORDER BY a.data_entry_time ASC
+							 LIMIT $4`
+
+	out := []*recrawlCandidate{}
+	for i, age := range ages {
+		// articles older than the next stage's age are handled by that stage
+		oldest := now.Add(-ages[len(ages)-1] - recrawlGrace)
+		if i+1 < len(ages) {
+			oldest = now.Add(-ages[i+1])
+		}
+
+		stage := []*recrawlCandidate{}
+		err := db.SelectContext(ctx, &stage, selectStmt, now.Add(-age), oldest, i+1, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range stage {
+			c.stage = i + 1
+			c.age = labels[i]
+		}
+		out = append(out, stage...)
+	}
+
+	return out, nil
+}
+
+// recrawl gets the article again and saves a revision if its title or body changed.
+// Returns the revision if one was saved.
+func recrawl(ctx context.Context, db *sqlx.DB, robots *RobotsCache, c *recrawlCandidate) *Revision {
+	// the canonicalized link may not exist (e.g. amp and mobile pages), so fetch the url that worked the first time
+	source := &Source{Link: c.Link, FetchLink: c.FetchLink.String, Host: c.Host, Source: c.Source}
+
+	// the host may have changed its robots.txt since the first crawl
+	if !robotsAllowed(ctx, db, robots, source) {
+		return nil
+	}
+
+	// Make sure the server is running
+	setup.CheckPythonServer()
+
+	// archive the raw page if enabled, then call newspaper3k to get data
+	html, archiveID := archivePage(ctx, db, source)
+	linkArchive(ctx, db, archiveID, c.ArticleID)
+	newspaper := NewNewspaperHTML(source, html)
+	if newspaper == nil {
+		return nil
+	}
+
+	// only compare values that are worth storing
+	newTitle := title(newspaper.Title)
+	newBody := body(newspaper.Text, source)
+	titleChanged := newTitle.Valid && newTitle.String != c.Title.String
+	bodyChanged := newBody.Valid && newBody.String != c.Body.String
+	if !titleChanged && !bodyChanged {
+		return nil
+	}
+
+	rev := &Revision{
+		ArticleID:    c.ArticleID,
+		DataTime:     time.Now(),
+		RecrawlAge:   c.age,
+		Title:        c.Title,
+		Body:         c.Body,
+		TitleChanged: titleChanged,
+		BodyChanged:  bodyChanged,
+	}
+	if titleChanged {
+		rev.Title = newTitle
+		rev.Diff = "--- title\n" + lineDiff(c.Title.String, newTitle.String)
+	}
+	if bodyChanged {
+		rev.Body = newBody
+		rev.Diff = rev.Diff + "--- body\n" + lineDiff(c.Body.String, newBody.String)
+	}
+
+	err := rev.Insert(ctx, db)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("ArticleID", c.ArticleID).
+			Error("Failed Revision Insert")
+		return nil
+	}
+
+	return rev
+}
+
+// Insert adds this revision to the ArticleRevision table with the next revision number for its article.
+// Updates RevisionID and RevisionNumber to the real database values.
+func (rev *Revision) Insert(ctx context.Context, db *sqlx.DB) error {
+	var insertStmt string = `INSERT INTO ArticleRevision (
+								article_id,
+								data_entry_time,
+								revision_number,
+								recrawl_age,
+								title,
+								body,
+								title_changed,
+								body_changed,
+								diff
+								)`
+
+	var valueStmt string = `VALUES (
+								:article_id,
+								:data_entry_time,
+								(SELECT COUNT(*) + 1 FROM ArticleRevision WHERE article_id = :article_id),
+								:recrawl_age,
+								:title,
+								:body,
+								:title_changed,
+								:body_changed,
+								:diff
+								)`
+
+	var returnStmt string = "RETURNING revision_id, revision_number"
+
+	stmt, err := db.PrepareNamedContext(ctx, insertStmt+" "+valueStmt+" "+returnStmt)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	return stmt.QueryRowxContext(ctx, rev).Scan(&rev.RevisionID, &rev.RevisionNumber)
+}
+
+// markRecrawled records that the article has been recrawled for the given stage.
+func markRecrawled(ctx context.Context, db *sqlx.DB, articleID int64, stage int) error {
+	var insertStmt string = `INSERT INTO ArticleRecrawl (article_id, recrawl_count, last_recrawl_time)
+							 VALUES ($1, $2, Now())
+							 ON CONFLICT (article_id) DO UPDATE SET
+								recrawl_count = EXCLUDED.recrawl_count,
+								last_recrawl_time = EXCLUDED.last_recrawl_time`
+
+	_, err := db.ExecContext(ctx, insertStmt, articleID, stage)
+	return err
+}
+
+// Revisions returns the revision history of the article, oldest first.
+func Revisions(ctx context.Context, db *sqlx.DB, articleID int64) ([]*Revision, error) {
+	out := []*Revision{}
+	err := db.SelectContext(ctx, &out,
+		"SELECT * FROM ArticleRevision WHERE article_id = $1 ORDER BY revision_number ASC", articleID)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// lineDiff returns the lines removed from a with a "- " prefix and the lines added in b with a "+ " prefix,
+// in order, based on their longest common subsequence of lines.
+func lineDiff(a string, b string) string {
+	x := strings.Split(a, "\n")
+	y := strings.Split(b, "\n")
+
+	var sb strings.Builder
+	// too large to diff, treat as a full replacement
+	if len(x) > maxDiffLines || len(y) > maxDiffLines {
+		for _, line := range x {
+			sb.WriteString("- " + line + "\n")
+		}
+		for _, line := range y {
+			sb.WriteString("+ " + line + "\n")
+		}
+		return sb.String()
+	}
+
+	// lcs[i][j] is the common subsequence length of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			i, j = i+1, j+1
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			sb.WriteString("- " + x[i] + "\n")
+			i = i + 1
+		default:
+			sb.WriteString("+ " + y[j] + "\n")
+			j = j + 1
+		}
+	}
+
+	return sb.String()
+}
+
+// RecrawlApp visits articles again at the ages in NEWSPAPER_RECRAWL_AGES (default 1h,24h,7d)
+// and saves a revision when their title or body has changed.
+// At most NEWSPAPER_RECRAWL_LIMIT articles (default 500) are recrawled per age each run.
+func RecrawlApp() {
+	ages, labels, err := RecrawlAges(os.Getenv("NEWSPAPER_RECRAWL_AGES"))
+	if err != nil {
+		setup.LogCommon(err).Fatal("Failed RecrawlAges")
+	} else if len(ages) == 0 {
+		ages, labels, _ = RecrawlAges(defaultRecrawlAges)
+	}
+	limit := setup.EnvToIntOr("NEWSPAPER_RECRAWL_LIMIT", 500)
+
+	ctx := context.Background()
+	// connect to redis cache
+	client, err := setup.Redis(ctx)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to Redis")
+	}
+	// connect to database
+	db, err := setup.SQL(ctx)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to SQL database")
+	}
+	// follow each host's robots.txt
+	robots := NewRobotsCacheFromEnv(client)
+	// crawl hosts in parallel while being polite to each host
+	scheduler := NewSchedulerFromEnv().WithHostDelay(robots.CrawlDelay)
+
+	candidates, err := recrawlCandidates(ctx, db, ages, labels, time.Now(), limit)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Failed recrawlCandidates")
+	}
+
+	var numRevisions uint64
+	tasks := []CrawlTask{}
+	for _, c := range candidates {
+		c := c
+		tasks = append(tasks, CrawlTask{
+			Host: c.Host,
+			Run: func() {
+				if rev := recrawl(ctx, db, robots, c); rev != nil {
+					atomic.AddUint64(&numRevisions, 1)
+					fmt.Println("Article revised", c.ArticleID, rev.RevisionNumber, c.Link)
+				}
+				// recrawl each stage once, even if the article could not be fetched
+				err := markRecrawled(ctx, db, c.ArticleID, c.stage)
+				if err != nil {
+					setup.LogCommon(err).
+						WithField("ArticleID", c.ArticleID).
+						Error("Failed markRecrawled")
+				}
+			},
+		})
+	}
+
+	// learn each host's Crawl-delay before its first request
+	robots.Prefetch(ctx, tasks)
+	// blocks until all articles are recrawled
+	scheduler.Run(ctx, tasks)
+
+	// run summary
+	setup.LogCommon(nil).
+		WithField("NumRecrawled", len(candidates)).
+		WithField("NumRevisions", numRevisions).
+		WithField("RunTime", setup.RunTime().String()).
+		Info("RunSummary")
+}
+
+// RevisionsApp prints the revision history of an article.
+func RevisionsApp(articleID int64) {
+	ctx := context.Background()
+	// connect to database
+	db, err := setup.SQL(ctx)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to SQL database")
+	}
+
+	revisions, err := Revisions(ctx, db, articleID)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("ArticleID", articleID).
+			Fatal("Failed Revisions")
+	}
+
+	for _, rev := range revisions {
+		fmt.Println("Revision", rev.RevisionNumber, rev.DataTime.Format(time.RFC3339), "age", rev.RecrawlAge,
+			"title changed", rev.TitleChanged, "body changed", rev.BodyChanged)
+		fmt.Print(rev.Diff)
+	}
+
+	setup.LogCommon(nil).
+		WithField("ArticleID", articleID).
+		WithField("NumRevisions", len(revisions)).
+		WithField("RunTime", setup.RunTime().String()).
+		Info("RunSummary")
+}
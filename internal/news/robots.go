@@ -0,0 +1,339 @@
+package news
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	goredis "github.com/go-redis/redis/v7"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/wpwilson10/caterpillar/internal/redis"
+)
+
+// how long a host's robots.txt is cached before fetching it again
+const robotsTTL = 24 * time.Hour
+
+// how long a host whose robots.txt could not be retrieved is treated as disallowing everything
+// before fetching it again, following RFC 9309 for server errors and unreachable hosts
+const robotsUnavailableTTL = 30 * time.Minute
+
+// number of hosts whose robots.txt is fetched at once by Prefetch
+const robotsPrefetchWorkers = 16
+
+// largest robots.txt body that is read, extra content is ignored
+const robotsMaxBytes = 512 * 1024
+
+// RobotsCache fetches and caches robots.txt files so articles are only fetched when
+// the host allows our user agent. Links that are disallowed are remembered
+// in a redis set so they are not retried.
+type RobotsCache struct {
+	client     *http.Client
+	agent      string     // user agent sent with requests and matched against robots groups
+	disallowed *redis.Set // links that robots.txt did not allow
+	mu         sync.Mutex
+	hosts      map[string]*robotsEntry // keyed by scheme and host
+}
+
+// parsed robots.txt rules for a single host
+type robotsEntry struct {
+	rules   []robotsRule
+	delay   time.Duration // Crawl-delay for our user agent
+	fetched time.Time
+	// set if robots.txt could not be retrieved, nothing is allowed until the entry expires
+	unavailable error
+}
+
+// single Allow or Disallow line
+type robotsRule struct {
+	allow   bool
+	pattern string
+	re      *regexp.Regexp
+}
+
+// NewRobotsCache creates a RobotsCache for the given user agent.
+// Disallowed links are saved to the disallowed set.
+func NewRobotsCache(agent string, disallowed *redis.Set) *RobotsCache {
+	return &RobotsCache{
+		client:     &http.Client{Timeout: time.Second * 10},
+		agent:      agent,
+		disallowed: disallowed,
+		hosts:      make(map[string]*robotsEntry),
+	}
+}
+
+// NewRobotsCacheFromEnv creates a RobotsCache using the newspaper3k user agent
+// and the NEWSPAPER_DISALLOWED_SET redis set.
+func NewRobotsCacheFromEnv(client *goredis.Client) *RobotsCache {
+	disallowed := redis.NewSet(client, os.Getenv("NEWSPAPER_DISALLOWED_SET"))
+	return NewRobotsCache(os.Getenv("PY_NEWSPAPER_USER_AGENT"), disallowed)
+}
+
+// Allowed returns true if the host's robots.txt allows our user agent to fetch the link.
+// An error means the rules could not be retrieved and the link should be tried again later.
+func (c *RobotsCache) Allowed(ctx context.Context, link string) (bool, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false, err
+	}
+
+	entry, err := c.entry(ctx, u)
+	if err != nil {
+		return false, err
+	}
+
+	// robots rules match against the escaped path and query
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if len(u.RawQuery) > 0 {
+		path = path + "?" + u.RawQuery
+	}
+
+	return entry.allowed(path), nil
+}
+
+// CrawlDelay returns the Crawl-delay from the host's cached robots.txt, or zero if unknown.
+// Suitable for use with Scheduler.WithHostDelay.
+func (c *RobotsCache) CrawlDelay(host string) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var delay time.Duration
+	for _, scheme := range []string{"https", "http"} {
+		if e, ok := c.hosts[scheme+"://"+host]; ok && e.delay > delay {
+			delay = e.delay
+		}
+	}
+
+	return delay
+}
+
+// IsDisallowed returns true if the link was previously disallowed by robots.txt.
+func (c *RobotsCache) IsDisallowed(ctx context.Context, link string) (bool, error) {
+	return c.disallowed.IsMember(ctx, link)
+}
+
+// Disallow remembers the link so it is not retried and adds it to the NewsDisallowed table.
+func (c *RobotsCache) Disallow(ctx context.Context, db *sqlx.DB, source *Source) error {
+	err := c.disallowed.Add(ctx, source.Link)
+	if err != nil {
+		return err
+	}
+
+	var insertStmt string = `INSERT INTO NewsDisallowed (link, host, source, data_entry_time)
+							 VALUES ($1, $2, $3, Now())
+							 ON CONFLICT (link) DO NOTHING`
+
+	_, err = db.ExecContext(ctx, insertStmt, source.Link, source.Host, source.Source)
+	return err
+}
+
+// entry returns the cached robots rules for the url's host, fetching them if needed.
+func (c *RobotsCache) entry(ctx context.Context, u *url.URL) (*robotsEntry, error) {
+	key := u.Scheme + "://" + u.Host
+
+	c.mu.Lock()
+	entry, ok := c.hosts[key]
+	c.mu.Unlock()
+	if !ok || entry.expired() {
+		entry = c.fetch(ctx, key)
+
+		c.mu.Lock()
+		c.hosts[key] = entry
+		c.mu.Unlock()
+	}
+
+	if entry.unavailable != nil {
+		return nil, entry.unavailable
+	}
+
+	return entry, nil
+}
+
+// expired returns true if the entry should be fetched again.
+func (e *robotsEntry) expired() bool {
+	if e.unavailable != nil {
+		return time.Since(e.fetched) >= robotsUnavailableTTL
+	}
+
+	return time.Since(e.fetched) >= robotsTTL
+}
+
+// Prefetch fetches robots.txt for the tasks' hosts that are not cached yet, several hosts at a time,
+// so that each host's Crawl-delay is known before the scheduler starts its first task.
+// Failures are cached like any other fetch and reported when the host's links are checked.
+func (c *RobotsCache) Prefetch(ctx context.Context, tasks []CrawlTask) {
+	hosts := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < robotsPrefetchWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for host := range hosts {
+				// the result is cached by entry
+				c.entry(ctx, &url.URL{Scheme: "https", Host: host})
+			}
+		}()
+	}
+
+	seen := make(map[string]bool)
+	for _, t := range tasks {
+		if len(t.Host) > 0 && !seen[t.Host] {
+			seen[t.Host] = true
+			hosts <- t.Host
+		}
+	}
+	close(hosts)
+	wg.Wait()
+}
+
+// fetch downloads and parses robots.txt for the given scheme and host.
+// Server errors and unreachable hosts return an unavailable entry.
+func (c *RobotsCache) fetch(ctx context.Context, key string) *robotsEntry {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, key+"/robots.txt", nil)
+	if err != nil {
+		return &robotsEntry{fetched: time.Now(), unavailable: err}
+	}
+	if len(c.agent) > 0 {
+		req.Header.Set("User-Agent", c.agent)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return &robotsEntry{fetched: time.Now(), unavailable: err}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return parseRobots(io.LimitReader(resp.Body, robotsMaxBytes), c.agent)
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		// no robots.txt means everything is allowed
+		return &robotsEntry{fetched: time.Now()}
+	}
+
+	// server errors mean the rules are unknown
+	return &robotsEntry{fetched: time.Now(), unavailable: fmt.Errorf("robots.txt http status %d", resp.StatusCode)}
+}
+
+// parseRobots reads the rules for the group that best matches the agent.
+// The longest user agent token contained in our agent wins, falling back to *.
+func parseRobots(r io.Reader, agent string) *robotsEntry {
+	agent = strings.ToLower(agent)
+
+	// rules for the best group so far
+	var best *robotsEntry
+	bestLen := -1
+
+	// current group's agents and rules
+	var agents []string
+	current := &robotsEntry{}
+	inRules := false
+
+	// finish the current group, keeping it if it is the best match
+	finish := func() {
+		for _, a := range agents {
+			match := -1
+			if a == "*" {
+				match = 0
+			} else if len(a) > 0 && strings.Contains(agent, a) {
+				match = len(a)
+			}
+			if match > bestLen {
+				best = current
+				bestLen = match
+			}
+		}
+		agents = nil
+		current = &robotsEntry{}
+		inRules = false
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// remove comments
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		value := strings.TrimSpace(line[i+1:])
+
+		switch key {
+		case "user-agent":
+			// a user agent after rules starts a new group
+			if inRules {
+				finish()
+			}
+			agents = append(agents, strings.ToLower(value))
+		case "allow", "disallow":
+			inRules = true
+			// an empty disallow allows everything
+			if len(value) > 0 {
+				current.rules = append(current.rules, newRobotsRule(key == "allow", value))
+			}
+		case "crawl-delay":
+			inRules = true
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				current.delay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+	}
+	finish()
+
+	if best == nil {
+		best = &robotsEntry{}
+	}
+	best.fetched = time.Now()
+
+	return best
+}
+
+// newRobotsRule compiles the path pattern, supporting * and $ wildcards.
+func newRobotsRule(allow bool, pattern string) robotsRule {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.Replace(expr, `\*`, `.*`, -1)
+	if strings.HasSuffix(expr, `\$`) {
+		expr = strings.TrimSuffix(expr, `\$`) + "$"
+	}
+
+	return robotsRule{
+		allow:   allow,
+		pattern: pattern,
+		re:      regexp.MustCompile("^" + expr),
+	}
+}
+
+// allowed applies the longest matching rule to the path, preferring allow on ties.
+func (e *robotsEntry) allowed(path string) bool {
+	out := true
+	longest := -1
+
+	for _, rule := range e.rules {
+		if !rule.re.MatchString(path) {
+			continue
+		}
+		if len(rule.pattern) > longest || (len(rule.pattern) == longest && rule.allow) {
+			out = rule.allow
+			longest = len(rule.pattern)
+		}
+	}
+
+	return out
+}
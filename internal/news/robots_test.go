@@ -0,0 +1,145 @@
+package news
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testRobots = `# example robots.txt
+User-agent: *
+Disallow: /private
+Crawl-delay: 2
+
+User-agent: otherbot
+Disallow: /
+
+User-agent: caterpillar
+User-agent: caterpillar-news
+Disallow: /news/*/draft$
+Disallow: /archive
+Allow: /archive/public
+Allow: /search$ # exact page only
+Crawl-delay: 0.5
+`
+
+func TestParseRobots(t *testing.T) {
+	tests := []struct {
+		name  string
+		agent string
+		path  string
+		want  bool
+	}{
+		{"default group allows", "somebot/1.0", "/story", true},
+		{"default group disallows", "somebot/1.0", "/private/story", false},
+		{"default group prefix", "somebot/1.0", "/privately", false},
+		{"other group", "OtherBot/2.0", "/story", false},
+		{"longest agent wins", "Mozilla/5.0 (compatible; caterpillar-news/1.0)", "/private", true},
+		{"shared group", "caterpillar/1.0", "/archive/2020", false},
+		{"longer allow wins", "caterpillar/1.0", "/archive/public/1", true},
+		{"wildcard", "caterpillar/1.0", "/news/2020/draft", false},
+		{"end anchor", "caterpillar/1.0", "/news/2020/draft/1", true},
+		{"allow end anchor", "caterpillar/1.0", "/search", true},
+		{"no rule", "caterpillar/1.0", "/search?q=1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := parseRobots(strings.NewReader(testRobots), tt.agent)
+			if got := entry.allowed(tt.path); got != tt.want {
+				t.Errorf("allowed(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRobotsCrawlDelay(t *testing.T) {
+	tests := []struct {
+		agent string
+		want  time.Duration
+	}{
+		{"somebot", 2 * time.Second},
+		{"otherbot", 0},
+		{"caterpillar", 500 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		if got := parseRobots(strings.NewReader(testRobots), tt.agent).delay; got != tt.want {
+			t.Errorf("%s delay = %v, want %v", tt.agent, got, tt.want)
+		}
+	}
+}
+
+func TestParseRobotsEmpty(t *testing.T) {
+	entry := parseRobots(strings.NewReader("User-agent: *\nDisallow:\n"), "caterpillar")
+	if !entry.allowed("/anything") {
+		t.Error("empty Disallow should allow everything")
+	}
+	if entry.fetched.IsZero() {
+		t.Error("fetched time not set")
+	}
+}
+
+func TestRobotsCacheStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		allowed bool
+		err     bool
+	}{
+		{"rules", http.StatusOK, "User-agent: *\nDisallow: /story\n", false, false},
+		{"missing", http.StatusNotFound, "", true, false},
+		{"server error", http.StatusServiceUnavailable, "", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			c := NewRobotsCache("caterpillar", nil)
+			for i := 0; i < 3; i++ {
+				allowed, err := c.Allowed(context.Background(), server.URL+"/story")
+				if allowed != tt.allowed || (err != nil) != tt.err {
+					t.Fatalf("Allowed = %v, %v, want %v, error %v", allowed, err, tt.allowed, tt.err)
+				}
+			}
+
+			// every result, including server errors, is cached
+			if requests != 1 {
+				t.Errorf("robots.txt fetched %d times, want 1", requests)
+			}
+		})
+	}
+}
+
+func TestRobotsCachePrefetch(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("User-agent: *\nCrawl-delay: 3\n"))
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	c := NewRobotsCache("caterpillar", nil)
+	c.client = server.Client()
+
+	if got := c.CrawlDelay(u.Host); got != 0 {
+		t.Fatalf("CrawlDelay before Prefetch = %v, want 0", got)
+	}
+
+	c.Prefetch(context.Background(), []CrawlTask{{Host: u.Host}, {Host: u.Host}, {Host: ""}})
+	if got := c.CrawlDelay(u.Host); got != 3*time.Second {
+		t.Errorf("CrawlDelay after Prefetch = %v, want 3s", got)
+	}
+}
@@ -1,13 +1,17 @@
 package news
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/gocarina/gocsv"
+	"github.com/jmoiron/sqlx"
 	"github.com/mmcdole/gofeed"
 
 	"github.com/wpwilson10/caterpillar/internal/redis"
@@ -15,20 +19,20 @@ import (
 )
 
 // SourceListFromRSS returns source objects from rss feeds.
-func SourceListFromRSS(articleSet *redis.Set) []*Source {
+func SourceListFromRSS(ctx context.Context, db *sqlx.DB, articleSet *redis.Set, scheduler *Scheduler) []*Source {
 	// get rss feeds
-	rss := newFeeds()
+	rss := newFeeds(ctx, db, scheduler)
 
 	// iterate through each news feed to create source structs
 	// and filter out links we have already seen
 	sources := []*Source{}
 	for _, r := range rss {
 		// iterate through each article in feed
-		for _, a := range r.Items {
+		for _, a := range r.feed.Items {
 			// check if we have seen this link before
-			if !articleSet.IsMember(a.Link) {
+			if !seen(ctx, articleSet, Canonicalize(a.Link)) {
 				// convert feed article to standard source
-				source := NewSource(FromFeed(a))
+				source := NewSource(FromFeed(a), FromCatalog(r.catalog))
 				// add to list if we got something
 				if len(source.Link) > 1 {
 					sources = append(sources, source)
@@ -40,100 +44,187 @@ func SourceListFromRSS(articleSet *redis.Set) []*Source {
 	return sources
 }
 
-// newFeeds returns an array of gofeeds using the rss list in the file at RSS_FILEPATH.
+// ErrNotModified is returned when a feed has not changed since the last poll.
+var ErrNotModified = errors.New("feed not modified")
+
+// polledFeed is a parsed feed along with its entry in the feed catalog.
+type polledFeed struct {
+	catalog *Feed
+	feed    *gofeed.Feed
+}
+
+// newFeeds returns the parsed feeds for the enabled rss feeds in the NewsFeed table.
+// Feeds are fetched in parallel using the scheduler's per host limits.
+// Feeds that are not due for polling, backing off after failures,
+// or have not changed since the last poll are skipped.
 // This is potentially slow
-func newFeeds() []*gofeed.Feed {
-	// get rss feeds from file
-	rss := rssFromFile()
-	// setup parser
-	fp := gofeed.NewParser()
+func newFeeds(ctx context.Context, db *sqlx.DB, scheduler *Scheduler) []*polledFeed {
+	// get rss feeds from the catalog
+	rss, err := LoadFeeds(ctx, db, FeedRSS)
+	if err != nil {
+		setup.LogCommon(err).Error("Failed LoadFeeds")
+		return nil
+	} else if len(rss) < 1 {
+		setup.LogCommon(nil).Error("Empty feed catalog")
+	}
+	// get saved polling state
+	states, err := LoadFeedStates(ctx, db)
+	if err != nil {
+		setup.LogCommon(err).Error("Failed LoadFeedStates")
+		states = make(map[string]*FeedState)
+	}
 	// set timeout
-	fp.Client = &http.Client{Timeout: time.Second * 10}
+	client := &http.Client{Timeout: time.Second * 10}
 	// save values
-	out := []*gofeed.Feed{}
+	out := []*polledFeed{}
+	var mu sync.Mutex
 
-	// process each
+	// create a task for each feed
+	tasks := []CrawlTask{}
 	for _, r := range rss {
+		r := r
+		link := strings.TrimSpace(r.RSS)
 		// sanity check that there is an rss link, arbitrary length value used
-		if len(r.RSS) < 5 {
+		if len(link) < 5 {
 			setup.LogCommon(nil).
 				WithField("RSS", r.Name).
 				Error("No RSS feed")
+			continue
 		}
-		// parse the rss feed
-		feed, err := fp.ParseURL(strings.TrimSpace(r.RSS))
-		if err != nil {
-			setup.LogCommon(err).
-				WithField("RSS", r.Name).
-				WithField("URL", r.RSS).
-				Error("Failed ParseURL")
+
+		// get this feed's state
+		state, ok := states[link]
+		if !ok {
+			state = NewFeedState(link, r.Name)
+		}
+		state.Name = r.Name
+		// skip feeds until their poll interval and any failure backoff has passed
+		if !r.Due(state, time.Now()) {
+			continue
 		}
-		// check we got good data
-		err = checkRSSFeed(feed)
+
+		u, err := url.Parse(link)
 		if err != nil {
 			setup.LogCommon(err).
 				WithField("RSS", r.Name).
-				WithField("URL", r.RSS).
-				Error("Failed checkRSSFeed")
-		} else {
-			// add to output
-			out = append(out, feed)
+				WithField("URL", link).
+				Error("Failed url.Parse")
+			continue
 		}
+
+		tasks = append(tasks, CrawlTask{
+			Host: u.Hostname(),
+			Run: func() {
+				// parse the rss feed
+				feed := pollFeed(ctx, client, state)
+				if feed != nil {
+					// add to output
+					mu.Lock()
+					out = append(out, &polledFeed{catalog: r, feed: feed})
+					mu.Unlock()
+				}
+
+				// save the feed's new state
+				err := state.Save(ctx, db)
+				if err != nil {
+					setup.LogCommon(err).
+						WithField("RSS", state.Name).
+						Error("Failed FeedState Save")
+				}
+			},
+		})
 	}
 
+	// blocks until all feeds are fetched
+	scheduler.Run(ctx, tasks)
+
 	return out
 }
 
-// checkRSSFeed performs basic checks to see if feed is valid.
-func checkRSSFeed(feed *gofeed.Feed) error {
-	// check we got something
-	if feed == nil {
-		return errors.New("No feed returned")
-	} else if len(feed.Items) < 1 {
-		// check we got some articles
-		return errors.New("Feed returned no articles")
-	} else if len(feed.Title) < 1 {
-		// check there is a title
-		return errors.New("Feed has no title")
+// pollFeed downloads and parses the feed and records the result in its state.
+// Returns nil if the feed failed, has not changed, or has no items.
+func pollFeed(ctx context.Context, client *http.Client, state *FeedState) *gofeed.Feed {
+	feed, etag, lastModified, err := fetchFeed(ctx, client, state)
+	now := time.Now()
+
+	if err == ErrNotModified {
+		state.NotModified(now)
+		return nil
+	} else if err != nil {
+		state.Failure(now, err)
+		setup.LogCommon(err).
+			WithField("RSS", state.Name).
+			WithField("URL", state.RSS).
+			WithField("failures", state.ConsecutiveFailures).
+			Error("Failed fetchFeed")
+		return nil
+	}
+
+	state.Success(now, feed, etag, lastModified)
+	// empty feeds are tracked by the feed health report
+	if len(feed.Items) < 1 {
+		return nil
 	}
-	return nil
-}
 
-// temp struct to parse rss source file
-type rss struct {
-	Name     string `csv:"Name"`
-	Link     string `csv:"Link"`
-	RSS      string `csv:"RSS"`
-	Category string `csv:"Category"`
+	return feed
 }
 
-// rssFromFile creates an array of rss structs from the file at filepath.
-func rssFromFile() []*rss {
-	filepath := os.Getenv("NEWSPAPER_RSS_FILEPATH")
-	// Get the file
-	sourceFile, err := os.OpenFile(filepath, os.O_RDWR|os.O_CREATE, os.ModePerm)
+// fetchFeed makes a conditional request for the feed using the state's cache headers.
+// Returns the parsed feed and the response's ETag and Last-Modified headers,
+// or ErrNotModified if the feed has not changed.
+func fetchFeed(ctx context.Context, client *http.Client, state *FeedState) (*gofeed.Feed, string, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, state.RSS, nil)
 	if err != nil {
-		setup.LogCommon(err).
-			WithField("filepath", filepath).
-			Error("OpenFile")
+		return nil, "", "", err
 	}
-	defer sourceFile.Close()
 
-	// Read from file to array
-	s := []*rss{}
-	// Load clients from file
-	if err := gocsv.UnmarshalFile(sourceFile, &s); err != nil {
-		setup.LogCommon(err).
-			WithField("filepath", filepath).
-			Error("UnmarshalFile")
+	// identify ourselves the same way newspaper3k does
+	if agent := os.Getenv("PY_NEWSPAPER_USER_AGENT"); len(agent) > 0 {
+		req.Header.Set("User-Agent", agent)
+	}
+	// ask the server to only send changes
+	if state.ETag.Valid {
+		req.Header.Set("If-None-Match", state.ETag.String)
+	}
+	if state.LastModified.Valid {
+		req.Header.Set("If-Modified-Since", state.LastModified.String)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, "", "", ErrNotModified
+	} else if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, "", "", fmt.Errorf("http status %d", resp.StatusCode)
+	}
+
+	feed, err := gofeed.NewParser().Parse(resp.Body)
+	if err != nil {
+		return nil, "", "", err
 	}
 
-	// Check that we got something
-	if len(s) <= 0 {
-		setup.LogCommon(nil).
-			WithField("filepath", filepath).
-			Error("Empty source list")
+	// check we got good data
+	err = checkRSSFeed(feed)
+	if err != nil {
+		return nil, "", "", err
 	}
 
-	return s
+	return feed, resp.Header.Get("ETag"), resp.Header.Get("Last-Modified"), nil
+}
+
+// checkRSSFeed performs basic checks to see if feed is valid.
+// Feeds without items are valid, they are reported by the feed health report.
+func checkRSSFeed(feed *gofeed.Feed) error {
+	// check we got something
+	if feed == nil {
+		return errors.New("No feed returned")
+	} else if len(feed.Title) < 1 {
+		// check there is a title
+		return errors.New("Feed has no title")
+	}
+	return nil
 }
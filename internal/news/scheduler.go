@@ -0,0 +1,183 @@
+package news
+
+import (
+	"context"
+	"time"
+
+	"github.com/wpwilson10/caterpillar/internal/setup"
+)
+
+// CrawlTask is a unit of work that makes requests to a single host.
+type CrawlTask struct {
+	Host string
+	Run  func()
+}
+
+// Scheduler runs crawl tasks on a bounded pool of workers.
+// Different hosts are crawled in parallel while each host is limited to a number of
+// concurrent tasks and a minimum delay between task starts.
+// An overall interval between any two task starts caps total throughput.
+type Scheduler struct {
+	workers   int           // max tasks running at once
+	hostLimit int           // max tasks running at once for a single host
+	hostDelay time.Duration // min time between task starts for a single host
+	interval  time.Duration // min time between any two task starts
+	// optional host specific delay, the larger of this and hostDelay is used
+	hostDelayFunc func(host string) time.Duration
+	// per host tracking, kept between runs so back to back runs are also polite to each host
+	hosts map[string]*hostSlot
+}
+
+// per host tracking used while running tasks
+type hostSlot struct {
+	active int       // tasks currently running
+	next   time.Time // earliest time the next task may start
+}
+
+// NewScheduler creates a Scheduler. Values less than one worker or one task per host are raised to one.
+func NewScheduler(workers int, hostLimit int, hostDelay time.Duration, interval time.Duration) *Scheduler {
+	if workers < 1 {
+		workers = 1
+	}
+	if hostLimit < 1 {
+		hostLimit = 1
+	}
+
+	return &Scheduler{
+		workers:   workers,
+		hostLimit: hostLimit,
+		hostDelay: hostDelay,
+		interval:  interval,
+		hosts:     make(map[string]*hostSlot),
+	}
+}
+
+// NewSchedulerFromEnv creates a Scheduler configured by the .env file.
+// NEWSPAPER_WORKERS - number of workers, default 8
+// NEWSPAPER_HOST_CONCURRENCY - tasks at once per host, default 1
+// NEWSPAPER_HOST_DELAY - seconds between task starts per host, default 5
+// NEWSPAPER_MAX_PER_MINUTE - max task starts per minute across all hosts, default 120
+func NewSchedulerFromEnv() *Scheduler {
+	workers := setup.EnvToIntOr("NEWSPAPER_WORKERS", 8)
+	hostLimit := setup.EnvToIntOr("NEWSPAPER_HOST_CONCURRENCY", 1)
+	hostDelay := time.Duration(setup.EnvToIntOr("NEWSPAPER_HOST_DELAY", 5)) * time.Second
+
+	var interval time.Duration
+	if perMinute := setup.EnvToIntOr("NEWSPAPER_MAX_PER_MINUTE", 120); perMinute > 0 {
+		interval = time.Minute / time.Duration(perMinute)
+	}
+
+	return NewScheduler(workers, hostLimit, hostDelay, interval)
+}
+
+// WithHostDelay sets a function returning a host specific minimum delay, such as a robots.txt Crawl-delay.
+// The larger of this and the scheduler's host delay is used. Returns the scheduler for chaining.
+func (s *Scheduler) WithHostDelay(f func(host string) time.Duration) *Scheduler {
+	s.hostDelayFunc = f
+	return s
+}
+
+// delay returns the minimum time between task starts for the host.
+func (s *Scheduler) delay(host string) time.Duration {
+	if s.hostDelayFunc != nil {
+		if d := s.hostDelayFunc(host); d > s.hostDelay {
+			return d
+		}
+	}
+
+	return s.hostDelay
+}
+
+// Run executes all tasks and blocks until they finish.
+// Tasks for the same host start in the order given, and host delays carry over from earlier runs.
+// If the context is cancelled, tasks that have not started are skipped.
+// Run must not be called again until it returns.
+func (s *Scheduler) Run(ctx context.Context, tasks []CrawlTask) {
+	pending := append([]CrawlTask{}, tasks...)
+	hosts := s.hosts
+
+	work := make(chan CrawlTask)
+	done := make(chan string)
+	for i := 0; i < s.workers; i++ {
+		go func() {
+			for t := range work {
+				t.Run()
+				done <- t.Host
+			}
+		}()
+	}
+
+	var running int
+	var nextStart time.Time
+	cancelled := ctx.Done()
+	for len(pending) > 0 || running > 0 {
+		now := time.Now()
+		wait := time.Duration(-1)
+
+		// start a task if a worker is free and throughput allows
+		if running < s.workers && len(pending) > 0 {
+			if now.Before(nextStart) {
+				wait = nextStart.Sub(now)
+			} else {
+				i, hostWait := s.ready(pending, hosts, now)
+				if i >= 0 {
+					t := pending[i]
+					pending = append(pending[:i], pending[i+1:]...)
+
+					slot := hosts[t.Host]
+					slot.active = slot.active + 1
+					slot.next = now.Add(s.delay(t.Host))
+					nextStart = now.Add(s.interval)
+					running = running + 1
+
+					work <- t
+					continue
+				}
+				wait = hostWait
+			}
+		}
+
+		// wait for a task to finish or a host to become ready
+		var timer <-chan time.Time
+		if wait >= 0 {
+			timer = time.After(wait)
+		}
+		select {
+		case host := <-done:
+			hosts[host].active = hosts[host].active - 1
+			running = running - 1
+		case <-timer:
+		case <-cancelled:
+			// stop starting new tasks, but let running ones finish
+			pending = nil
+			cancelled = nil
+		}
+	}
+
+	close(work)
+}
+
+// ready returns the index of the first pending task whose host can start now.
+// If none can, returns -1 and how long until the earliest host is ready,
+// or a negative wait if every host is at its concurrency limit.
+func (s *Scheduler) ready(pending []CrawlTask, hosts map[string]*hostSlot, now time.Time) (int, time.Duration) {
+	wait := time.Duration(-1)
+
+	for i, t := range pending {
+		slot, ok := hosts[t.Host]
+		if !ok {
+			slot = &hostSlot{}
+			hosts[t.Host] = slot
+		}
+
+		if slot.active >= s.hostLimit {
+			continue
+		} else if !now.Before(slot.next) {
+			return i, 0
+		} else if d := slot.next.Sub(now); wait < 0 || d < wait {
+			wait = d
+		}
+	}
+
+	return -1, wait
+}
@@ -0,0 +1,41 @@
+package news
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSchedulerHostDelay(t *testing.T) {
+	delay := 50 * time.Millisecond
+	s := NewScheduler(4, 1, delay, 0)
+
+	var mu sync.Mutex
+	starts := make(map[string][]time.Time)
+	task := func(host string) CrawlTask {
+		return CrawlTask{Host: host, Run: func() {
+			mu.Lock()
+			starts[host] = append(starts[host], time.Now())
+			mu.Unlock()
+		}}
+	}
+
+	s.Run(context.Background(), []CrawlTask{task("a"), task("a"), task("b")})
+	// host delays carry over to the next run
+	s.Run(context.Background(), []CrawlTask{task("a")})
+
+	if len(starts["a"]) != 3 || len(starts["b"]) != 1 {
+		t.Fatalf("starts = %v, want 3 for a and 1 for b", starts)
+	}
+	for i := 1; i < len(starts["a"]); i++ {
+		// allow for timer resolution
+		if gap := starts["a"][i].Sub(starts["a"][i-1]); gap < delay-5*time.Millisecond {
+			t.Errorf("gap %d = %v, want at least %v", i, gap, delay)
+		}
+	}
+	// other hosts do not wait
+	if gap := starts["b"][0].Sub(starts["a"][0]); gap >= delay {
+		t.Errorf("host b waited %v", gap)
+	}
+}
@@ -0,0 +1,311 @@
+package news
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/wpwilson10/caterpillar/internal/redis"
+	"github.com/wpwilson10/caterpillar/internal/setup"
+)
+
+// Sitemap limits
+const (
+	sitemapMaxBytes = 50 * 1024 * 1024 // largest uncompressed sitemap allowed by the protocol
+	sitemapMaxDepth = 3                // how many levels of sitemap indexes are followed
+)
+
+// SitemapURL is a single url entry from a sitemap, with Google News fields if present.
+type SitemapURL struct {
+	Loc     string      `xml:"loc"`
+	LastMod string      `xml:"lastmod"`
+	News    sitemapNews `xml:"news"`
+}
+
+// Google News sitemap extension
+type sitemapNews struct {
+	PublicationDate string `xml:"publication_date"`
+	Title           string `xml:"title"`
+}
+
+// sitemap root element, either a urlset or a sitemapindex
+type sitemapDoc struct {
+	XMLName  xml.Name
+	URLs     []*SitemapURL `xml:"url"`
+	Sitemaps []struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod"`
+	} `xml:"sitemap"`
+}
+
+// Published returns the news publication date, falling back to the last modified date.
+// Returns nil if neither is given or parseable.
+func (u *SitemapURL) Published() *time.Time {
+	loc := HostLocation(u.Loc, "")
+	if t, ok := ParseDate(u.News.PublicationDate, loc); ok {
+		return &t
+	}
+	if t, ok := ParseDate(u.LastMod, loc); ok {
+		return &t
+	}
+
+	return nil
+}
+
+// SitemapWalker fetches sitemaps, following sitemap indexes and skipping entries older than a cutoff.
+type SitemapWalker struct {
+	client *http.Client
+	agent  string    // user agent sent with requests
+	since  time.Time // entries last modified before this are skipped
+}
+
+// NewSitemapWalker creates a SitemapWalker that skips entries last modified before since.
+func NewSitemapWalker(agent string, since time.Time) *SitemapWalker {
+	return &SitemapWalker{
+		client: &http.Client{Timeout: time.Second * 30},
+		agent:  agent,
+		since:  since,
+	}
+}
+
+// Visit returns the recent url entries in the sitemap at link and the child sitemaps to visit next
+// if it is a sitemap index. Depth is the number of indexes above the sitemap.
+// Entries without a date are skipped because their age is unknown.
+// Also returns the total number of url entries seen before filtering.
+func (w *SitemapWalker) Visit(ctx context.Context, link string, depth int) ([]*SitemapURL, []string, int, error) {
+	doc, err := w.fetch(ctx, link)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	out := []*SitemapURL{}
+	for _, u := range doc.URLs {
+		if published := u.Published(); published != nil && !published.Before(w.since) {
+			out = append(out, u)
+		}
+	}
+
+	// child sitemaps from an index
+	children := []string{}
+	for _, child := range doc.Sitemaps {
+		if depth >= sitemapMaxDepth {
+			setup.LogCommon(nil).
+				WithField("sitemap", link).
+				Warn("Sitemap index too deep")
+			break
+		}
+		// unchanged child sitemaps have nothing new
+		if t, ok := ParseDate(child.LastMod, time.UTC); ok && t.Before(w.since) {
+			continue
+		}
+		children = append(children, strings.TrimSpace(child.Loc))
+	}
+
+	return out, children, len(doc.URLs), nil
+}
+
+// fetch downloads and parses a single sitemap, decompressing it if gzipped.
+func (w *SitemapWalker) fetch(ctx context.Context, link string) (*sitemapDoc, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(w.agent) > 0 {
+		req.Header.Set("User-Agent", w.agent)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("http status %d", resp.StatusCode)
+	}
+
+	// sitemap.xml.gz files are served as plain gzip data rather than with a gzip content encoding
+	var body io.Reader = bufio.NewReader(resp.Body)
+	if magic, err := body.(*bufio.Reader).Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	doc := sitemapDoc{}
+	err = xml.NewDecoder(io.LimitReader(body, sitemapMaxBytes)).Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if doc.XMLName.Local != "urlset" && doc.XMLName.Local != "sitemapindex" {
+		return nil, fmt.Errorf("unknown sitemap root %s", doc.XMLName.Local)
+	}
+
+	return &doc, nil
+}
+
+// SourceListFromSitemaps returns source objects from the sitemaps in the feed catalog.
+// Only entries newer than NEWSPAPER_SITEMAP_MAX_AGE hours (default 48) are used.
+func SourceListFromSitemaps(ctx context.Context, db *sqlx.DB, articleSet *redis.Set, scheduler *Scheduler) []*Source {
+	// get sitemaps from the catalog
+	sitemaps, err := LoadFeeds(ctx, db, FeedSitemap)
+	if err != nil {
+		setup.LogCommon(err).Error("Failed LoadFeeds")
+		return nil
+	}
+	// get saved polling state
+	states, err := LoadFeedStates(ctx, db)
+	if err != nil {
+		setup.LogCommon(err).Error("Failed LoadFeedStates")
+		states = make(map[string]*FeedState)
+	}
+
+	maxAge := time.Duration(setup.EnvToIntOr("NEWSPAPER_SITEMAP_MAX_AGE", 48)) * time.Hour
+	walker := NewSitemapWalker(os.Getenv("PY_NEWSPAPER_USER_AGENT"), time.Now().Add(-maxAge))
+	// walk each due sitemap
+	walks := []*sitemapWalk{}
+	pages := []sitemapPage{}
+	for _, f := range sitemaps {
+		// get this sitemap's state
+		state, ok := states[f.RSS]
+		if !ok {
+			state = NewFeedState(f.RSS, f.Name)
+		}
+		state.Name = f.Name
+		// skip sitemaps until their poll interval and any failure backoff has passed
+		if !f.Due(state, time.Now()) {
+			continue
+		}
+
+		walk := &sitemapWalk{feed: f, state: state}
+		walks = append(walks, walk)
+		pages = append(pages, sitemapPage{walk: walk, link: f.RSS})
+	}
+
+	// fetch a level of sitemaps at a time so that child sitemaps from indexes are also scheduled by host
+	for len(pages) > 0 {
+		var mu sync.Mutex
+		next := []sitemapPage{}
+
+		tasks := []CrawlTask{}
+		for _, p := range pages {
+			p := p
+
+			u, err := url.Parse(p.link)
+			if err != nil || len(u.Hostname()) == 0 {
+				setup.LogCommon(err).
+					WithField("RSS", p.walk.feed.Name).
+					WithField("URL", p.link).
+					Error("Failed url.Parse")
+				if p.depth == 0 {
+					p.walk.err = fmt.Errorf("invalid sitemap url %s", p.link)
+				}
+				continue
+			}
+
+			tasks = append(tasks, CrawlTask{
+				Host: u.Hostname(),
+				Run: func() {
+					urls, children, total, err := walker.Visit(ctx, p.link, p.depth)
+
+					mu.Lock()
+					defer mu.Unlock()
+					if err != nil {
+						if p.depth == 0 {
+							p.walk.err = err
+						} else {
+							// keep what we have from the other children
+							setup.LogCommon(err).
+								WithField("sitemap", p.link).
+								Warn("Failed child sitemap")
+						}
+						return
+					}
+
+					p.walk.urls = append(p.walk.urls, urls...)
+					p.walk.total = p.walk.total + total
+					for _, child := range children {
+						next = append(next, sitemapPage{walk: p.walk, link: child, depth: p.depth + 1})
+					}
+				},
+			})
+		}
+
+		// blocks until this level of sitemaps is fetched
+		scheduler.Run(ctx, tasks)
+		pages = next
+	}
+
+	// save values
+	sources := []*Source{}
+	for _, walk := range walks {
+		state := walk.state
+		if walk.err != nil {
+			state.Failure(time.Now(), walk.err)
+			setup.LogCommon(walk.err).
+				WithField("RSS", state.Name).
+				WithField("URL", state.RSS).
+				WithField("failures", state.ConsecutiveFailures).
+				Error("Failed sitemap Walk")
+		} else {
+			state.Polled(time.Now(), walk.total)
+		}
+
+		// save the sitemap's new state
+		err = state.Save(ctx, db)
+		if err != nil {
+			setup.LogCommon(err).
+				WithField("RSS", state.Name).
+				Error("Failed FeedState Save")
+		}
+
+		// convert entries we have not seen to standard sources
+		for _, entry := range walk.urls {
+			// skip entries that are not urls
+			if !setup.IsValidURL(strings.TrimSpace(entry.Loc)) {
+				continue
+			}
+			if seen(ctx, articleSet, Canonicalize(entry.Loc)) {
+				continue
+			}
+			source := NewSource(FromSitemap(entry), FromCatalog(walk.feed))
+			// add to list if we got something
+			if len(source.Link) > 1 {
+				sources = append(sources, source)
+			}
+		}
+	}
+
+	return sources
+}
+
+// sitemapWalk collects the entries of a catalog sitemap and the sitemaps below it.
+type sitemapWalk struct {
+	feed  *Feed
+	state *FeedState
+	urls  []*SitemapURL
+	total int   // url entries seen before filtering
+	err   error // set if the catalog sitemap itself failed
+}
+
+// sitemapPage is a single sitemap to fetch during a walk.
+type sitemapPage struct {
+	walk  *sitemapWalk
+	link  string
+	depth int // number of sitemap indexes above this one
+}
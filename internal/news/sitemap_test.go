@@ -0,0 +1,85 @@
+package news
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSitemapWalkerVisit(t *testing.T) {
+	recent := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	old := time.Now().Add(-30 * 24 * time.Hour).UTC().Format(time.RFC3339)
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.xml":
+			w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<sitemap><loc> ` + server.URL + `/news.xml </loc><lastmod>` + recent + `</lastmod></sitemap>
+	<sitemap><loc>` + server.URL + `/old.xml</loc><lastmod>` + old + `</lastmod></sitemap>
+	<sitemap><loc>` + server.URL + `/undated.xml</loc></sitemap>
+</sitemapindex>`))
+		case "/news.xml":
+			w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:news="http://www.google.com/schemas/sitemap-news/0.9">
+	<url><loc>https://www.example.com/new</loc><news:news><news:publication_date>` + recent + `</news:publication_date><news:title>New</news:title></news:news></url>
+	<url><loc>https://www.example.com/old</loc><lastmod>` + old + `</lastmod></url>
+	<url><loc>https://www.example.com/undated</loc></url>
+</urlset>`))
+		case "/feed.xml":
+			w.Write([]byte(`<rss><channel></channel></rss>`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	walker := NewSitemapWalker("caterpillar", time.Now().Add(-48*time.Hour))
+	ctx := context.Background()
+
+	urls, children, total, err := walker.Visit(ctx, server.URL+"/index.xml", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(urls) != 0 || total != 0 {
+		t.Errorf("index urls = %d, total = %d, want 0, 0", len(urls), total)
+	}
+	want := []string{server.URL + "/news.xml", server.URL + "/undated.xml"}
+	if strings.Join(children, " ") != strings.Join(want, " ") {
+		t.Errorf("children = %v, want %v", children, want)
+	}
+
+	// indexes below the max depth are not followed
+	_, children, _, err = walker.Visit(ctx, server.URL+"/index.xml", sitemapMaxDepth)
+	if err != nil || len(children) != 0 {
+		t.Errorf("children at max depth = %v, %v, want none", children, err)
+	}
+
+	urls, children, total, err = walker.Visit(ctx, server.URL+"/news.xml", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(urls) != 1 || urls[0].Loc != "https://www.example.com/new" || urls[0].News.Title != "New" {
+		t.Errorf("urls = %v, want only the new entry", urls)
+	}
+	if total != 3 || len(children) != 0 {
+		t.Errorf("total = %d, children = %v, want 3, none", total, children)
+	}
+
+	for _, path := range []string{"/missing.xml", "/feed.xml"} {
+		if _, _, _, err = walker.Visit(ctx, server.URL+path, 0); err == nil {
+			t.Errorf("Visit(%s) expected error", path)
+		}
+	}
+}
+
+func TestFromSitemapInvalidLink(t *testing.T) {
+	s := NewSource(FromSitemap(&SitemapURL{Loc: "http://[::1"}))
+	if s.Host != "" {
+		t.Errorf("Host = %q, want empty", s.Host)
+	}
+}
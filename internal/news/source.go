@@ -2,21 +2,49 @@ package news
 
 import (
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/mmcdole/gofeed"
 	"github.com/turnage/graw/reddit"
+	"gopkg.in/guregu/null.v3"
 
 	"github.com/wpwilson10/caterpillar/internal/setup"
 )
 
 // Source is a website news source.
 type Source struct {
-	Title   string
-	Link    string // URL of content
-	Source  string // where the article link came from (e.g. RSS Feed, Reddit)
-	Host    string // hostname parsed from the link
-	PubDate *time.Time
+	Title     string
+	Link      string // standard form of the content's URL, used for dedupe and storage
+	FetchLink string // URL as given by the source, fetched since the standard form may not exist
+	Source    string // where the article link came from (e.g. RSS Feed, Reddit)
+	Host      string // hostname parsed from the link
+	PubDate   *time.Time
+	// catalog feed the link came from, null for sources outside the feed catalog
+	FeedID null.Int
+	Region string // ISO 3166-1 alpha-2 code of the catalog feed if known
+}
+
+// fetchLink returns the URL to download for the source.
+func (s *Source) fetchLink() string {
+	if len(s.FetchLink) > 0 {
+		return s.FetchLink
+	}
+
+	return s.Link
+}
+
+// linkHost returns the hostname parsed from the link, or an empty string if it cannot be parsed.
+func linkHost(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("link", link).
+			Warn("Failed url.Parse")
+		return ""
+	}
+
+	return u.Hostname()
 }
 
 // SourceOption is the signature of our option functions
@@ -40,32 +68,55 @@ func NewSource(opts ...SourceOption) *Source {
 
 // FromFeed uses gofeed.Items to make a source
 func FromFeed(feed *gofeed.Item) SourceOption {
+	// fetch the link as given, using its standard form for dedupe
+	fetchLink := strings.TrimSpace(feed.Link)
+	link := Canonicalize(fetchLink)
 	// get host from link
-	u, err := url.Parse(feed.Link)
-	if err != nil {
-		setup.LogCommon(err).
-			WithField("link", feed.Link).
-			Warn("Failed url.Parse")
-	}
+	host := linkHost(link)
 
 	return func(s *Source) {
 		s.Title = feed.Title
-		s.Link = feed.Link
+		s.Link = link
+		s.FetchLink = fetchLink
 		s.Source = "RSS Feed"
-		s.Host = u.Hostname()
+		s.Host = host
 		s.PubDate = feed.PublishedParsed
 	}
 }
 
+// FromSitemap uses sitemap url entries to make a source
+func FromSitemap(item *SitemapURL) SourceOption {
+	// fetch the link as given, using its standard form for dedupe
+	fetchLink := strings.TrimSpace(item.Loc)
+	link := Canonicalize(fetchLink)
+	// get host from link
+	host := linkHost(link)
+
+	return func(s *Source) {
+		s.Title = strings.TrimSpace(item.News.Title)
+		s.Link = link
+		s.FetchLink = fetchLink
+		s.Source = "Sitemap"
+		s.Host = host
+		s.PubDate = item.Published()
+	}
+}
+
+// FromCatalog sets the feed catalog entry that a source came from
+func FromCatalog(feed *Feed) SourceOption {
+	return func(s *Source) {
+		s.FeedID = null.IntFrom(feed.FeedID)
+		s.Region = feed.Region.String
+	}
+}
+
 // FromReddit uses graw reddit.Post to make a source
 func FromReddit(item *reddit.Post) SourceOption {
+	// fetch the link as given, using its standard form for dedupe
+	fetchLink := strings.TrimSpace(item.URL)
+	link := Canonicalize(fetchLink)
 	// get host from link
-	u, err := url.Parse(item.URL)
-	if err != nil {
-		setup.LogCommon(err).
-			WithField("link", item.URL).
-			Warn("Failed url.Parse")
-	}
+	host := linkHost(link)
 
 	// convert time
 	var y int64 = int64(item.CreatedUTC)
@@ -73,28 +124,28 @@ func FromReddit(item *reddit.Post) SourceOption {
 
 	return func(s *Source) {
 		s.Title = item.Title
-		s.Link = item.URL
+		s.Link = link
+		s.FetchLink = fetchLink
 		s.Source = "Reddit Submission"
-		s.Host = u.Hostname()
+		s.Host = host
 		s.PubDate = &cTime
 	}
 }
 
 // FromRedditArticle uses reddit submissions to make a source
 func FromRedditArticle(item *RedditArticle) SourceOption {
+	// fetch the link as given, using its standard form for dedupe
+	fetchLink := strings.TrimSpace(item.Link)
+	link := Canonicalize(fetchLink)
 	// get host from link
-	u, err := url.Parse(item.Link)
-	if err != nil {
-		setup.LogCommon(err).
-			WithField("link", item.Link).
-			Warn("Failed url.Parse")
-	}
+	host := linkHost(link)
 
 	return func(s *Source) {
 		s.Title = item.Title
-		s.Link = item.Link
+		s.Link = link
+		s.FetchLink = fetchLink
 		s.Source = "Reddit Submission"
-		s.Host = u.Hostname()
+		s.Host = host
 		s.PubDate = item.PubDate
 	}
 }
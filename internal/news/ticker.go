@@ -0,0 +1,178 @@
+package news
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/wpwilson10/caterpillar/internal/setup"
+	"github.com/wpwilson10/caterpillar/internal/tickers"
+)
+
+// ArticleListing stores a listing mentioned in an article in a format matching the ArticleListing table schema.
+type ArticleListing struct {
+	ArticleID    int64     `db:"article_id"`
+	ListingID    int64     `db:"listing_id"`
+	MentionCount int       `db:"mention_count"` // mentions in the title and body
+	Confidence   float64   `db:"confidence"`    // chance the article is about the listing
+	MatchTypes   string    `db:"match_types"`   // comma separated kinds of mentions (e.g. cashtag,name)
+	DataTime     time.Time `db:"data_entry_time"`
+}
+
+// SaveListings finds the listings mentioned in the article's titles and body and replaces
+// the article's rows in the ArticleListing table. Returns the number of listings linked.
+func SaveListings(ctx context.Context, db *sqlx.DB, linker *tickers.Linker, article *Article) (int, error) {
+	var insertStmt string = `INSERT INTO ArticleListing (
+								article_id,
+								listing_id,
+								mention_count,
+								confidence,
+								match_types,
+								data_entry_time
+								)
+							 VALUES (
+								:article_id,
+								:listing_id,
+								:mention_count,
+								:confidence,
+								:match_types,
+								:data_entry_time
+								)`
+
+	// the source title is usually the same as the page title, only count it when it differs
+	texts := []string{article.Title.String, article.Body.String}
+	if article.SourceTitle.String != article.Title.String {
+		texts = append(texts, article.SourceTitle.String)
+	}
+	links := linker.Link(texts...)
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// links are replaced so reextracted articles do not keep stale mentions
+	_, err = tx.ExecContext(ctx, "DELETE FROM ArticleListing WHERE article_id = $1", article.ArticleID)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	for _, link := range links {
+		_, err = tx.NamedExecContext(ctx, insertStmt, &ArticleListing{
+			ArticleID:    article.ArticleID,
+			ListingID:    link.ListingID,
+			MentionCount: link.MentionCount,
+			Confidence:   link.Confidence,
+			MatchTypes:   strings.Join(link.Kinds, ","),
+			DataTime:     now,
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(links), tx.Commit()
+}
+
+// saveArticleListings links the article to the listings it mentions, logging on failure.
+func saveArticleListings(ctx context.Context, db *sqlx.DB, article *Article) {
+	linker, err := tickers.Shared(ctx, db)
+	if linker == nil {
+		setup.LogCommon(err).Error("Failed tickers.Shared")
+		return
+	} else if err != nil {
+		// an older linker still finds most listings
+		setup.LogCommon(err).Warn("Failed tickers.Shared reload")
+	}
+
+	_, err = SaveListings(ctx, db, linker, article)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("ArticleID", article.ArticleID).
+			Error("Failed SaveListings")
+	}
+}
+
+// BackfillListings links listings to articles entered in the backfill's date range, checkpointing after each batch.
+// The backfill's article count is the number of articles that mention at least one listing.
+func BackfillListings(ctx context.Context, db *sqlx.DB, b *Backfill, batchSize int) error {
+	var selectStmt string = `SELECT ` + ArticleColumns("") + ` FROM NewsArticle
+							 WHERE data_entry_time >= $1 AND data_entry_time < $2
+								AND article_id > $3
+							 ORDER BY article_id ASC
+							 LIMIT $4`
+
+	linker, err := tickers.LoadLinker(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	return RunBackfill(ctx, db, b, batchSize, func(lastID int64, batchSize int) (int64, int64, int64, error) {
+		batch := []*Article{}
+		err := db.SelectContext(ctx, &batch, selectStmt, b.RangeStart, b.RangeEnd, lastID, batchSize)
+		if err != nil || len(batch) == 0 {
+			return 0, 0, 0, err
+		}
+
+		var numLinked int64
+		for _, a := range batch {
+			n, err := SaveListings(ctx, db, linker, a)
+			if err != nil {
+				return 0, 0, 0, err
+			}
+			if n > 0 {
+				numLinked = numLinked + 1
+			}
+		}
+
+		return batch[len(batch)-1].ArticleID, int64(len(batch)), numLinked, nil
+	})
+}
+
+// ListingBackfillApp links listings to articles entered in [from, to) in batches of batchSize.
+// Progress is saved in the NewsBackfill table so that running again with the same range resumes.
+func ListingBackfillApp(from time.Time, to time.Time, batchSize int) {
+	if !from.Before(to) || batchSize < 1 {
+		setup.LogCommon(nil).
+			WithField("from", from).
+			WithField("to", to).
+			WithField("batch", batchSize).
+			Fatal("Invalid backfill range or batch size")
+	}
+
+	ctx := context.Background()
+	// connect to database
+	db, err := setup.SQL(ctx)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to SQL database")
+	}
+
+	b, err := LoadBackfill(ctx, db, "listings", from, to)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Failed LoadBackfill")
+	}
+	if b.CompletedTime.Valid {
+		fmt.Println("Backfill already complete", b.Name)
+	}
+
+	err = BackfillListings(ctx, db, b, batchSize)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("backfill", b.Name).
+			WithField("LastID", b.LastID).
+			Fatal("Failed BackfillListings")
+	}
+
+	// run summary
+	setup.LogCommon(nil).
+		WithField("backfill", b.Name).
+		WithField("NumProcessed", b.NumProcessed).
+		WithField("NumArticles", b.NumArticles).
+		WithField("RunTime", setup.RunTime().String()).
+		Info("RunSummary")
+}
@@ -1,6 +1,7 @@
 package reddit
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"sync"
@@ -15,36 +16,67 @@ import (
 // App takes queued reddit submissions and gets most recent data to add to database
 // Know issues - GetCommments cannot pull all comments for large threads. Limited by API
 func App() {
-	db := setup.SQL()
+	ctx := context.Background()
+	db, err := setup.SQL(ctx)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to SQL database")
+	}
 	bot := BotClient()
 	// connect to redis caches
-	articleSet := redis.NewSet(setup.Redis(), os.Getenv("NEWSPAPER_SET"))
-	// setup blacklist of article hosts to avoid
-	blacklist := news.NewBlackList()
+	client, err := setup.Redis(ctx)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to Redis")
+	}
+	articleSet := redis.NewSet(client, os.Getenv("NEWSPAPER_SET"))
+	// setup blacklist of article links to avoid
+	blacklist, err := news.BlackListFromEnv(ctx, db)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Failed BlackListFromEnv")
+	}
+	// follow each host's robots.txt
+	robots := news.NewRobotsCacheFromEnv(client)
 
-	// get submissions to process
-	queue := redis.NewQueue(setup.Redis(), os.Getenv("REDDIT_QUEUE"))
-	submissions := PopQueue(queue)
+	// ages at which submissions are fetched
+	schedule, err := ScheduleFromEnv()
+	if err != nil {
+		setup.LogCommon(err).Fatal("Failed ScheduleFromEnv")
+	}
 
 	// for tracking async calls
 	var wg sync.WaitGroup
 
-	// process each entry from the submission queue
-	for _, s := range submissions {
-		fmt.Println(s.Permalink)
+	// latest snapshots first, so submissions queued for their next snapshot are not fetched again this run
+	numQueued := 0
+	for i := len(schedule.Ages) - 1; i >= 0; i-- {
+		var next *redis.Queue
+		if i+1 < len(schedule.Ages) {
+			next = snapshotQueue(client, i+1)
+		}
+
+		// get submissions to process
+		submissions := PopQueue(ctx, snapshotQueue(client, i), schedule.Ages[i])
+		numQueued = numQueued + len(submissions)
 
-		wg.Add(1)
-		go Driver(db, bot, &wg, s, articleSet, blacklist)
-		// reddit api has 60 calls/minute limit, and each run takes two calls
-		// https://github.com/reddit-archive/reddit/wiki/API#rules
-		time.Sleep(2 * time.Second)
+		// process each entry from the submission queue
+		for _, s := range submissions {
+			fmt.Println(schedule.Labels[i], s.Permalink)
+			// entries from the bot do not know their snapshot
+			s.Snapshot = i
+
+			wg.Add(1)
+			go Driver(ctx, db, bot, &wg, s, schedule, next, articleSet, blacklist, robots)
+			// reddit api has 60 calls/minute limit, and each run takes two calls
+			// https://github.com/reddit-archive/reddit/wiki/API#rules
+			time.Sleep(2 * time.Second)
+		}
 	}
 
 	// block until all done
 	wg.Wait()
 	// log summary
+	blacklist.LogCounts()
 	setup.LogCommon(nil).
-		WithField("NumQueued", len(submissions)).
+		WithField("NumQueued", numQueued).
 		WithField("RunTime", setup.RunTime().String()).
 		Info("RunSummary")
 }
@@ -56,7 +88,11 @@ func BotApp() {
 	// Setup client
 	bot := BotClient()
 	// connect to queue
-	queue := redis.NewQueue(setup.Redis(), os.Getenv("REDDIT_QUEUE"))
+	client, err := setup.Redis(context.Background())
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to Redis")
+	}
+	queue := redis.NewQueue(client, os.Getenv("REDDIT_QUEUE"))
 
 	// point bot to my struct with its handles
 	handler := &redditBot{bot: *bot, queue: queue}
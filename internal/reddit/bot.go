@@ -1,6 +1,7 @@
 package reddit
 
 import (
+	"context"
 	"encoding/csv"
 	"io"
 	"os"
@@ -24,9 +25,7 @@ func (r *redditBot) Post(p *reddit.Post) error {
 	// turn into queue submission
 	submission := NewQueueSubmission(p)
 	// add to queue
-	submission.Push(r.queue)
-
-	return nil
+	return submission.Push(context.Background(), r.queue)
 }
 
 // BotClient returns a graw client using my login config from a .env.
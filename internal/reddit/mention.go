@@ -0,0 +1,207 @@
+package reddit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/guregu/null.v3"
+
+	"github.com/wpwilson10/caterpillar/internal/news"
+	"github.com/wpwilson10/caterpillar/internal/setup"
+	"github.com/wpwilson10/caterpillar/internal/tickers"
+)
+
+// ListingMention stores the listings mentioned in a submission or comment in a format matching
+// the RedditListingMention table schema.
+type ListingMention struct {
+	MentionID    int64     `db:"mention_id"`
+	SubmissionID int64     `db:"submission_id"`
+	CommentID    null.Int  `db:"comment_id"` // null for mentions in the submission's title and self text
+	ListingID    int64     `db:"listing_id"`
+	MentionCount int       `db:"mention_count"`
+	Confidence   float64   `db:"confidence"`  // chance the text is about the listing
+	MatchTypes   string    `db:"match_types"` // comma separated kinds of mentions (e.g. cashtag,ticker)
+	CreatedTime  time.Time `db:"created_time"`
+	DataTime     time.Time `db:"data_entry_time"`
+}
+
+// SaveMentions finds the listings mentioned in a stored submission and its comments and replaces
+// the submission's rows in the RedditListingMention table. Returns the number of rows saved.
+func SaveMentions(ctx context.Context, db *sqlx.DB, finder *tickers.SymbolFinder, submissionID int64) (int, error) {
+	var insertStmt string = `INSERT INTO RedditListingMention (
+								submission_id,
+								comment_id,
+								listing_id,
+								mention_count,
+								confidence,
+								match_types,
+								created_time,
+								data_entry_time
+								)
+							 VALUES (
+								:submission_id,
+								:comment_id,
+								:listing_id,
+								:mention_count,
+								:confidence,
+								:match_types,
+								:created_time,
+								:data_entry_time
+								)`
+
+	submission := Submission{}
+	err := db.GetContext(ctx, &submission,
+		`SELECT `+SubmissionColumns("")+` FROM RedditSubmission WHERE submission_id = $1`, submissionID)
+	if err != nil {
+		return 0, err
+	}
+	comments := []*Comment{}
+	err = db.SelectContext(ctx, &comments,
+		`SELECT `+CommentColumns("")+` FROM RedditComment WHERE submission_id = $1`, submissionID)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	rows := []*ListingMention{}
+	add := func(commentID null.Int, created time.Time, links []*tickers.Link) {
+		for _, link := range links {
+			rows = append(rows, &ListingMention{
+				SubmissionID: submissionID,
+				CommentID:    commentID,
+				ListingID:    link.ListingID,
+				MentionCount: link.MentionCount,
+				Confidence:   link.Confidence,
+				MatchTypes:   strings.Join(link.Kinds, ","),
+				CreatedTime:  created,
+				DataTime:     now,
+			})
+		}
+	}
+	add(null.Int{}, submission.CreatedTime, finder.Link(submission.Title, submission.Selftext))
+	for _, c := range comments {
+		add(null.IntFrom(c.CommentID), c.CreatedTime, finder.Link(c.Body))
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// mentions are replaced so finding them again does not duplicate rows
+	_, err = tx.ExecContext(ctx, "DELETE FROM RedditListingMention WHERE submission_id = $1", submissionID)
+	if err != nil {
+		return 0, err
+	}
+	for _, row := range rows {
+		_, err = tx.NamedExecContext(ctx, insertStmt, row)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(rows), tx.Commit()
+}
+
+// saveSubmissionMentions saves the listings mentioned in a submission and its comments, logging on failure.
+func saveSubmissionMentions(ctx context.Context, db *sqlx.DB, submissionID int64) {
+	finder, err := tickers.SharedSymbolFinder(ctx, db)
+	if finder == nil {
+		setup.LogCommon(err).Error("Failed tickers.SharedSymbolFinder")
+		return
+	} else if err != nil {
+		// an older finder still finds most listings
+		setup.LogCommon(err).Warn("Failed tickers.SharedSymbolFinder reload")
+	}
+
+	_, err = SaveMentions(ctx, db, finder, submissionID)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("submissionID", submissionID).
+			Error("Failed SaveMentions")
+	}
+}
+
+// BackfillMentions finds listing mentions in submissions entered in the backfill's date range,
+// checkpointing after each batch. The backfill's article count is the number of submissions
+// with at least one mention in the submission or its comments.
+func BackfillMentions(ctx context.Context, db *sqlx.DB, b *news.Backfill, batchSize int) error {
+	var selectStmt string = `SELECT submission_id FROM RedditSubmission
+							 WHERE data_entry_time >= $1 AND data_entry_time < $2
+								AND submission_id > $3
+							 ORDER BY submission_id ASC
+							 LIMIT $4`
+
+	finder, err := tickers.LoadSymbolFinder(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	return news.RunBackfill(ctx, db, b, batchSize, func(lastID int64, batchSize int) (int64, int64, int64, error) {
+		batch := []int64{}
+		err := db.SelectContext(ctx, &batch, selectStmt, b.RangeStart, b.RangeEnd, lastID, batchSize)
+		if err != nil || len(batch) == 0 {
+			return 0, 0, 0, err
+		}
+
+		var numLinked int64
+		for _, id := range batch {
+			n, err := SaveMentions(ctx, db, finder, id)
+			if err != nil {
+				return 0, 0, 0, err
+			}
+			if n > 0 {
+				numLinked = numLinked + 1
+			}
+		}
+
+		return batch[len(batch)-1], int64(len(batch)), numLinked, nil
+	})
+}
+
+// MentionBackfillApp finds listing mentions in submissions entered in [from, to) in batches of batchSize.
+// Progress is saved in the NewsBackfill table so that running again with the same range resumes.
+func MentionBackfillApp(from time.Time, to time.Time, batchSize int) {
+	if !from.Before(to) || batchSize < 1 {
+		setup.LogCommon(nil).
+			WithField("from", from).
+			WithField("to", to).
+			WithField("batch", batchSize).
+			Fatal("Invalid backfill range or batch size")
+	}
+
+	ctx := context.Background()
+	// connect to database
+	db, err := setup.SQL(ctx)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to SQL database")
+	}
+
+	b, err := news.LoadBackfill(ctx, db, "reddit-mentions", from, to)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Failed LoadBackfill")
+	}
+	if b.CompletedTime.Valid {
+		fmt.Println("Backfill already complete", b.Name)
+	}
+
+	err = BackfillMentions(ctx, db, b, batchSize)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("backfill", b.Name).
+			WithField("LastID", b.LastID).
+			Fatal("Failed BackfillMentions")
+	}
+
+	// run summary
+	setup.LogCommon(nil).
+		WithField("backfill", b.Name).
+		WithField("NumProcessed", b.NumProcessed).
+		WithField("NumSubmissions", b.NumArticles).
+		WithField("RunTime", setup.RunTime().String()).
+		Info("RunSummary")
+}
@@ -1,9 +1,8 @@
 package reddit
 
 import (
+	"context"
 	"encoding/json"
-	"os"
-	"strconv"
 	"time"
 
 	"github.com/turnage/graw/reddit"
@@ -13,8 +12,10 @@ import (
 
 // QueueSubmission represents a submission sent to our queue for later processing.
 type QueueSubmission struct {
-	CreatedTime time.Time
-	Permalink   string
+	CreatedTime  time.Time
+	Permalink    string
+	Snapshot     int   // index of the snapshot age the submission is queued for, zero from the bot
+	SubmissionID int64 // database ID once the submission is stored, zero before
 }
 
 // NewQueueSubmission creates a queue object for the given reddit post.
@@ -30,57 +31,55 @@ func NewQueueSubmission(p *reddit.Post) *QueueSubmission {
 }
 
 // Push marshals this object into JSON and adds it to the queue.
-func (s *QueueSubmission) Push(queue *redis.Queue) {
+func (s *QueueSubmission) Push(ctx context.Context, queue *redis.Queue) error {
 	jsonData, err := json.Marshal(s)
-
 	if err != nil {
-		setup.LogCommon(err).
-			WithField("permaLink", s.Permalink).
-			Error("Failed json.Marshal")
+		return err
 	}
 
-	queue.Push(string(jsonData))
+	return queue.Push(ctx, string(jsonData))
 }
 
-// PopQueue returns submissions older than 24 hours from the REDDIT_QUEUE redis queue.
-func PopQueue(queue *redis.Queue) []QueueSubmission {
+// PopQueue returns submissions at least age old from the front of the queue.
+func PopQueue(ctx context.Context, queue *redis.Queue, age time.Duration) []QueueSubmission {
 	out := []QueueSubmission{}
 
 	// flag tracks whether we have found a submission older than cut off
 	flag := true
 	for flag {
 		// look at most recent submission
-		s := queue.Peek()
+		s, err := queue.Peek(ctx)
 
-		if s == nil {
+		if err == redis.ErrEmpty {
 			// nothing returned so quit
 			flag = false
+		} else if err != nil {
+			setup.LogCommon(err).Error("Failed Peek")
+			flag = false
 		} else {
 			// convert to struct
 			q := QueueSubmission{}
-			err := json.Unmarshal([]byte(*s), &q)
+			err := json.Unmarshal([]byte(s), &q)
 			if err != nil {
 				setup.LogCommon(err).
 					WithField("queueSubmission", q).
 					Error("Failed json.Unmarshal")
 			}
 
-			// get lookback time
-			lookback, err := strconv.ParseFloat(os.Getenv("REDDIT_LOOKBACK"), 64)
-			if err != nil {
-				setup.LogCommon(err).
-					WithField("lookback", lookback).
-					Fatal("Failed REDDIT_LOOKBACK float conversion")
-			}
-
 			// check age
-			if time.Since(q.CreatedTime).Hours() >= lookback {
+			if time.Since(q.CreatedTime) >= age {
 				// remove peeked value
-				queue.Pop()
+				_, err = queue.Pop(ctx)
+				if err != nil {
+					setup.LogCommon(err).
+						WithField("permalink", q.Permalink).
+						Error("Failed Pop")
+					break
+				}
 				// add to return
 				out = append(out, q)
 			} else {
-				// got a submission newer than REDDIT_LOOKBACK
+				// got a submission younger than age
 				flag = false
 			}
 		}
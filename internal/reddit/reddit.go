@@ -1,12 +1,14 @@
 package reddit
 
 import (
+	"context"
 	"sync"
 	"time"
 
 	"github.com/jmoiron/sqlx"
 	log "github.com/sirupsen/logrus"
 	"github.com/turnage/graw/reddit"
+	"gopkg.in/guregu/null.v3"
 
 	"github.com/wpwilson10/caterpillar/internal/news"
 	"github.com/wpwilson10/caterpillar/internal/redis"
@@ -14,12 +16,18 @@ import (
 )
 
 // Driver contains the main application logic for adding submissions and comments to the database.
-func Driver(db *sqlx.DB, bot *reddit.Bot, wg *sync.WaitGroup, q QueueSubmission, articleSet *redis.Set, blacklist *news.BlackList) {
+// Each fetch adds a snapshot of the submission's votes, the submission and its comments are added
+// at the schedule's content snapshot, and the submission is queued for its next snapshot.
+func Driver(ctx context.Context, db *sqlx.DB, bot *reddit.Bot, wg *sync.WaitGroup, q QueueSubmission, schedule *Schedule, next *redis.Queue, articleSet *redis.Set, blacklist *news.BlackList, robots *news.RobotsCache) {
 	// async call
 	defer wg.Done()
 
 	// Get updated submission information
 	harvest := GetSubmission(bot, q.Permalink)
+	// the thread no longer exists, so it gets no more snapshots
+	if harvest == nil {
+		return
+	}
 
 	// sanity check that we got a single post
 	if len(harvest.Posts) != 1 {
@@ -29,11 +37,33 @@ func Driver(db *sqlx.DB, bot *reddit.Bot, wg *sync.WaitGroup, q QueueSubmission,
 		return
 	}
 	submission := harvest.Posts[0]
+	if submission == nil {
+		return
+	}
+
+	// record votes and status at this age
+	snapshot := NewSnapshot(submission, schedule.Labels[q.Snapshot])
+	snapshot.SubmissionID = null.NewInt(q.SubmissionID, q.SubmissionID != 0)
+	err := InsertSnapshot(ctx, db, snapshot)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("redditID", submission.ID).
+			WithField("snapshot", snapshot.SnapshotAge).
+			Error("Failed InsertSnapshot")
+	}
+
+	// before and after the content snapshot only votes are recorded
+	if q.Snapshot != schedule.Content {
+		if !submission.Deleted {
+			queueSnapshot(ctx, next, q)
+		}
+		return
+	}
 
 	// if we got a real not-deleted submission, and it was commented or scored enough
 	if checkSubmission(submission) {
 		// Put submission in database, returns the row ID
-		sID := InsertSubmission(db, submission)
+		sID, _ := InsertSubmission(db, submission)
 		// Transform comments from tree to list
 		commentList := ParseComments(submission.Replies)
 
@@ -47,11 +77,27 @@ func Driver(db *sqlx.DB, bot *reddit.Bot, wg *sync.WaitGroup, q QueueSubmission,
 
 		// Add comments to database
 		InsertComments(db, commentList, sID)
+		// find stock tickers mentioned in the submission and comments
+		if sID != 0 {
+			saveSubmissionMentions(ctx, db, sID)
+		}
+
+		// only stored submissions are followed after the content snapshot
+		if sID != 0 {
+			err = linkSnapshots(ctx, db, submission.ID, sID)
+			if err != nil {
+				setup.LogCommon(err).
+					WithField("submissionID", sID).
+					Error("Failed linkSnapshots")
+			}
+			q.SubmissionID = sID
+			queueSnapshot(ctx, next, q)
+		}
 
 		// only process links that go externally
 		if !(submission.IsRedditMediaDomain || submission.IsSelf) {
 			// Handle getting and linking submission to a news article
-			news.RedditNewsDriver(db, articleSet, blacklist, submission, sID)
+			news.RedditNewsDriver(ctx, db, articleSet, blacklist, robots, submission, sID)
 		}
 	}
 }
@@ -59,7 +105,10 @@ func Driver(db *sqlx.DB, bot *reddit.Bot, wg *sync.WaitGroup, q QueueSubmission,
 // checkSubmission returns true if we got a real not-deleted submission,
 // and it was commented + scored at least REDDIT_SCORE_CUTOFF times
 func checkSubmission(submission *reddit.Post) bool {
-	scoreCutoff := setup.EnvToInt("REDDIT_SCORE_CUTOFF")
+	scoreCutoff, err := setup.EnvToInt("REDDIT_SCORE_CUTOFF")
+	if err != nil {
+		setup.LogCommon(err).Fatal("Failed env int conversion")
+	}
 	return submission != nil &&
 		!submission.Deleted &&
 		(submission.NumComments+submission.Score) >= int32(scoreCutoff)
@@ -112,9 +161,10 @@ func GetSubmission(bot *reddit.Bot, permalink string) *reddit.Harvest {
 	return &harvest
 }
 
-// InsertSubmission puts a submission into the RedditSubmission database table.
-// Returns the ID given to the submission by the database.
-func InsertSubmission(db *sqlx.DB, submission *reddit.Post) int64 {
+// InsertSubmission puts a submission into the RedditSubmission database table,
+// or updates its text, votes, and comment count if it was stored before.
+// Returns the ID given to the submission by the database, and true if a new row was created.
+func InsertSubmission(db *sqlx.DB, submission *reddit.Post) (int64, bool) {
 	// Setup
 	// Use positional bindvars to not have to recreate struct
 	var insertStmt string = `INSERT INTO RedditSubmission (
@@ -141,8 +191,20 @@ func InsertSubmission(db *sqlx.DB, submission *reddit.Post) int64 {
 	var valueStmt string = `VALUES (DEFAULT, Now(), $1, $2, $3, $4, $5, $6, $7,
 									$8, $9, $10, $11, $12, $13, $14, $15, $16)`
 
-	var returnStmt string = "RETURNING submission_id;"
-	var fullStmt string = insertStmt + " " + valueStmt + " " + returnStmt
+	// the first data_entry_time is kept so backfills by entry time do not see the submission again
+	var conflictStmt string = `ON CONFLICT (reddit_id) DO UPDATE SET
+								user_name = EXCLUDED.user_name,
+								selftext = EXCLUDED.selftext,
+								selftext_html = EXCLUDED.selftext_html,
+								num_comments = EXCLUDED.num_comments,
+								score = EXCLUDED.score,
+								up_votes = EXCLUDED.up_votes,
+								down_votes = EXCLUDED.down_votes,
+								is_nsfw = EXCLUDED.is_nsfw`
+
+	// xmax is zero for rows created by this statement
+	var returnStmt string = "RETURNING submission_id, (xmax = 0) AS created;"
+	var fullStmt string = insertStmt + " " + valueStmt + " " + conflictStmt + " " + returnStmt
 
 	// convert time
 	var y int64 = int64(submission.CreatedUTC)
@@ -150,6 +212,7 @@ func InsertSubmission(db *sqlx.DB, submission *reddit.Post) int64 {
 
 	// for the return
 	var id int64
+	var created bool
 
 	// Use this hacky setup because libpq is stupid
 	// See https://github.com/jmoiron/sqlx/issues/154
@@ -170,7 +233,7 @@ func InsertSubmission(db *sqlx.DB, submission *reddit.Post) int64 {
 		submission.Downs,        // $14
 		submission.NSFW,         // $15
 		submission.IsSelf).      // $16
-		Scan(&id)
+		Scan(&id, &created)
 
 	if err != nil {
 		setup.LogCommon(err).
@@ -182,13 +245,16 @@ func InsertSubmission(db *sqlx.DB, submission *reddit.Post) int64 {
 	setup.LogCommon(nil).
 		WithField("redditID", submission.ID).
 		WithField("permalink", submission.Permalink).
+		WithField("created", created).
 		Info("Inserting reddit submission")
 
-	return id
+	return id, created
 }
 
-// InsertComments puts a list of comments into the RedditComment database table.
-func InsertComments(db *sqlx.DB, comments []*reddit.Comment, sID int64) {
+// InsertComments puts a list of comments into the RedditComment database table,
+// updating the text, votes, and deleted status of comments that were stored before.
+// Returns the number of comments created and the number updated.
+func InsertComments(db *sqlx.DB, comments []*reddit.Comment, sID int64) (int, int) {
 	// Setup
 	// Use positional bindvars to not have to recreate struct
 	var insertStmt string = `INSERT INTO RedditComment (
@@ -208,12 +274,26 @@ func InsertComments(db *sqlx.DB, comments []*reddit.Comment, sID int64) {
 
 	var valueStmt string = "VALUES (DEFAULT, Now(), $1, $2, $3, $4, $5, $6, $7, $8, $9, $10)"
 
-	var fullStmt string = insertStmt + " " + valueStmt
+	var conflictStmt string = `ON CONFLICT (submission_id, reddit_id) DO UPDATE SET
+								user_name = EXCLUDED.user_name,
+								body = EXCLUDED.body,
+								body_html = EXCLUDED.body_html,
+								up_votes = EXCLUDED.up_votes,
+								down_votes = EXCLUDED.down_votes,
+								is_deleted = EXCLUDED.is_deleted`
+
+	// xmax is zero for rows created by this statement
+	var returnStmt string = "RETURNING (xmax = 0) AS created"
+
+	var fullStmt string = insertStmt + " " + valueStmt + " " + conflictStmt + " " + returnStmt
+
+	var numCreated, numUpdated int
 
 	// start a transaction
 	tx, err := db.Beginx()
 	if err != nil {
 		log.WithError(err).Warn("InsertComments start transaction")
+		return 0, 0
 	}
 
 	// process each comment
@@ -223,7 +303,8 @@ func InsertComments(db *sqlx.DB, comments []*reddit.Comment, sID int64) {
 		cTime := time.Unix(y, 0)
 
 		// Insert into queue
-		_, err = tx.Exec(fullStmt,
+		var created bool
+		err = tx.QueryRow(fullStmt,
 			sID,              // $1
 			comment.ID,       // $2
 			comment.ParentID, // $3
@@ -233,7 +314,8 @@ func InsertComments(db *sqlx.DB, comments []*reddit.Comment, sID int64) {
 			comment.BodyHTML, // $7
 			comment.Ups,      // $8
 			comment.Downs,    // $9
-			comment.Deleted)  // $10
+			comment.Deleted). // $10
+			Scan(&created)
 
 		if err != nil {
 			log.
@@ -241,6 +323,10 @@ func InsertComments(db *sqlx.DB, comments []*reddit.Comment, sID int64) {
 				WithField("submissionID", sID).
 				WithError(err).
 				Error("InsertComments execute statement")
+		} else if created {
+			numCreated++
+		} else {
+			numUpdated++
 		}
 	}
 
@@ -248,7 +334,10 @@ func InsertComments(db *sqlx.DB, comments []*reddit.Comment, sID int64) {
 	err = tx.Commit()
 	if err != nil {
 		setup.LogCommon(err).Warn("InsertComments commiting transaction")
+		return 0, 0
 	}
+
+	return numCreated, numUpdated
 }
 
 // ParseComments takes branching comment trees and returns a list of the comments.
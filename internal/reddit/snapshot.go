@@ -0,0 +1,170 @@
+package reddit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	goredis "github.com/go-redis/redis/v7"
+	"github.com/jmoiron/sqlx"
+	"github.com/turnage/graw/reddit"
+	"gopkg.in/guregu/null.v3"
+
+	"github.com/wpwilson10/caterpillar/internal/news"
+	"github.com/wpwilson10/caterpillar/internal/redis"
+	"github.com/wpwilson10/caterpillar/internal/setup"
+)
+
+// Snapshot stores a submission's votes and status at one age in a format matching
+// the RedditSubmissionSnapshot table schema.
+type Snapshot struct {
+	SnapshotID   int64     `db:"snapshot_id"`
+	RedditID     string    `db:"reddit_id"`
+	SubmissionID null.Int  `db:"submission_id"` // null until the submission is stored at the content snapshot
+	SnapshotAge  string    `db:"snapshot_age"`  // scheduled age of the submission (e.g. 6h)
+	Score        int       `db:"score"`
+	UpVotes      int       `db:"up_votes"`
+	NumComments  int       `db:"num_comments"`
+	Awards       int       `db:"awards"` // times gilded
+	IsDeleted    bool      `db:"is_deleted"`
+	IsRemoved    bool      `db:"is_removed"`
+	IsLocked     bool      `db:"is_locked"`
+	CreatedTime  time.Time `db:"created_time"`
+	DataTime     time.Time `db:"data_entry_time"`
+}
+
+// Schedule is the ages at which submissions are fetched. Each age has its own queue, and submissions
+// move to the next queue after each fetch. Comments, ticker mentions, and news links are stored
+// once, at the content snapshot.
+type Schedule struct {
+	Ages    []time.Duration
+	Labels  []string
+	Content int // index of the age at which the submission and its comments are stored
+}
+
+// ScheduleFromEnv returns the snapshot schedule in REDDIT_SNAPSHOT_AGES (e.g. 1h,6h,24h,7d).
+// Content is stored at the first age of at least REDDIT_LOOKBACK hours, or the last age if all are younger.
+// Without REDDIT_SNAPSHOT_AGES, submissions are fetched once at REDDIT_LOOKBACK hours.
+// Ages are sorted, and each age's queue is named by its position, see snapshotQueue.
+func ScheduleFromEnv() (*Schedule, error) {
+	lookbackHours, err := strconv.ParseFloat(os.Getenv("REDDIT_LOOKBACK"), 64)
+	if err != nil {
+		return nil, fmt.Errorf("REDDIT_LOOKBACK: %w", err)
+	}
+	lookback := time.Duration(lookbackHours * float64(time.Hour))
+
+	ages, labels := []time.Duration{lookback}, []string{strconv.FormatFloat(lookbackHours, 'f', -1, 64) + "h"}
+	if s := os.Getenv("REDDIT_SNAPSHOT_AGES"); len(s) > 0 {
+		// snapshot ages are written the same way as article recrawl ages
+		ages, labels, err = news.RecrawlAges(s)
+		if err != nil {
+			return nil, fmt.Errorf("REDDIT_SNAPSHOT_AGES: %w", err)
+		}
+		if len(ages) == 0 {
+			return nil, fmt.Errorf("REDDIT_SNAPSHOT_AGES has no ages")
+		}
+	}
+
+	out := &Schedule{Ages: ages, Labels: labels, Content: len(ages) - 1}
+	for i, age := range ages {
+		if age >= lookback {
+			out.Content = i
+			break
+		}
+	}
+
+	return out, nil
+}
+
+// snapshotQueue returns the queue of submissions waiting for the snapshot at index i.
+// The first snapshot uses REDDIT_QUEUE, which the bot fills, later snapshots use queues named after it.
+// Queues are named by the position of the age in the sorted REDDIT_SNAPSHOT_AGES, not by the age itself,
+// so changing the ages while submissions are queued moves those submissions to whichever age now has their position.
+// Let the queues drain before changing REDDIT_SNAPSHOT_AGES.
+func snapshotQueue(client *goredis.Client, i int) *redis.Queue {
+	name := os.Getenv("REDDIT_QUEUE")
+	if i > 0 {
+		name = fmt.Sprintf("%s:snapshot:%d", name, i)
+	}
+
+	return redis.NewQueue(client, name)
+}
+
+// queueSnapshot adds the submission to the queue for its next snapshot, doing nothing after the last one.
+func queueSnapshot(ctx context.Context, next *redis.Queue, q QueueSubmission) {
+	if next == nil {
+		return
+	}
+
+	q.Snapshot = q.Snapshot + 1
+	err := q.Push(ctx, next)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("permalink", q.Permalink).
+			WithField("snapshot", q.Snapshot).
+			Error("Failed queueSnapshot")
+	}
+}
+
+// NewSnapshot returns a snapshot of the submission's current votes and status.
+func NewSnapshot(submission *reddit.Post, age string) *Snapshot {
+	return &Snapshot{
+		RedditID:    submission.ID,
+		SnapshotAge: age,
+		Score:       int(submission.Score),
+		UpVotes:     int(submission.Ups),
+		NumComments: int(submission.NumComments),
+		Awards:      int(submission.Gilded),
+		IsDeleted:   submission.Deleted || submission.Author == "[deleted]",
+		// moderators removing a post replaces its self text, removed link posts are not marked
+		IsRemoved:   submission.SelfText == "[removed]",
+		IsLocked:    submission.Locked,
+		CreatedTime: time.Unix(int64(submission.CreatedUTC), 0),
+		DataTime:    time.Now(),
+	}
+}
+
+// InsertSnapshot appends the snapshot to the RedditSubmissionSnapshot table.
+func InsertSnapshot(ctx context.Context, db *sqlx.DB, s *Snapshot) error {
+	var insertStmt string = `INSERT INTO RedditSubmissionSnapshot (
+								reddit_id,
+								submission_id,
+								snapshot_age,
+								score,
+								up_votes,
+								num_comments,
+								awards,
+								is_deleted,
+								is_removed,
+								is_locked,
+								created_time,
+								data_entry_time
+								)
+							 VALUES (
+								:reddit_id,
+								:submission_id,
+								:snapshot_age,
+								:score,
+								:up_votes,
+								:num_comments,
+								:awards,
+								:is_deleted,
+								:is_removed,
+								:is_locked,
+								:created_time,
+								:data_entry_time
+								)`
+
+	_, err := db.NamedExecContext(ctx, insertStmt, s)
+	return err
+}
+
+// linkSnapshots sets the submission ID of the submission's earlier snapshots once it is stored.
+func linkSnapshots(ctx context.Context, db *sqlx.DB, redditID string, submissionID int64) error {
+	_, err := db.ExecContext(ctx,
+		"UPDATE RedditSubmissionSnapshot SET submission_id = $1 WHERE reddit_id = $2 AND submission_id IS NULL",
+		submissionID, redditID)
+	return err
+}
@@ -0,0 +1,99 @@
+package reddit
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// setEnv sets or unsets the variables and returns a function that restores them.
+func setEnv(vars map[string]string) func() {
+	old := map[string]*string{}
+	for k, v := range vars {
+		if prev, ok := os.LookupEnv(k); ok {
+			old[k] = &prev
+		} else {
+			old[k] = nil
+		}
+		if len(v) == 0 {
+			os.Unsetenv(k)
+		} else {
+			os.Setenv(k, v)
+		}
+	}
+
+	return func() {
+		for k, v := range old {
+			if v == nil {
+				os.Unsetenv(k)
+			} else {
+				os.Setenv(k, *v)
+			}
+		}
+	}
+}
+
+func TestScheduleFromEnv(t *testing.T) {
+	tests := []struct {
+		name     string
+		lookback string
+		ages     string
+		want     *Schedule
+		wantErr  bool
+	}{
+		{
+			name:     "unset ages fetch once at the lookback",
+			lookback: "1.5",
+			want:     &Schedule{Ages: []time.Duration{90 * time.Minute}, Labels: []string{"1.5h"}, Content: 0},
+		},
+		{
+			name:     "content at the first age of at least the lookback",
+			lookback: "6",
+			ages:     "1h,6h,24h,7d",
+			want: &Schedule{
+				Ages:    []time.Duration{time.Hour, 6 * time.Hour, 24 * time.Hour, 7 * 24 * time.Hour},
+				Labels:  []string{"1h", "6h", "24h", "7d"},
+				Content: 1,
+			},
+		},
+		{
+			name:     "ages younger than the lookback store content at the last age",
+			lookback: "48",
+			ages:     "1h,6h,24h",
+			want: &Schedule{
+				Ages:    []time.Duration{time.Hour, 6 * time.Hour, 24 * time.Hour},
+				Labels:  []string{"1h", "6h", "24h"},
+				Content: 2,
+			},
+		},
+		{
+			name:     "unsorted ages are sorted",
+			lookback: "12",
+			ages:     "7d, 1h,24h",
+			want: &Schedule{
+				Ages:    []time.Duration{time.Hour, 24 * time.Hour, 7 * 24 * time.Hour},
+				Labels:  []string{"1h", "24h", "7d"},
+				Content: 1,
+			},
+		},
+		{name: "invalid age", lookback: "6", ages: "1h,soon", wantErr: true},
+		{name: "negative age", lookback: "6", ages: "-1h", wantErr: true},
+		{name: "no ages", lookback: "6", ages: " , ", wantErr: true},
+		{name: "missing lookback", ages: "1h", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer setEnv(map[string]string{"REDDIT_LOOKBACK": tt.lookback, "REDDIT_SNAPSHOT_AGES": tt.ages})()
+
+			got, err := ScheduleFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ScheduleFromEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ScheduleFromEnv() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package reddit
+
+import (
+	"strings"
+	"time"
+)
+
+// Submission stores a reddit submission in a format matching the RedditSubmission table schema
+type Submission struct {
+	SubmissionID  int64     `db:"submission_id" json:"submission_id"`
+	RedditID      string    `db:"reddit_id" json:"reddit_id"` // reddit's unique ID for this submission
+	Title         string    `db:"title" json:"title"`
+	URL           string    `db:"url" json:"url"`             // link to what this post is about
+	Permalink     string    `db:"permalink" json:"permalink"` // reddit's relative url for this submission
+	DataTime      time.Time `db:"data_entry_time" json:"data_entry_time"`
+	CreatedTime   time.Time `db:"created_time" json:"created_time"`
+	UserName      string    `db:"user_name" json:"user_name"`
+	SubredditName string    `db:"subreddit_name" json:"subreddit_name"`
+	SubredditID   string    `db:"subreddit_id" json:"subreddit_id"`
+	Selftext      string    `db:"selftext" json:"selftext"`
+	SelftextHTML  string    `db:"selftext_html" json:"selftext_html"`
+	NumComments   int       `db:"num_comments" json:"num_comments"`
+	Score         int       `db:"score" json:"score"`
+	UpVotes       int       `db:"up_votes" json:"up_votes"`
+	DownVotes     int       `db:"down_votes" json:"down_votes"`
+	IsNSFW        bool      `db:"is_nsfw" json:"is_nsfw"`
+	IsSelf        bool      `db:"is_self" json:"is_self"`
+}
+
+// Comment stores a reddit comment in a format matching the RedditComment table schema
+type Comment struct {
+	CommentID    int64     `db:"comment_id" json:"comment_id"`
+	SubmissionID int64     `db:"submission_id" json:"submission_id"`
+	RedditID     string    `db:"reddit_id" json:"reddit_id"` // reddit's unique ID for this comment
+	ParentID     string    `db:"parent_id" json:"parent_id"` // reddit's full name (t1_ or t3_ prefix) of what this replies to
+	DataTime     time.Time `db:"data_entry_time" json:"data_entry_time"`
+	CreatedTime  time.Time `db:"created_time" json:"created_time"`
+	UserName     string    `db:"user_name" json:"user_name"`
+	Body         string    `db:"body" json:"body"`
+	BodyHTML     string    `db:"body_html" json:"body_html"`
+	UpVotes      int       `db:"up_votes" json:"up_votes"`
+	DownVotes    int       `db:"down_votes" json:"down_votes"`
+	IsDeleted    bool      `db:"is_deleted" json:"is_deleted"`
+}
+
+// submissionColumns and commentColumns are the columns stored in Submission and Comment, in table order.
+// Selects list them instead of using * so the tables' search columns are left out.
+var (
+	submissionColumns = []string{"submission_id", "reddit_id", "title", "url", "permalink", "data_entry_time",
+		"created_time", "user_name", "subreddit_name", "subreddit_id", "selftext", "selftext_html",
+		"num_comments", "score", "up_votes", "down_votes", "is_nsfw", "is_self"}
+	commentColumns = []string{"comment_id", "submission_id", "reddit_id", "parent_id", "data_entry_time",
+		"created_time", "user_name", "body", "body_html", "up_votes", "down_votes", "is_deleted"}
+)
+
+// SubmissionColumns returns the RedditSubmission columns stored in Submission as a select list,
+// prefixed with the table alias if one is given.
+func SubmissionColumns(alias string) string {
+	return columnList(submissionColumns, alias)
+}
+
+// CommentColumns returns the RedditComment columns stored in Comment as a select list,
+// prefixed with the table alias if one is given.
+func CommentColumns(alias string) string {
+	return columnList(commentColumns, alias)
+}
+
+func columnList(columns []string, alias string) string {
+	if len(alias) == 0 {
+		return strings.Join(columns, ", ")
+	}
+
+	return alias + "." + strings.Join(columns, ", "+alias+".")
+}
+
+// FullName returns reddit's full name for the comment, which replies use as their parent ID.
+func (c *Comment) FullName() string {
+	return "t1_" + c.RedditID
+}
@@ -1,6 +1,9 @@
 package redis
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/go-redis/redis/v7"
 	"github.com/wpwilson10/caterpillar/internal/setup"
 )
@@ -27,36 +30,32 @@ func NewCappedList(client *redis.Client, name string, size int) *CappedList {
 }
 
 // Add puts the input at the front of the list and removes extra elements from the end.
-func (c *CappedList) Add(input string) {
+func (c *CappedList) Add(ctx context.Context, input string) error {
+	client := c.client.WithContext(ctx)
 	// add new element
-	err := c.client.LPush(c.name, input).Err()
+	err := client.LPush(c.name, input).Err()
 	if err != nil {
-		setup.LogCommon(err).WithField("input", input).Error("Failed LPush")
+		return err
 	}
 	// remove old elements
-	err = c.client.LTrim(c.name, 0, c.size-1).Err()
-	if err != nil {
-		setup.LogCommon(err).WithField("input", input).Error("Failed LTrim")
-	}
+	return client.LTrim(c.name, 0, c.size-1).Err()
 }
 
-// List returns the non-null elements in the list
-func (c *CappedList) List() []string {
+// List returns the non-null elements in the list.
+// Returns ErrEmpty if there is nothing in the list.
+func (c *CappedList) List(ctx context.Context) ([]string, error) {
+	client := c.client.WithContext(ctx)
 	// get size of list
-	size, err := c.client.LLen(c.name).Result()
+	size, err := client.LLen(c.name).Result()
 	if err != nil {
-		setup.LogCommon(err).Error("Failed LLen")
+		return nil, err
+	} else if size == 0 {
+		return nil, ErrEmpty
 	} else if size > c.size {
 		// sanity check that the list is not bigger than possible
-		setup.LogCommon(nil).WithField("size", size).Error("Size is larger than expected")
+		return nil, fmt.Errorf("list size %d is larger than cap %d", size, c.size)
 	}
 
 	// get the elements
-	out, err := c.client.LRange(c.name, 0, size-1).Result()
-	if err != nil {
-		setup.LogCommon(err).Error("Failed LRange")
-	}
-
-	return out
-
+	return client.LRange(c.name, 0, size-1).Result()
 }
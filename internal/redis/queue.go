@@ -1,10 +1,17 @@
 package redis
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
 	"github.com/go-redis/redis/v7"
-	"github.com/wpwilson10/caterpillar/internal/setup"
 )
 
+// ErrEmpty is returned when reading from a queue or list that has no elements.
+// This is a normal condition, not a failure of the redis server.
+var ErrEmpty = errors.New("redis: empty")
+
 // Queue implements a Redis list as a queue where name is the list key
 type Queue struct {
 	client *redis.Client
@@ -17,38 +24,36 @@ func NewQueue(client *redis.Client, name string) *Queue {
 }
 
 // Push adds the input to the end of the queue
-func (q *Queue) Push(input string) {
-	err := q.client.RPush(q.name, input).Err()
-	if err != nil {
-		setup.LogCommon(err).WithField("input", input).Error("Failed RPush")
-	}
+func (q *Queue) Push(ctx context.Context, input string) error {
+	return q.client.WithContext(ctx).RPush(q.name, input).Err()
 }
 
-// Pop returns the first value and removes it from the queue
-func (q *Queue) Pop() *string {
-	out, err := q.client.LPop(q.name).Result()
-	if err != nil {
-		setup.LogCommon(err).Error("Failed LPop")
-		return nil
+// Pop returns the first value and removes it from the queue.
+// Returns ErrEmpty if there is nothing in the queue.
+func (q *Queue) Pop(ctx context.Context) (string, error) {
+	out, err := q.client.WithContext(ctx).LPop(q.name).Result()
+	if err == redis.Nil {
+		return "", ErrEmpty
+	} else if err != nil {
+		return "", err
 	} else if len(out) == 0 {
-		return nil
+		return "", ErrEmpty
 	}
 
-	return &out
+	return out, nil
 }
 
-// Peek returns the first value but does not remove it from the queue
-func (q *Queue) Peek() *string {
-	out, err := q.client.LRange(q.name, 0, 0).Result()
+// Peek returns the first value but does not remove it from the queue.
+// Returns ErrEmpty if there is nothing in the queue.
+func (q *Queue) Peek(ctx context.Context) (string, error) {
+	out, err := q.client.WithContext(ctx).LRange(q.name, 0, 0).Result()
 	if err != nil {
-		setup.LogCommon(err).Error("Failed LRange")
-		return nil
+		return "", err
 	} else if len(out) == 0 {
-		return nil
+		return "", ErrEmpty
 	} else if len(out) != 1 {
-		setup.LogCommon(err).Error("Unexpected LRange return size")
-		return nil
+		return "", fmt.Errorf("unexpected LRange return size %d", len(out))
 	}
 
-	return &(out[0])
+	return out[0], nil
 }
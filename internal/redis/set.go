@@ -6,8 +6,9 @@
 package redis
 
 import (
+	"context"
+
 	"github.com/go-redis/redis/v7"
-	"github.com/wpwilson10/caterpillar/internal/setup"
 )
 
 // Set implements a Redis set where name is the set key
@@ -22,20 +23,12 @@ func NewSet(client *redis.Client, name string) *Set {
 }
 
 // Add puts input in the set if it does not already exist, otherwise does nothing.
-func (s *Set) Add(input string) {
-	err := s.client.SAdd(s.name, input).Err()
-	if err != nil {
-		setup.LogCommon(err).WithField("input", input).Error("Failed SAdd")
-	}
+func (s *Set) Add(ctx context.Context, input string) error {
+	return s.client.WithContext(ctx).SAdd(s.name, input).Err()
 }
 
 // IsMember returns true if input is in the set, false otherwise.
-func (s *Set) IsMember(input string) bool {
-	out, err := s.client.SIsMember(s.name, input).Result()
-	if err != nil {
-		setup.LogCommon(err).WithField("input", input).Error("Failed SIsMember")
-		return false
-	}
-
-	return out
+// An error means membership is unknown and should not be treated as false.
+func (s *Set) IsMember(ctx context.Context, input string) (bool, error) {
+	return s.client.WithContext(ctx).SIsMember(s.name, input).Result()
 }
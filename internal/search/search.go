@@ -0,0 +1,259 @@
+// Package search runs full text searches over news articles and reddit submissions and comments.
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/guregu/null.v3"
+
+	"github.com/wpwilson10/caterpillar/internal/setup"
+)
+
+// Kinds of content that can be searched
+const (
+	KindArticle    = "article"
+	KindSubmission = "submission"
+	KindComment    = "comment"
+)
+
+// default and largest number of results
+const (
+	defaultLimit = 20
+	maxLimit     = 500
+)
+
+// Snippet highlighting, query terms in snippets are wrapped in these
+const (
+	HighlightStart = "<b>"
+	HighlightStop  = "</b>"
+)
+
+// ts_headline options for snippets
+var headlineOptions = fmt.Sprintf(`StartSel=%s, StopSel=%s, MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=" ... "`,
+	HighlightStart, HighlightStop)
+
+// ErrEmptyQuery is returned when a search has no query text.
+var ErrEmptyQuery = errors.New("search: empty query")
+
+// ErrHostAndSubreddit is returned when a search filters by both a host and a subreddit,
+// which no content has.
+var ErrHostAndSubreddit = errors.New("search: host and subreddit can not both be set")
+
+// ErrNoKinds is returned when the host or subreddit filter excludes every kind of content searched.
+var ErrNoKinds = errors.New("search: no kinds of content match the host or subreddit filter")
+
+// Query is a full text search.
+// Text uses web search syntax: words, "quoted phrases", or, and -excluded words.
+type Query struct {
+	Text      string
+	Kinds     []string  // kinds of content to search, all kinds if empty
+	Host      string    // only articles from this host, excludes reddit content
+	Subreddit string    // only reddit content from this subreddit, excludes articles
+	From      time.Time // only content published at or after this time, ignored if zero
+	To        time.Time // only content published before this time, ignored if zero
+	Limit     int       // most results returned, defaults to 20
+}
+
+// Result is a piece of content matching a query.
+type Result struct {
+	Kind    string    `db:"kind"`
+	ID      int64     `db:"id"` // article_id, submission_id, or comment_id
+	Title   string    `db:"title"`
+	Link    string    `db:"link"`   // article link or reddit permalink
+	Source  string    `db:"source"` // host of articles, subreddit of reddit content
+	Time    null.Time `db:"time"`   // published or created time
+	Rank    float64   `db:"rank"`
+	Snippet string    `db:"snippet"` // matching text with query terms highlighted
+}
+
+// searches by kind, each takes the query text, host or subreddit, from, to, limit, and headline options.
+// The inner select ranks and limits matches so snippets are only made for returned rows.
+var searches = map[string]string{
+	KindArticle: `SELECT kind, id, title, link, source, time, rank,
+					ts_headline('english', COALESCE(body, ''), q, $6) AS snippet
+				 FROM (
+					SELECT 'article' AS kind, a.article_id AS id, COALESCE(a.title, a.source_title, '') AS title,
+						a.link, a.host AS source,
+						COALESCE(a.published_time, a.source_published_time, a.data_entry_time) AS time,
+						ts_rank_cd(a.search, q) AS rank, a.body, q
+					FROM NewsArticle a, websearch_to_tsquery('english', $1) q
+					WHERE a.search @@ q
+						AND ($2 = '' OR a.host = $2)
+						AND ($3::timestamptz IS NULL OR COALESCE(a.published_time, a.source_published_time, a.data_entry_time) >= $3)
+						AND ($4::timestamptz IS NULL OR COALESCE(a.published_time, a.source_published_time, a.data_entry_time) < $4)
+					ORDER BY rank DESC
+					LIMIT $5
+				 ) matches
+				 ORDER BY rank DESC`,
+
+	KindSubmission: `SELECT kind, id, title, link, source, time, rank,
+						ts_headline('english', body, q, $6) AS snippet
+					 FROM (
+						SELECT 'submission' AS kind, s.submission_id AS id, COALESCE(s.title, '') AS title,
+							'https://www.reddit.com' || s.permalink AS link, s.subreddit_name AS source,
+							s.created_time AS time, ts_rank_cd(s.search, q) AS rank,
+							COALESCE(NULLIF(s.selftext, ''), s.title, '') AS body, q
+						FROM RedditSubmission s, websearch_to_tsquery('english', $1) q
+						WHERE s.search @@ q
+							AND ($2 = '' OR lower(s.subreddit_name) = lower($2))
+							AND ($3::timestamptz IS NULL OR s.created_time >= $3)
+							AND ($4::timestamptz IS NULL OR s.created_time < $4)
+						ORDER BY rank DESC
+						LIMIT $5
+					 ) matches
+					 ORDER BY rank DESC`,
+
+	KindComment: `SELECT kind, id, title, link, source, time, rank,
+					ts_headline('english', body, q, $6) AS snippet
+				 FROM (
+					SELECT 'comment' AS kind, c.comment_id AS id, COALESCE(s.title, '') AS title,
+						'https://www.reddit.com' || s.permalink || c.reddit_id AS link, s.subreddit_name AS source,
+						c.created_time AS time, ts_rank_cd(c.search, q) AS rank, COALESCE(c.body, '') AS body, q
+					FROM RedditComment c
+					JOIN RedditSubmission s ON s.submission_id = c.submission_id,
+					websearch_to_tsquery('english', $1) q
+					WHERE c.search @@ q
+						AND ($2 = '' OR lower(s.subreddit_name) = lower($2))
+						AND ($3::timestamptz IS NULL OR c.created_time >= $3)
+						AND ($4::timestamptz IS NULL OR c.created_time < $4)
+					ORDER BY rank DESC
+					LIMIT $5
+				 ) matches
+				 ORDER BY rank DESC`,
+}
+
+// Search returns the content best matching the query, highest rank first.
+// Each kind is searched separately and the results are merged by rank.
+func Search(ctx context.Context, db *sqlx.DB, q *Query) ([]*Result, error) {
+	text := strings.TrimSpace(q.Text)
+	if len(text) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	if len(q.Host) > 0 && len(q.Subreddit) > 0 {
+		return nil, ErrHostAndSubreddit
+	}
+	kinds := q.kinds()
+	if len(kinds) == 0 {
+		return nil, ErrNoKinds
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	} else if limit > maxLimit {
+		limit = maxLimit
+	}
+	from := null.NewTime(q.From, !q.From.IsZero())
+	to := null.NewTime(q.To, !q.To.IsZero())
+
+	out := []*Result{}
+	for _, kind := range kinds {
+		// a host only applies to articles and a subreddit only to reddit content
+		filter := q.Subreddit
+		if kind == KindArticle {
+			filter = q.Host
+		}
+
+		results := []*Result{}
+		err := db.SelectContext(ctx, &results, searches[kind], text, filter, from, to, limit, headlineOptions)
+		if err != nil {
+			return nil, fmt.Errorf("search %s: %w", kind, err)
+		}
+		out = append(out, results...)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Rank > out[j].Rank
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+
+	return out, nil
+}
+
+// kinds returns the kinds of content the query searches.
+func (q *Query) kinds() []string {
+	kinds := q.Kinds
+	if len(kinds) == 0 {
+		kinds = []string{KindArticle, KindSubmission, KindComment}
+	}
+
+	out := []string{}
+	for _, kind := range kinds {
+		if _, ok := searches[kind]; !ok {
+			continue
+		}
+		// hosts are only known for articles, subreddits only for reddit content
+		if len(q.Host) > 0 && kind != KindArticle {
+			continue
+		}
+		if len(q.Subreddit) > 0 && kind == KindArticle {
+			continue
+		}
+		out = append(out, kind)
+	}
+
+	return out
+}
+
+// ParseKinds splits a comma separated list of kinds, where news means articles
+// and reddit means submissions and comments. Returns nil, meaning all kinds, for an empty list.
+func ParseKinds(s string) ([]string, error) {
+	out := []string{}
+	for _, kind := range strings.Split(s, ",") {
+		switch kind = strings.ToLower(strings.TrimSpace(kind)); kind {
+		case "":
+		case "news":
+			out = append(out, KindArticle)
+		case "reddit":
+			out = append(out, KindSubmission, KindComment)
+		case KindArticle, KindSubmission, KindComment:
+			out = append(out, kind)
+		default:
+			return nil, fmt.Errorf("search: unknown kind %s", kind)
+		}
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+
+	return out, nil
+}
+
+// App prints the results of a search with query terms in bold.
+func App(q *Query) {
+	ctx := context.Background()
+	// connect to database
+	db, err := setup.SQL(ctx)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to SQL database")
+	}
+
+	results, err := Search(ctx, db, q)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("query", q.Text).
+			Fatal("Failed Search")
+	}
+
+	// terminal bold instead of html tags
+	bold := strings.NewReplacer(HighlightStart, "\x1b[1m", HighlightStop, "\x1b[0m")
+	for _, r := range results {
+		fmt.Printf("%s %d %.3f %s %s\n  %s\n  %s\n  %s\n\n",
+			r.Kind, r.ID, r.Rank, r.Time.Time.Format("2006-01-02"), r.Source,
+			r.Title, r.Link, bold.Replace(strings.Join(strings.Fields(r.Snippet), " ")))
+	}
+
+	setup.LogCommon(nil).
+		WithField("query", q.Text).
+		WithField("NumResults", len(results)).
+		WithField("RunTime", setup.RunTime().String()).
+		Info("RunSummary")
+}
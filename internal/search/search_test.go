@@ -0,0 +1,74 @@
+package search
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestParseKinds(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{" , ", nil, false},
+		{"news", []string{KindArticle}, false},
+		{"reddit", []string{KindSubmission, KindComment}, false},
+		{"Article, comment", []string{KindArticle, KindComment}, false},
+		{"news,submission", []string{KindArticle, KindSubmission}, false},
+		{"articles", nil, true},
+		{"news,tweets", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseKinds(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseKinds(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseKinds(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestQueryKinds(t *testing.T) {
+	tests := []struct {
+		name string
+		q    Query
+		want []string
+	}{
+		{"all kinds by default", Query{}, []string{KindArticle, KindSubmission, KindComment}},
+		{"given kinds", Query{Kinds: []string{KindComment, KindArticle}}, []string{KindComment, KindArticle}},
+		{"unknown kinds are skipped", Query{Kinds: []string{"tweet", KindSubmission}}, []string{KindSubmission}},
+		{"host searches only articles", Query{Host: "www.example.com"}, []string{KindArticle}},
+		{"subreddit searches only reddit", Query{Subreddit: "stocks"}, []string{KindSubmission, KindComment}},
+		{"host with reddit kinds", Query{Host: "www.example.com", Kinds: []string{KindComment}}, []string{}},
+		{"host and subreddit", Query{Host: "www.example.com", Subreddit: "stocks"}, []string{}},
+	}
+
+	for _, tt := range tests {
+		if got := tt.q.kinds(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: kinds() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSearchRejectsEmptyResults(t *testing.T) {
+	// these fail before the database is used
+	tests := []struct {
+		q    Query
+		want error
+	}{
+		{Query{Text: "  "}, ErrEmptyQuery},
+		{Query{Text: "earnings", Host: "www.example.com", Subreddit: "stocks"}, ErrHostAndSubreddit},
+		{Query{Text: "earnings", Subreddit: "stocks", Kinds: []string{KindArticle}}, ErrNoKinds},
+	}
+
+	for _, tt := range tests {
+		if _, err := Search(context.Background(), nil, &tt.q); err != tt.want {
+			t.Errorf("Search(%+v) error = %v, want %v", tt.q, err, tt.want)
+		}
+	}
+}
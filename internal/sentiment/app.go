@@ -0,0 +1,125 @@
+package sentiment
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/wpwilson10/caterpillar/internal/news"
+	"github.com/wpwilson10/caterpillar/internal/setup"
+)
+
+// BackfillApp scores the kinds of content entered in [from, to) in batches of batchSize, scoring already
+// scored content again. Progress is saved in the NewsBackfill table so that running again with the same range resumes.
+func BackfillApp(kinds []string, from time.Time, to time.Time, batchSize int) {
+	if !from.Before(to) || batchSize < 1 {
+		setup.LogCommon(nil).
+			WithField("from", from).
+			WithField("to", to).
+			WithField("batch", batchSize).
+			Fatal("Invalid backfill range or batch size")
+	}
+
+	ctx := context.Background()
+	// connect to database
+	db, err := setup.SQL(ctx)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to SQL database")
+	}
+
+	var numProcessed, numScored int64
+	for _, kind := range kinds {
+		b, err := news.LoadBackfill(ctx, db, "sentiment-"+kind, from, to)
+		if err != nil {
+			setup.LogCommon(err).Fatal("Failed LoadBackfill")
+		}
+		if b.CompletedTime.Valid {
+			fmt.Println("Backfill already complete", b.Name)
+		}
+
+		err = Backfill(ctx, db, kind, b, batchSize)
+		if err != nil {
+			setup.LogCommon(err).
+				WithField("backfill", b.Name).
+				WithField("LastID", b.LastID).
+				Fatal("Failed sentiment Backfill")
+		}
+		numProcessed = numProcessed + b.NumProcessed
+		numScored = numScored + b.NumArticles
+	}
+
+	// run summary
+	setup.LogCommon(nil).
+		WithField("kinds", kinds).
+		WithField("NumProcessed", numProcessed).
+		WithField("NumScored", numScored).
+		WithField("RunTime", setup.RunTime().String()).
+		Info("RunSummary")
+}
+
+// IncrementalApp scores the kinds of content added since the saved watermark of each kind,
+// in batches of batchSize. Run it after collecting to keep scores current.
+func IncrementalApp(kinds []string, batchSize int) {
+	if batchSize < 1 {
+		setup.LogCommon(nil).
+			WithField("batch", batchSize).
+			Fatal("Invalid batch size")
+	}
+
+	ctx := context.Background()
+	// connect to database
+	db, err := setup.SQL(ctx)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to SQL database")
+	}
+
+	var numScored int64
+	for _, kind := range kinds {
+		n, err := Incremental(ctx, db, kind, batchSize)
+		if err != nil {
+			setup.LogCommon(err).
+				WithField("kind", kind).
+				Fatal("Failed sentiment Incremental")
+		}
+		fmt.Println("Scored", kind, n)
+		numScored = numScored + n
+	}
+
+	// run summary
+	setup.LogCommon(nil).
+		WithField("kinds", kinds).
+		WithField("NumScored", numScored).
+		WithField("RunTime", setup.RunTime().String()).
+		Info("RunSummary")
+}
+
+// SeriesApp prints the daily average sentiment of content grouped by a host, subreddit, or listing symbol,
+// published in the optional range [from, to).
+func SeriesApp(by string, value string, from time.Time, to time.Time) {
+	ctx := context.Background()
+	// connect to database
+	db, err := setup.SQL(ctx)
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to SQL database")
+	}
+
+	points, err := Series(ctx, db, by, value, from, to)
+	if err != nil {
+		setup.LogCommon(err).
+			WithField("series", by).
+			WithField("value", value).
+			Fatal("Failed sentiment Series")
+	}
+
+	fmt.Println("day", "scored", "compound", "positive", "negative")
+	for _, p := range points {
+		fmt.Printf("%s %d %.4f %.4f %.4f\n", p.Day.Format("2006-01-02"), p.NumScored, p.Compound, p.Positive, p.Negative)
+	}
+
+	setup.LogCommon(nil).
+		WithField("series", by).
+		WithField("value", value).
+		WithField("NumDays", len(points)).
+		WithField("RunTime", setup.RunTime().String()).
+		Info("RunSummary")
+}
@@ -0,0 +1,117 @@
+package sentiment
+
+// lexicon maps lower case words, emoticons, and emoji to valences from -4 (most negative) to 4 (most positive).
+// General words use VADER's mean human ratings, finance and reddit terms are rated the same way.
+var lexicon = map[string]float64{
+	// general positive
+	"good": 1.9, "great": 3.1, "excellent": 2.7, "amazing": 2.8, "awesome": 3.1, "fantastic": 2.6,
+	"wonderful": 2.7, "superb": 3.1, "outstanding": 3.0, "terrific": 2.7, "brilliant": 2.8, "best": 3.2,
+	"better": 1.9, "nice": 1.8, "love": 3.2, "loved": 2.9, "loves": 2.7, "loving": 2.9, "like": 1.5,
+	"liked": 1.8, "likes": 1.8, "happy": 2.7, "happier": 2.4, "glad": 2.0, "pleased": 1.9, "delighted": 2.9,
+	"excited": 1.4, "exciting": 2.2, "win": 2.8, "wins": 2.7, "winning": 2.4, "winner": 2.8, "won": 2.7,
+	"success": 2.7, "successful": 2.8, "succeed": 2.2, "succeeded": 1.8, "strong": 2.3, "stronger": 1.6,
+	"strongest": 1.9, "positive": 2.6, "optimistic": 1.3, "optimism": 2.5, "hope": 1.9, "hopeful": 1.6,
+	"confident": 2.2, "confidence": 2.3, "perfect": 2.7, "impressive": 2.3, "impressed": 2.1, "beautiful": 2.9,
+	"thanks": 1.9, "thank": 1.5, "grateful": 2.0, "fun": 2.3, "funny": 1.9, "lol": 1.8, "haha": 2.0,
+	"cool": 1.3, "wow": 2.8, "yes": 1.7, "agree": 1.5, "support": 1.7, "helpful": 1.8, "help": 1.7,
+	"benefit": 2.0, "benefits": 1.6, "improve": 1.9, "improved": 2.1, "improvement": 2.0, "improving": 1.8,
+	"recover": 1.0, "recovery": 1.4, "recovered": 1.4, "safe": 1.9, "secure": 1.4, "solid": 0.8,
+	"fair": 1.3, "fine": 0.8, "ok": 1.2, "okay": 0.9, "well": 1.1, "easy": 1.9, "smart": 1.7, "wise": 1.8,
+	"free": 2.3, "proud": 2.1, "celebrate": 2.7, "reward": 2.0, "rewarding": 2.4, "opportunity": 1.8,
+	"promising": 1.7, "favorable": 2.1, "favorite": 2.0, "welcome": 2.0, "upgrade": 1.6, "upgraded": 1.6,
+	"approve": 1.9, "approved": 1.8, "approval": 2.2, "praise": 2.6, "innovative": 1.9, "efficient": 1.8,
+	"robust": 1.4, "healthy": 1.7, "stable": 1.2, "resilient": 1.2, "thrive": 2.2, "thriving": 2.3,
+	"boost": 1.7, "boosted": 1.5, "encouraging": 2.4, "lucky": 1.8, "luck": 2.0, "worth": 0.9,
+	"valuable": 2.1, "useful": 1.9, "interesting": 1.7, "kind": 2.4, "honest": 2.3, "trust": 2.3,
+
+	// general negative
+	"bad": -2.5, "worse": -2.1, "worst": -3.1, "terrible": -2.1, "horrible": -2.5, "awful": -2.0,
+	"poor": -2.1, "hate": -2.7, "hated": -3.2, "hates": -1.9, "sad": -2.1, "unhappy": -1.8, "angry": -2.3,
+	"anger": -2.7, "upset": -1.6, "afraid": -2.0, "fear": -2.2, "fears": -1.8, "scared": -1.9, "worry": -1.9,
+	"worried": -1.2, "worries": -2.2, "concern": -1.5, "concerned": -1.3, "concerns": -1.5, "problem": -1.7,
+	"problems": -1.7, "trouble": -1.7, "risk": -1.1, "risky": -0.8, "danger": -2.4, "dangerous": -2.1,
+	"fail": -2.5, "failed": -2.3, "fails": -2.2, "failure": -2.3, "lose": -1.7, "losing": -1.6, "lost": -1.3,
+	"loser": -2.4, "losers": -2.4, "weak": -1.9, "weaker": -1.9, "weakness": -1.5, "negative": -2.7,
+	"pessimistic": -1.5, "wrong": -2.1, "stupid": -2.4, "dumb": -2.3, "idiot": -2.3, "idiots": -2.6,
+	"crazy": -1.4, "scam": -2.8, "fraud": -2.8, "fraudulent": -3.2, "lie": -1.6, "lies": -1.8, "liar": -3.1,
+	"cheat": -2.0, "corrupt": -3.0, "crisis": -3.1, "disaster": -3.1, "catastrophe": -3.4, "collapse": -2.2,
+	"collapsed": -2.2, "damage": -2.2, "damaged": -1.9, "hurt": -2.4, "hurts": -2.1, "pain": -2.3,
+	"painful": -1.9, "suffer": -2.5, "suffering": -2.1, "kill": -3.7, "killed": -3.5, "killing": -3.4,
+	"dead": -3.3, "death": -2.9, "die": -2.9, "dies": -2.9, "war": -2.9, "attack": -2.1, "threat": -2.4,
+	"threats": -1.8, "violence": -3.1, "victim": -2.8, "victims": -2.8, "sick": -2.3, "disease": -1.7,
+	"pandemic": -2.3, "outbreak": -2.0, "lawsuit": -1.7, "sued": -1.6, "sue": -1.6, "penalty": -2.0,
+	"fined": -1.5, "guilty": -1.8, "ban": -2.6, "banned": -2.0, "warn": -1.4, "warning": -1.4,
+	"warns": -0.4, "cut": -1.1, "cuts": -1.2, "layoffs": -2.2, "fired": -2.6, "unemployment": -1.9,
+	"uncertain": -1.2, "uncertainty": -1.4, "doubt": -1.5, "doubts": -1.2, "disappoint": -1.7,
+	"disappointed": -1.9, "disappointing": -2.2, "disappointment": -2.3, "annoying": -1.7, "boring": -1.3,
+	"useless": -1.8, "waste": -1.8, "wasted": -2.2, "broke": -1.8, "broken": -2.1, "ugly": -2.3,
+	"sucks": -1.5, "suck": -1.9, "shit": -2.6, "crap": -1.6, "damn": -1.7, "wtf": -2.8, "no": -1.2,
+	"reject": -1.7, "rejected": -2.3, "denied": -1.6, "delay": -1.3, "delayed": -0.9, "shortage": -1.4,
+	"deficit": -1.7, "debt": -1.5, "default": -1.1, "bankrupt": -2.6, "bankruptcy": -2.6, "struggle": -1.8,
+	"struggling": -1.8, "slow": -0.6, "harm": -2.5, "miss": -0.6, "missed": -1.2, "downgrade": -1.6,
+	"downgraded": -1.6, "volatile": -1.0, "volatility": -0.6, "panic": -2.3, "sorry": -0.3, "cry": -2.1,
+
+	// markets and investing
+	"bullish": 2.0, "bull": 1.0, "bulls": 0.9, "bearish": -2.0, "bear": -1.0, "bears": -0.9,
+	"rally": 1.8, "rallies": 1.7, "rallied": 1.7, "surge": 1.6, "surges": 1.6, "surged": 1.6, "soar": 2.0,
+	"soars": 2.0, "soared": 2.0, "jump": 0.8, "jumps": 0.8, "jumped": 0.8, "climb": 0.9, "climbs": 0.9,
+	"climbed": 0.9, "gain": 2.0, "gains": 1.8, "gained": 1.6, "rise": 0.8, "rises": 0.8, "rose": 0.8,
+	"rising": 0.8, "beat": 1.2, "beats": 1.2, "outperform": 1.8, "outperformed": 1.8, "profit": 1.9,
+	"profits": 1.9, "profitable": 2.1, "record": 0.7, "high": 0.4, "growth": 1.6, "growing": 1.3,
+	"grow": 1.3, "upside": 1.6, "breakout": 1.4, "moon": 2.0, "mooning": 2.6, "tendies": 2.4,
+	"gainz": 2.4, "stonks": 1.2, "rocket": 1.8, "squeeze": 0.6, "dividend": 0.8, "buyback": 0.9,
+	"undervalued": 1.2, "green": 0.6, "tailwind": 1.2, "tailwinds": 1.2, "hodl": 0.8, "diamond": 1.0,
+	"plunge": -2.2, "plunges": -2.2, "plunged": -2.2, "plummet": -2.6, "plummets": -2.6, "plummeted": -2.6,
+	"crash": -2.6, "crashes": -2.6, "crashed": -2.6, "tumble": -1.8, "tumbles": -1.8, "tumbled": -1.8,
+	"slump": -1.8, "slumps": -1.8, "slumped": -1.8, "sink": -1.4, "sinks": -1.4, "sank": -1.4,
+	"drop": -1.1, "drops": -1.1, "dropped": -1.1, "fall": -1.1, "falls": -1.1, "fell": -1.1,
+	"falling": -1.1, "decline": -1.2, "declines": -1.2, "declined": -1.2, "loss": -1.8, "losses": -1.8,
+	"selloff": -1.8, "sell-off": -1.8, "recession": -2.5, "downturn": -1.9, "inflation": -1.0,
+	"underperform": -1.8, "underperformed": -1.8, "overvalued": -1.2, "bubble": -1.3, "downside": -1.6,
+	"headwind": -1.2, "headwinds": -1.2, "red": -0.6, "bagholder": -2.0, "bagholders": -2.0,
+	"bagholding": -2.0, "rekt": -2.6, "dump": -1.6, "dumped": -1.6, "dumping": -1.6, "tank": -1.6,
+	"tanked": -1.8, "tanking": -1.8, "drilling": -1.2, "dilution": -1.5, "short": -0.5, "shorts": -0.4,
+	"guh": -2.4, "fud": -1.6, "scammed": -2.8, "delisted": -2.2, "margin": -0.2,
+
+	// emoticons
+	":)": 2.0, ":-)": 1.3, ":D": 2.3, ":-D": 2.3, ";)": 1.9, ";-)": 1.0, ":P": 1.4, "xD": 1.8, "XD": 2.8,
+	"<3": 1.9, ":(": -1.9, ":-(": -1.5, ":'(": -2.2, "D:": -0.9, ":/": -1.4, ":-/": -1.4, ">:(": -2.5,
+
+	// emoji
+	"😀": 2.0, "😃": 2.0, "😄": 2.2, "😁": 2.0, "😂": 1.9, "🤣": 2.0, "😊": 2.2, "😍": 2.8, "🥰": 2.6,
+	"😎": 1.8, "👍": 1.9, "👏": 1.9, "🙌": 2.0, "🎉": 2.4, "❤": 2.6, "💯": 1.8, "💪": 1.6, "🔥": 1.2,
+	"🚀": 2.0, "🌙": 1.0, "💎": 1.4, "🙏": 1.3, "💰": 1.6, "🤑": 1.8, "📈": 1.6, "🐂": 1.0, "✅": 1.2,
+	"😢": -2.1, "😭": -2.2, "😞": -2.0, "😔": -1.8, "😟": -1.6, "😠": -2.3, "😡": -2.6, "🤬": -2.8,
+	"😱": -1.8, "😨": -1.9, "😰": -1.8, "🤮": -2.4, "💩": -1.8, "👎": -1.9, "📉": -1.6, "🐻": -1.0,
+	"💀": -1.2, "🤡": -1.6, "🩸": -1.8, "❌": -1.2, "🙄": -1.0, "😬": -0.8,
+}
+
+// boosters change the valence of the sentiment word after them, away from zero or towards it
+var boosters = map[string]float64{
+	"absolutely": boostIncrease, "amazingly": boostIncrease, "awfully": boostIncrease,
+	"completely": boostIncrease, "considerably": boostIncrease, "decidedly": boostIncrease,
+	"deeply": boostIncrease, "enormously": boostIncrease, "entirely": boostIncrease,
+	"especially": boostIncrease, "exceptionally": boostIncrease, "extremely": boostIncrease,
+	"fabulously": boostIncrease, "fully": boostIncrease, "greatly": boostIncrease, "highly": boostIncrease,
+	"hugely": boostIncrease, "incredibly": boostIncrease, "intensely": boostIncrease,
+	"majorly": boostIncrease, "massively": boostIncrease, "more": boostIncrease, "most": boostIncrease,
+	"particularly": boostIncrease, "purely": boostIncrease, "quite": boostIncrease, "really": boostIncrease,
+	"remarkably": boostIncrease, "so": boostIncrease, "substantially": boostIncrease,
+	"thoroughly": boostIncrease, "totally": boostIncrease, "tremendously": boostIncrease,
+	"uber": boostIncrease, "unbelievably": boostIncrease, "unusually": boostIncrease,
+	"utterly": boostIncrease, "very": boostIncrease, "super": boostIncrease, "hella": boostIncrease,
+	"almost": boostDecrease, "barely": boostDecrease, "hardly": boostDecrease, "less": boostDecrease,
+	"little": boostDecrease, "marginally": boostDecrease, "occasionally": boostDecrease,
+	"partly": boostDecrease, "scarcely": boostDecrease, "slightly": boostDecrease,
+	"somewhat": boostDecrease, "kinda": boostDecrease, "sorta": boostDecrease,
+}
+
+// negations flip and weaken the valence of sentiment words up to three words after them.
+// Contractions ending in n't are also negations.
+var negations = map[string]bool{
+	"not": true, "no": true, "never": true, "none": true, "nobody": true, "nothing": true, "neither": true,
+	"nor": true, "nowhere": true, "cannot": true, "without": true, "rarely": true, "seldom": true,
+	"despite": true, "aint": true, "arent": true, "cant": true, "couldnt": true, "didnt": true,
+	"doesnt": true, "dont": true, "hadnt": true, "hasnt": true, "havent": true, "isnt": true,
+	"shouldnt": true, "wasnt": true, "werent": true, "wont": true, "wouldnt": true,
+}
@@ -0,0 +1,346 @@
+// Package sentiment scores English text with a lexicon and rules in the style of VADER
+// (Hutto and Gilbert, 2014), and stores scores for articles and reddit content.
+package sentiment
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Version of the lexicon and rules, stored with scores so changed scoring can be rerun
+const Version = 1
+
+// Rule constants from VADER
+const (
+	boostIncrease  = 0.293 // added by words like very
+	boostDecrease  = -0.293
+	capsIncrease   = 0.733 // added to upper case words in mixed case text
+	negationScalar = -0.74 // applied to words after a negation
+	butBefore      = 0.5   // weight of words before but
+	butAfter       = 1.5   // weight of words after but
+	exclaimBoost   = 0.292 // per exclamation point, up to maxExclaims
+	maxExclaims    = 4
+	questionBoost  = 0.18 // per question mark after the first, up to maxQuestions
+	maxQuestions   = 3
+	maxQuestionSum = 0.96
+	normalAlpha    = 15 // approximates the maximum expected sum of valences
+)
+
+// Scores for a piece of text
+type Scores struct {
+	Compound  float64 // normalized sum of valences, from -1 (most negative) to 1 (most positive)
+	Positive  float64 // share of the text that is positive
+	Negative  float64 // share of the text that is negative
+	Neutral   float64 // share of the text that is neutral
+	Sentences int     // sentences scored, zero if the text was empty
+}
+
+// Score returns the scores of a short text such as a sentence, title, or comment, scored as one piece.
+func Score(text string) Scores {
+	words := tokenize(text)
+	if len(words) == 0 {
+		return Scores{}
+	}
+
+	capDiff := isCapDiff(words)
+	lower := make([]string, len(words))
+	for i, w := range words {
+		lower[i] = strings.ToLower(w)
+	}
+
+	valences := make([]float64, len(words))
+	for i := range words {
+		// the kind in "kind of" is not kindness
+		if i+1 < len(lower) && lower[i] == "kind" && lower[i+1] == "of" {
+			continue
+		}
+		valences[i] = wordValence(words, lower, i, capDiff)
+	}
+	butCheck(lower, valences)
+
+	sum := 0.0
+	for _, v := range valences {
+		sum += v
+	}
+	emphasis := punctuationEmphasis(text)
+	if sum > 0 {
+		sum += emphasis
+	} else if sum < 0 {
+		sum -= emphasis
+	}
+
+	out := Scores{Compound: normalize(sum), Sentences: 1}
+	out.Positive, out.Negative, out.Neutral = shares(valences, emphasis)
+
+	return out
+}
+
+// ScoreDocument returns the scores of a longer text, such as an article, as the average of its sentences.
+// Sentences without sentiment count as neutral.
+func ScoreDocument(texts ...string) Scores {
+	out := Scores{}
+	for _, text := range texts {
+		for _, sentence := range splitSentences(text) {
+			s := Score(sentence)
+			if s.Sentences == 0 {
+				continue
+			}
+			out.Compound += s.Compound
+			out.Positive += s.Positive
+			out.Negative += s.Negative
+			out.Neutral += s.Neutral
+			out.Sentences++
+		}
+	}
+	if out.Sentences == 0 {
+		return out
+	}
+
+	n := float64(out.Sentences)
+	out.Compound = round(out.Compound / n)
+	out.Positive = round(out.Positive / n)
+	out.Negative = round(out.Negative / n)
+	out.Neutral = round(out.Neutral / n)
+
+	return out
+}
+
+// wordValence returns the valence of the word at i after boosters, capitals, and negations before it.
+func wordValence(words []string, lower []string, i int, capDiff bool) float64 {
+	word := lower[i]
+	if _, ok := boosters[word]; ok {
+		return 0
+	}
+	valence, ok := lexicon[word]
+	if !ok {
+		valence, ok = lexicon[words[i]] // emoticons such as :D are case sensitive
+	}
+	if !ok || valence == 0 {
+		return 0
+	}
+
+	if capDiff && isUpper(words[i]) {
+		valence += math.Copysign(capsIncrease, valence)
+	}
+
+	// boosters and negations up to three words before, with less effect further away
+	for start := 1; start <= 3 && i-start >= 0; start++ {
+		prev := lower[i-start]
+		if _, isWord := lexicon[prev]; !isWord {
+			boost := scalarIncDec(words[i-start], prev, valence, capDiff)
+			if start == 2 {
+				boost *= 0.95
+			} else if start == 3 {
+				boost *= 0.9
+			}
+			valence += boost
+		}
+
+		// "never so good" and "never this good" are emphasis, not negation
+		if start > 1 && lower[i-start] == "never" && (lower[i-start+1] == "so" || lower[i-start+1] == "this") {
+			valence *= 1.25
+		} else if isNegated(prev) {
+			valence *= negationScalar
+		}
+	}
+
+	// "least" negates unless it is "at least" or "very least"
+	if i > 0 && lower[i-1] == "least" && (i < 2 || (lower[i-2] != "at" && lower[i-2] != "very")) {
+		valence *= negationScalar
+	}
+
+	return valence
+}
+
+// scalarIncDec returns the change a booster word makes to a valence.
+func scalarIncDec(word string, lower string, valence float64, capDiff bool) float64 {
+	scalar, ok := boosters[lower]
+	if !ok {
+		return 0
+	}
+	if valence < 0 {
+		scalar = -scalar
+	}
+	if capDiff && isUpper(word) {
+		scalar += math.Copysign(capsIncrease, valence)
+	}
+
+	return scalar
+}
+
+// butCheck weakens valences before "but" and strengthens those after it.
+func butCheck(lower []string, valences []float64) {
+	for i, w := range lower {
+		if w != "but" {
+			continue
+		}
+		for j := range valences {
+			if j < i {
+				valences[j] *= butBefore
+			} else if j > i {
+				valences[j] *= butAfter
+			}
+		}
+		return
+	}
+}
+
+// isNegated returns true if the word negates the words after it.
+func isNegated(word string) bool {
+	return negations[word] || strings.HasSuffix(word, "n't") || strings.HasSuffix(word, "n’t")
+}
+
+// punctuationEmphasis returns how much exclamation points and question marks strengthen the text.
+func punctuationEmphasis(text string) float64 {
+	exclaims := strings.Count(text, "!")
+	if exclaims > maxExclaims {
+		exclaims = maxExclaims
+	}
+	emphasis := float64(exclaims) * exclaimBoost
+
+	questions := strings.Count(text, "?")
+	if questions > 1 {
+		if questions <= maxQuestions {
+			emphasis += float64(questions) * questionBoost
+		} else {
+			emphasis += maxQuestionSum
+		}
+	}
+
+	return emphasis
+}
+
+// normalize maps a sum of valences to -1 to 1.
+func normalize(sum float64) float64 {
+	n := sum / math.Sqrt(sum*sum+normalAlpha)
+	if n < -1 {
+		n = -1
+	} else if n > 1 {
+		n = 1
+	}
+
+	return round(n)
+}
+
+// shares returns the positive, negative, and neutral shares of the text's valences.
+func shares(valences []float64, emphasis float64) (float64, float64, float64) {
+	var pos, neg, neu float64
+	for _, v := range valences {
+		if v > 0 {
+			pos += v + 1 // one is added to count the word itself as well as its strength
+		} else if v < 0 {
+			neg += v - 1
+		} else {
+			neu++
+		}
+	}
+	if pos > math.Abs(neg) {
+		pos += emphasis
+	} else if pos < math.Abs(neg) {
+		neg -= emphasis
+	}
+
+	total := pos + math.Abs(neg) + neu
+	if total == 0 {
+		return 0, 0, 0
+	}
+
+	return round(pos / total), round(math.Abs(neg) / total), round(neu / total)
+}
+
+func round(f float64) float64 {
+	return math.Round(f*10000) / 10000
+}
+
+// tokenize splits text into words, removing surrounding punctuation except from emoticons,
+// and making each emoji its own word.
+func tokenize(text string) []string {
+	var b strings.Builder
+	for _, r := range text {
+		if r == '\uFE0F' || r == '\u200D' {
+			// emoji variation selectors and joiners
+			continue
+		} else if isEmoji(r) {
+			b.WriteRune(' ')
+			b.WriteRune(r)
+			b.WriteRune(' ')
+		} else {
+			b.WriteRune(r)
+		}
+	}
+
+	out := []string{}
+	for _, field := range strings.Fields(b.String()) {
+		if _, ok := lexicon[field]; ok {
+			// emoticons and emoji
+			out = append(out, field)
+			continue
+		}
+		word := strings.TrimFunc(field, func(r rune) bool {
+			return unicode.IsPunct(r) && r != '\''
+		})
+		word = strings.Trim(word, "'")
+		// one letter words carry no sentiment except I and a, which keep sentences the same length
+		if len(word) > 1 || word == "I" || word == "a" {
+			out = append(out, word)
+		}
+	}
+
+	return out
+}
+
+// isEmoji returns true for emoji and other pictographic symbols.
+func isEmoji(r rune) bool {
+	return r >= 0x1F000 || (r >= 0x2600 && r <= 0x27BF) || unicode.Is(unicode.So, r)
+}
+
+// isUpper returns true if the word has letters and they are all upper case.
+func isUpper(word string) bool {
+	hasLetter := false
+	for _, r := range word {
+		if unicode.IsLower(r) {
+			return false
+		}
+		if unicode.IsLetter(r) {
+			hasLetter = true
+		}
+	}
+
+	return hasLetter
+}
+
+// isCapDiff returns true if some but not all words are upper case, so upper case words are emphasis.
+func isCapDiff(words []string) bool {
+	upper := 0
+	for _, w := range words {
+		if isUpper(w) {
+			upper++
+		}
+	}
+
+	return upper > 0 && upper < len(words)
+}
+
+// splitSentences splits text into sentences at line breaks and at sentence ending punctuation
+// followed by a space.
+func splitSentences(text string) []string {
+	out := []string{}
+	for _, line := range strings.Split(text, "\n") {
+		start := 0
+		runes := []rune(line)
+		for i, r := range runes {
+			if (r == '.' || r == '!' || r == '?') && i+1 < len(runes) && unicode.IsSpace(runes[i+1]) {
+				if s := strings.TrimSpace(string(runes[start : i+1])); len(s) > 0 {
+					out = append(out, s)
+				}
+				start = i + 1
+			}
+		}
+		if s := strings.TrimSpace(string(runes[start:])); len(s) > 0 {
+			out = append(out, s)
+		}
+	}
+
+	return out
+}
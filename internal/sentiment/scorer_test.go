@@ -0,0 +1,64 @@
+package sentiment
+
+import (
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		text     string
+		compound float64
+	}{
+		// values from the reference VADER implementation
+		{"The food is good.", 0.4404},
+		{"The food is bad.", -0.5423},
+		{"The food is not good.", -0.3412},
+		{"The table is brown.", 0},
+		{"", 0},
+	}
+
+	for _, tt := range tests {
+		if got := Score(tt.text).Compound; got != tt.compound {
+			t.Errorf("Score(%q).Compound = %v, want %v", tt.text, got, tt.compound)
+		}
+	}
+}
+
+func TestScoreRules(t *testing.T) {
+	// each pair is ordered from less to more positive
+	tests := [][2]string{
+		{"The food is good.", "The food is very good."},
+		{"The food is good.", "The food is GOOD."},
+		{"The food is good.", "The food is good!!!"},
+		{"The food is very bad.", "The food is bad."},
+		{"The food is not good.", "The food is good."},
+		{"The food is good, but the service is terrible.", "The food is terrible, but the service is good."},
+		{"It is kind of bad.", "It is kind of good."},
+	}
+
+	for _, tt := range tests {
+		low, high := Score(tt[0]).Compound, Score(tt[1]).Compound
+		if low >= high {
+			t.Errorf("Score(%q) = %v, want less than Score(%q) = %v", tt[0], low, tt[1], high)
+		}
+	}
+
+	s := Score("The food is good and the service is bad.")
+	if s.Positive <= 0 || s.Negative <= 0 || s.Neutral <= 0 || s.Sentences != 1 {
+		t.Errorf("Score shares = %+v", s)
+	}
+	if sum := s.Positive + s.Negative + s.Neutral; sum < 0.99 || sum > 1.01 {
+		t.Errorf("Score shares sum to %v", sum)
+	}
+}
+
+func TestScoreDocument(t *testing.T) {
+	s := ScoreDocument("The food is good. The table is brown.", "")
+	if s.Sentences != 2 || s.Compound != round(0.4404/2) {
+		t.Errorf("ScoreDocument = %+v", s)
+	}
+
+	if s := ScoreDocument("", "   "); s != (Scores{}) {
+		t.Errorf("ScoreDocument of empty text = %+v", s)
+	}
+}
@@ -0,0 +1,131 @@
+package sentiment
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/guregu/null.v3"
+)
+
+// What a series is grouped by
+const (
+	SeriesHost      = "host"      // articles from a host
+	SeriesSubreddit = "subreddit" // submissions and comments in a subreddit
+	SeriesListing   = "listing"   // articles, submissions, and comments linked to a listing's symbol
+)
+
+// least confidence of a listing link or mention for content to count towards the listing's series
+const minListingConfidence = 0.5
+
+// Point is the average sentiment of content published on a day, in UTC.
+type Point struct {
+	Day       time.Time `db:"day"`
+	NumScored int64     `db:"num_scored"`
+	Compound  float64   `db:"compound"`
+	Positive  float64   `db:"positive"`
+	Negative  float64   `db:"negative"`
+}
+
+// series by what they are grouped by, each takes the host, subreddit, or symbol, from, and to.
+// Listing series also take the least listing confidence.
+// The inner select returns the publish time and scores of each piece of content in the group.
+var series = map[string]string{
+	SeriesHost: `SELECT date_trunc('day', t AT TIME ZONE 'UTC') AS day, count(*) AS num_scored,
+					avg(compound) AS compound, avg(positive) AS positive, avg(negative) AS negative
+				 FROM (
+					SELECT COALESCE(a.published_time, a.source_published_time, a.data_entry_time) AS t,
+						s.compound, s.positive, s.negative
+					FROM ArticleSentiment s
+					JOIN NewsArticle a ON a.article_id = s.article_id
+					WHERE a.host = $1
+				 ) scored
+				 WHERE ($2::timestamptz IS NULL OR t >= $2) AND ($3::timestamptz IS NULL OR t < $3)
+				 GROUP BY day
+				 ORDER BY day ASC`,
+
+	SeriesSubreddit: `SELECT date_trunc('day', t AT TIME ZONE 'UTC') AS day, count(*) AS num_scored,
+						avg(compound) AS compound, avg(positive) AS positive, avg(negative) AS negative
+					 FROM (
+						SELECT sub.created_time AS t, s.compound, s.positive, s.negative
+						FROM RedditSubmissionSentiment s
+						JOIN RedditSubmission sub ON sub.submission_id = s.submission_id
+						WHERE lower(sub.subreddit_name) = lower($1)
+						UNION ALL
+						SELECT c.created_time AS t, s.compound, s.positive, s.negative
+						FROM RedditCommentSentiment s
+						JOIN RedditComment c ON c.comment_id = s.comment_id
+						JOIN RedditSubmission sub ON sub.submission_id = c.submission_id
+						WHERE lower(sub.subreddit_name) = lower($1)
+					 ) scored
+					 WHERE ($2::timestamptz IS NULL OR t >= $2) AND ($3::timestamptz IS NULL OR t < $3)
+					 GROUP BY day
+					 ORDER BY day ASC`,
+
+	SeriesListing: `WITH listing AS (
+						SELECT listing_id FROM Listing WHERE upper(symbol) = upper($1)
+					)
+					SELECT date_trunc('day', t AT TIME ZONE 'UTC') AS day, count(*) AS num_scored,
+						avg(compound) AS compound, avg(positive) AS positive, avg(negative) AS negative
+					FROM (
+						SELECT COALESCE(a.published_time, a.source_published_time, a.data_entry_time) AS t,
+							s.compound, s.positive, s.negative
+						FROM ArticleListing al
+						JOIN ArticleSentiment s ON s.article_id = al.article_id
+						JOIN NewsArticle a ON a.article_id = al.article_id
+						WHERE al.listing_id IN (SELECT listing_id FROM listing) AND al.confidence >= $4
+						UNION ALL
+						SELECT m.created_time AS t, s.compound, s.positive, s.negative
+						FROM RedditListingMention m
+						JOIN RedditSubmissionSentiment s ON s.submission_id = m.submission_id
+						WHERE m.comment_id IS NULL
+							AND m.listing_id IN (SELECT listing_id FROM listing) AND m.confidence >= $4
+						UNION ALL
+						SELECT m.created_time AS t, s.compound, s.positive, s.negative
+						FROM RedditListingMention m
+						JOIN RedditCommentSentiment s ON s.comment_id = m.comment_id
+						WHERE m.listing_id IN (SELECT listing_id FROM listing) AND m.confidence >= $4
+					) scored
+					WHERE ($2::timestamptz IS NULL OR t >= $2) AND ($3::timestamptz IS NULL OR t < $3)
+					GROUP BY day
+					ORDER BY day ASC`,
+}
+
+// Series returns the daily average sentiment of the content grouped by a host, subreddit, or listing symbol,
+// published in the optional range [from, to). Days without scored content are left out.
+func Series(ctx context.Context, db *sqlx.DB, by string, value string, from time.Time, to time.Time) ([]*Point, error) {
+	stmt, ok := series[by]
+	if !ok {
+		return nil, fmt.Errorf("sentiment: unknown series %s", by)
+	}
+
+	args := []interface{}{value, null.NewTime(from, !from.IsZero()), null.NewTime(to, !to.IsZero())}
+	if by == SeriesListing {
+		args = append(args, minListingConfidence)
+	}
+
+	out := []*Point{}
+	err := db.SelectContext(ctx, &out, stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("sentiment series %s: %w", by, err)
+	}
+
+	return out, nil
+}
+
+// ParseSeries splits a series given as kind:value, such as subreddit:investing or listing:AAPL.
+func ParseSeries(s string) (string, string, error) {
+	i := strings.Index(s, ":")
+	if i < 1 || i == len(s)-1 {
+		return "", "", fmt.Errorf("sentiment: series %s is not kind:value", s)
+	}
+
+	by := strings.ToLower(strings.TrimSpace(s[:i]))
+	if _, ok := series[by]; !ok {
+		return "", "", fmt.Errorf("sentiment: unknown series %s", by)
+	}
+
+	return by, strings.TrimSpace(s[i+1:]), nil
+}
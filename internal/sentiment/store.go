@@ -0,0 +1,343 @@
+package sentiment
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/guregu/null.v3"
+
+	"github.com/wpwilson10/caterpillar/internal/news"
+)
+
+// Kinds of content that are scored
+const (
+	KindArticle    = "article"
+	KindSubmission = "submission"
+	KindComment    = "comment"
+)
+
+// Kinds lists every kind of content that is scored, in scoring order
+var Kinds = []string{KindArticle, KindSubmission, KindComment}
+
+// lag is how old content's entry time must be before the incremental watermark passes it,
+// so content from transactions still in progress is not skipped
+const lag = 5 * time.Minute
+
+// Row stores the scores of a piece of content in a format matching the ArticleSentiment,
+// RedditSubmissionSentiment, and RedditCommentSentiment table schemas, where ID is the content's key.
+type Row struct {
+	ID           int64     `db:"id"`
+	Compound     float64   `db:"compound"`
+	Positive     float64   `db:"positive"`
+	Negative     float64   `db:"negative"`
+	Neutral      float64   `db:"neutral"`
+	NumSentences int       `db:"num_sentences"`
+	Version      int       `db:"version"`
+	DataTime     time.Time `db:"data_entry_time"`
+}
+
+// target is where a kind of content is read from and its scores are saved
+type target struct {
+	content string // content table
+	table   string // scores table
+	key     string // key column in the content and scores tables
+	// selects the id, title, and body of content after $3 through $5 entered in the optional range [$1, $2),
+	// in key order and limited to $4 rows
+	selectStmt string
+}
+
+var targets = map[string]*target{
+	// only english articles, or articles whose language is unknown, can be scored with the lexicon
+	KindArticle: {
+		content: "NewsArticle",
+		table:   "ArticleSentiment",
+		key:     "article_id",
+		selectStmt: `SELECT article_id AS id, COALESCE(title, source_title, '') AS title, COALESCE(body, '') AS body
+					 FROM NewsArticle
+					 WHERE (language IS NULL OR language = 'en')
+						AND ($1::timestamptz IS NULL OR data_entry_time >= $1)
+						AND ($2::timestamptz IS NULL OR data_entry_time < $2)
+						AND article_id > $3 AND article_id <= $5
+					 ORDER BY article_id ASC
+					 LIMIT $4`,
+	},
+	KindSubmission: {
+		content: "RedditSubmission",
+		table:   "RedditSubmissionSentiment",
+		key:     "submission_id",
+		selectStmt: `SELECT submission_id AS id, COALESCE(title, '') AS title, COALESCE(selftext, '') AS body
+					 FROM RedditSubmission
+					 WHERE ($1::timestamptz IS NULL OR data_entry_time >= $1)
+						AND ($2::timestamptz IS NULL OR data_entry_time < $2)
+						AND submission_id > $3 AND submission_id <= $5
+					 ORDER BY submission_id ASC
+					 LIMIT $4`,
+	},
+	KindComment: {
+		content: "RedditComment",
+		table:   "RedditCommentSentiment",
+		key:     "comment_id",
+		selectStmt: `SELECT comment_id AS id, '' AS title, COALESCE(body, '') AS body
+					 FROM RedditComment
+					 WHERE NOT COALESCE(is_deleted, false)
+						AND ($1::timestamptz IS NULL OR data_entry_time >= $1)
+						AND ($2::timestamptz IS NULL OR data_entry_time < $2)
+						AND comment_id > $3 AND comment_id <= $5
+					 ORDER BY comment_id ASC
+					 LIMIT $4`,
+	},
+}
+
+// content is text read for scoring
+type content struct {
+	ID    int64  `db:"id"`
+	Title string `db:"title"`
+	Body  string `db:"body"`
+}
+
+// removed reddit text, which has no sentiment
+var removedText = map[string]bool{"[deleted]": true, "[removed]": true}
+
+// ParseKinds splits a comma separated list of kinds, where news means articles and reddit means
+// submissions and comments. Returns every kind for an empty list or all.
+func ParseKinds(s string) ([]string, error) {
+	out := []string{}
+	for _, kind := range strings.Split(s, ",") {
+		switch kind = strings.ToLower(strings.TrimSpace(kind)); kind {
+		case "":
+		case "all":
+			return Kinds, nil
+		case "news":
+			out = append(out, KindArticle)
+		case "reddit":
+			out = append(out, KindSubmission, KindComment)
+		case KindArticle, KindSubmission, KindComment:
+			out = append(out, kind)
+		default:
+			return nil, fmt.Errorf("sentiment: unknown kind %s", kind)
+		}
+	}
+	if len(out) == 0 {
+		return Kinds, nil
+	}
+
+	return out, nil
+}
+
+// ScoreBatch scores up to batchSize pieces of the kind of content after the given ID, entered in
+// the optional range [from, to), and saves their scores. Returns the last ID read, or zero if none were left,
+// the number of pieces read, and the number of pieces with text that were saved.
+func ScoreBatch(ctx context.Context, db *sqlx.DB, kind string, afterID int64, from null.Time, to null.Time, batchSize int) (int64, int, int, error) {
+	t, ok := targets[kind]
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("sentiment: unknown kind %s", kind)
+	}
+
+	return scoreBatch(ctx, db, t, afterID, math.MaxInt64, from, to, batchSize)
+}
+
+// scoreBatch is ScoreBatch for content with keys after afterID through throughID.
+func scoreBatch(ctx context.Context, db *sqlx.DB, t *target, afterID int64, throughID int64, from null.Time, to null.Time, batchSize int) (int64, int, int, error) {
+	batch := []*content{}
+	err := db.SelectContext(ctx, &batch, t.selectStmt, from, to, afterID, batchSize, throughID)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if len(batch) == 0 {
+		return 0, 0, 0, nil
+	}
+
+	now := time.Now()
+	rows := []*Row{}
+	for _, c := range batch {
+		if removedText[strings.TrimSpace(c.Body)] {
+			c.Body = ""
+		}
+		scores := ScoreDocument(c.Title, c.Body)
+		if scores.Sentences == 0 {
+			continue
+		}
+		rows = append(rows, &Row{
+			ID:           c.ID,
+			Compound:     scores.Compound,
+			Positive:     scores.Positive,
+			Negative:     scores.Negative,
+			Neutral:      scores.Neutral,
+			NumSentences: scores.Sentences,
+			Version:      Version,
+			DataTime:     now,
+		})
+	}
+
+	return batch[len(batch)-1].ID, len(batch), len(rows), save(ctx, db, t, rows)
+}
+
+// save inserts or replaces the scores in the target's table.
+func save(ctx context.Context, db *sqlx.DB, t *target, rows []*Row) error {
+	var insertStmt string = `INSERT INTO ` + t.table + ` (
+								` + t.key + `,
+								compound,
+								positive,
+								negative,
+								neutral,
+								num_sentences,
+								version,
+								data_entry_time
+								)
+							 VALUES (
+								:id,
+								:compound,
+								:positive,
+								:negative,
+								:neutral,
+								:num_sentences,
+								:version,
+								:data_entry_time
+								)
+							 ON CONFLICT (` + t.key + `) DO UPDATE SET
+								compound = EXCLUDED.compound,
+								positive = EXCLUDED.positive,
+								negative = EXCLUDED.negative,
+								neutral = EXCLUDED.neutral,
+								num_sentences = EXCLUDED.num_sentences,
+								version = EXCLUDED.version,
+								data_entry_time = EXCLUDED.data_entry_time`
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, row := range rows {
+		_, err = tx.NamedExecContext(ctx, insertStmt, row)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Backfill scores the kind of content entered in the backfill's date range, checkpointing after each batch.
+// Content that was already scored is scored again, so changed scoring can be rerun.
+// The backfill's article count is the number of pieces with text that were scored.
+func Backfill(ctx context.Context, db *sqlx.DB, kind string, b *news.Backfill, batchSize int) error {
+	from, to := null.TimeFrom(b.RangeStart), null.TimeFrom(b.RangeEnd)
+
+	return news.RunBackfill(ctx, db, b, batchSize, func(lastID int64, batchSize int) (int64, int64, int64, error) {
+		lastID, numRead, numScored, err := ScoreBatch(ctx, db, kind, lastID, from, to, batchSize)
+		return lastID, int64(numRead), int64(numScored), err
+	})
+}
+
+// Watermark stores how far a kind of content has been scored incrementally,
+// in a format matching the SentimentWatermark table schema.
+type Watermark struct {
+	Kind        string    `db:"kind"`
+	LastID      int64     `db:"last_id"` // key of the last content read, scored or not
+	NumScored   int64     `db:"num_scored"`
+	NumRuns     int64     `db:"num_runs"`
+	UpdatedTime time.Time `db:"updated_time"`
+}
+
+// LoadWatermark returns the saved watermark for the kind of content. A new watermark starts
+// after the newest scored content, so content scored before watermarks were saved is not scored again.
+func LoadWatermark(ctx context.Context, db *sqlx.DB, kind string) (*Watermark, error) {
+	t, ok := targets[kind]
+	if !ok {
+		return nil, fmt.Errorf("sentiment: unknown kind %s", kind)
+	}
+
+	out := Watermark{}
+	err := db.GetContext(ctx, &out, "SELECT * FROM SentimentWatermark WHERE kind = $1", kind)
+	if err == sql.ErrNoRows {
+		out = Watermark{Kind: kind}
+		err = db.GetContext(ctx, &out.LastID, `SELECT COALESCE(max(`+t.key+`), 0) FROM `+t.table)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// Save inserts or updates this watermark in the SentimentWatermark table.
+func (w *Watermark) Save(ctx context.Context, db *sqlx.DB) error {
+	w.UpdatedTime = time.Now()
+
+	var insertStmt string = `INSERT INTO SentimentWatermark (
+								kind,
+								last_id,
+								num_scored,
+								num_runs,
+								updated_time
+								)`
+
+	var valueStmt string = `VALUES (
+								:kind,
+								:last_id,
+								:num_scored,
+								:num_runs,
+								:updated_time
+								)`
+
+	var conflictStmt string = `ON CONFLICT (kind) DO UPDATE SET
+								last_id = EXCLUDED.last_id,
+								num_scored = EXCLUDED.num_scored,
+								num_runs = EXCLUDED.num_runs,
+								updated_time = EXCLUDED.updated_time`
+
+	_, err := db.NamedExecContext(ctx, insertStmt+" "+valueStmt+" "+conflictStmt, w)
+
+	return err
+}
+
+// Incremental scores the kind of content added after its saved watermark, saving the watermark after each batch.
+// Only content entered more than lag ago is read, so content committed out of key order is not passed over.
+// Returns the number of pieces with text that were scored.
+func Incremental(ctx context.Context, db *sqlx.DB, kind string, batchSize int) (int64, error) {
+	mark, err := LoadWatermark(ctx, db, kind)
+	if err != nil {
+		return 0, err
+	}
+	t := targets[kind]
+
+	// newest content old enough that transactions before it have finished
+	var bound null.Int
+	err = db.GetContext(ctx, &bound,
+		fmt.Sprintf(`SELECT max(%[1]s) FROM (SELECT %[1]s FROM %[2]s WHERE data_entry_time < $1 ORDER BY %[1]s DESC LIMIT 1) newest`,
+			t.key, t.content),
+		time.Now().Add(-lag))
+	if err != nil {
+		return 0, err
+	}
+
+	mark.NumRuns++
+	var total int64
+	for bound.Valid && mark.LastID < bound.Int64 {
+		id, _, numScored, err := scoreBatch(ctx, db, t, mark.LastID, bound.Int64, null.Time{}, null.Time{}, batchSize)
+		if err != nil {
+			return total, err
+		}
+		// nothing left through the bound
+		if id == 0 {
+			break
+		}
+		mark.LastID = id
+		mark.NumScored = mark.NumScored + int64(numScored)
+		total = total + int64(numScored)
+
+		err = mark.Save(ctx, db)
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, mark.Save(ctx, db)
+}
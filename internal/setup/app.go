@@ -26,9 +26,14 @@ func RunTime() time.Duration {
 
 // CheckPythonServer blocks the function call until the python server is up.
 func CheckPythonServer() {
+	port, err := EnvToInt("PY_CATERPILLAR_PORT")
+	if err != nil {
+		LogCommon(err).Fatal("Failed env int conversion")
+	}
+
 	// check if python server is running
 	var count int = 1
-	for !CheckOnce(EnvToInt("PY_CATERPILLAR_PORT")) {
+	for !CheckOnce(port) {
 		LogCommon(nil).
 			WithField("count", count).
 			Warn("Caterpillar python server not running")
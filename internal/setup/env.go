@@ -1,6 +1,8 @@
 package setup
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -8,6 +10,9 @@ import (
 	"github.com/joho/godotenv"
 )
 
+// ErrEnvNotSet is returned when a required .env variable is missing or empty.
+var ErrEnvNotSet = errors.New("environment variable not set")
+
 // EnvironmentConfig loads the .env file for the whole program.
 // Use os.Getenv("LABEL_NAME") to access.
 func EnvironmentConfig() {
@@ -25,14 +30,33 @@ func EnvironmentConfig() {
 }
 
 // EnvToInt converts the given .env variable into an integer.
-func EnvToInt(s string) int {
-	// get apps port
-	port, err := strconv.Atoi(os.Getenv(s))
+// Returns ErrEnvNotSet if the variable does not exist.
+func EnvToInt(s string) (int, error) {
+	value := os.Getenv(s)
+	if len(value) == 0 {
+		return 0, fmt.Errorf("%s: %w", s, ErrEnvNotSet)
+	}
+
+	out, err := strconv.Atoi(value)
 	if err != nil {
+		return 0, fmt.Errorf("%s: %w", s, err)
+	}
+
+	return out, nil
+}
+
+// EnvToIntOr converts the given .env variable into an integer,
+// returning fallback if the variable is missing or not an integer.
+func EnvToIntOr(s string, fallback int) int {
+	out, err := EnvToInt(s)
+	if errors.Is(err, ErrEnvNotSet) {
+		return fallback
+	} else if err != nil {
 		LogCommon(err).
-			WithField("port", port).
-			Fatal("Failed env int conversion")
+			WithField("fallback", fallback).
+			Warn("Failed env int conversion")
+		return fallback
 	}
 
-	return port
+	return out
 }
@@ -1,6 +1,8 @@
 package setup
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"strconv"
 
@@ -8,11 +10,11 @@ import (
 )
 
 // Redis sets up a connection to a Redis server specified by the .env config.
-func Redis() *redis.Client {
-
+// Pings the server so an unreachable server is reported here instead of on first use.
+func Redis(ctx context.Context) (*redis.Client, error) {
 	db, err := strconv.Atoi(os.Getenv("REDIS_DATABASE"))
 	if err != nil {
-		LogCommon(err).Fatal("REDIS_DATABASE type conversion")
+		return nil, fmt.Errorf("REDIS_DATABASE type conversion: %w", err)
 	}
 
 	client := redis.NewClient(&redis.Options{
@@ -21,5 +23,12 @@ func Redis() *redis.Client {
 		DB:       db,
 	})
 
-	return client
+	// check that the server is reachable
+	err = client.WithContext(ctx).Ping().Err()
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return client, nil
 }
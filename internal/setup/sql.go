@@ -1,6 +1,7 @@
 package setup
 
 import (
+	"context"
 	"os"
 
 	"github.com/jmoiron/sqlx"
@@ -9,7 +10,7 @@ import (
 )
 
 // SQL initalizes a database connection based off .env file connection parameters
-func SQL() *sqlx.DB {
+func SQL(ctx context.Context) (*sqlx.DB, error) {
 	var host string = "host=" + os.Getenv("SQL_HOST")
 	var port string = "port=" + os.Getenv("SQL_PORT")
 	var user string = "user=" + os.Getenv("SQL_USER")
@@ -17,12 +18,7 @@ func SQL() *sqlx.DB {
 	var dbname string = "dbname=" + os.Getenv("SQL_DB")
 	var connectionString = host + " " + port + " " + user + " " + password + " " + dbname + " " + "sslmode=disable"
 
-	// this Pings the database trying to connect, panics on error
+	// this Pings the database trying to connect
 	// use sqlx.Open() for sql.Open() semantics
-	db, err := sqlx.Connect("postgres", connectionString)
-	if err != nil {
-		LogCommon(err).Fatal("Connecting to SQL database")
-	}
-
-	return db
+	return sqlx.ConnectContext(ctx, "postgres", connectionString)
 }
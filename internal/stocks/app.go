@@ -1,6 +1,7 @@
 package stocks
 
 import (
+	"context"
 	"time"
 
 	"github.com/wpwilson10/caterpillar/internal/setup"
@@ -10,7 +11,10 @@ import (
 func App() {
 	// Setup necessary clients
 	client := IEXSetup()
-	db := setup.SQL()
+	db, err := setup.SQL(context.Background())
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to SQL database")
+	}
 
 	// get active listings and latest intraday times from database
 	// use russell3000 index to reduce number of calls
@@ -39,7 +43,10 @@ func App() {
 // UpdateActiveDriver update the active status for listings in the IEX listing table.
 func UpdateActiveDriver() {
 	// Setup necessary clients
-	db := setup.SQL()
+	db, err := setup.SQL(context.Background())
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to SQL database")
+	}
 
 	// get all listings from database
 	dbListings := AllListings(db)
@@ -56,7 +63,10 @@ func UpdateActiveDriver() {
 func UpdateListingsDriver() {
 	// Setup necessary clients
 	client := IEXSetup()
-	db := setup.SQL()
+	db, err := setup.SQL(context.Background())
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to SQL database")
+	}
 
 	// get all listings from database
 	dbListings := AllListings(db)
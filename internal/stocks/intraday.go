@@ -11,18 +11,18 @@ import (
 
 // Intraday stores a single timepoint of data in a format matching the intraday table schema
 type Intraday struct {
-	IntradayID int64           `db:"intraday_id"`
-	ListingID  int64           `db:"listing_id"`
-	SourceID   int64           `db:"source_id"`
-	DataTime   time.Time       `db:"data_time"`
-	UpdateTime time.Time       `db:"update_time"`
-	Open       decimal.Decimal `db:"open"`
-	Close      decimal.Decimal `db:"close"`
-	High       decimal.Decimal `db:"high"`
-	Low        decimal.Decimal `db:"low"`
-	Volume     decimal.Decimal `db:"volume"`
-	Notional   decimal.Decimal `db:"notional"`
-	NumTrades  decimal.Decimal `db:"num_trades"`
+	IntradayID int64           `db:"intraday_id" json:"intraday_id"`
+	ListingID  int64           `db:"listing_id" json:"listing_id"`
+	SourceID   int64           `db:"source_id" json:"source_id"`
+	DataTime   time.Time       `db:"data_time" json:"data_time"`
+	UpdateTime time.Time       `db:"update_time" json:"update_time"`
+	Open       decimal.Decimal `db:"open" json:"open"`
+	Close      decimal.Decimal `db:"close" json:"close"`
+	High       decimal.Decimal `db:"high" json:"high"`
+	Low        decimal.Decimal `db:"low" json:"low"`
+	Volume     decimal.Decimal `db:"volume" json:"volume"`
+	Notional   decimal.Decimal `db:"notional" json:"notional"`
+	NumTrades  decimal.Decimal `db:"num_trades" json:"num_trades"`
 }
 
 // InsertIntraday inserts all values for all given Intraday structs into the intraday database table.
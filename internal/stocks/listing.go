@@ -11,19 +11,19 @@ import (
 // Listing contains information about each stock listing.
 // Most of the data comes from IEX
 type Listing struct {
-	ListingID     int64     `db:"listing_id"`
-	UpdateTime    time.Time `db:"update_time"`
-	IsEnabled     bool      `db:"is_enabled"`
-	Symbol        string    `db:"symbol"`
-	Name          string    `db:"name"`
-	IexID         string    `db:"iex_id"`
-	Type          string    `db:"type"`
-	Region        string    `db:"region"`
-	Currency      string    `db:"currency"`
-	Exchange      string    `db:"exchange"`
-	IsSP500       bool      `db:"is_sp500"`
-	IsRussell3000 bool      `db:"is_russell3000"`
-	IsActive      bool      `db:"is_active"`
+	ListingID     int64     `db:"listing_id" json:"listing_id"`
+	UpdateTime    time.Time `db:"update_time" json:"update_time"`
+	IsEnabled     bool      `db:"is_enabled" json:"is_enabled"`
+	Symbol        string    `db:"symbol" json:"symbol"`
+	Name          string    `db:"name" json:"name"`
+	IexID         string    `db:"iex_id" json:"iex_id"`
+	Type          string    `db:"type" json:"type"`
+	Region        string    `db:"region" json:"region"`
+	Currency      string    `db:"currency" json:"currency"`
+	Exchange      string    `db:"exchange" json:"exchange"`
+	IsSP500       bool      `db:"is_sp500" json:"is_sp500"`
+	IsRussell3000 bool      `db:"is_russell3000" json:"is_russell3000"`
+	IsActive      bool      `db:"is_active" json:"is_active"`
 }
 
 // InsertNewListings inserts all information from IEX as a new entry in the Listing table.
@@ -34,13 +34,13 @@ func byPublishedTime(db *sqlx.DB, target *news.Article) []news.Article {
 		return nil
 	}
 
-	var selectStmtBefore string = `SELECT * FROM NewsArticle 
+	var selectStmtBefore string = `SELECT ` + news.ArticleColumns("") + ` FROM NewsArticle 
 									WHERE article_id !=$1 AND host=$2
 										AND published_time <= $3 
 									ORDER BY published_time DESC
 									LIMIT 10`
 
-	var selectStmtAfter string = `SELECT * FROM NewsArticle 
+	var selectStmtAfter string = `SELECT ` + news.ArticleColumns("") + ` FROM NewsArticle 
 									WHERE article_id !=$1 AND host=$2
 										AND published_time >= $3 
 									ORDER BY published_time ASC
@@ -60,13 +60,13 @@ func bySourcePublishedTime(db *sqlx.DB, target *news.Article) []news.Article {
 		return nil
 	}
 
-	var selectStmtBefore string = `SELECT * FROM NewsArticle 
+	var selectStmtBefore string = `SELECT ` + news.ArticleColumns("") + ` FROM NewsArticle 
 									WHERE article_id !=$1 AND host=$2
 										AND source_published_time <= $3 
 									ORDER BY source_published_time DESC
 									LIMIT 10`
 
-	var selectStmtAfter string = `SELECT * FROM NewsArticle 
+	var selectStmtAfter string = `SELECT ` + news.ArticleColumns("") + ` FROM NewsArticle 
 									WHERE article_id !=$1 AND host=$2
 										AND source_published_time >= $3 
 									ORDER BY source_published_time ASC
@@ -81,13 +81,13 @@ func bySourcePublishedTime(db *sqlx.DB, target *news.Article) []news.Article {
 // Returns the articles with a data_entry_time immediately before and after the given article.
 // Should always return values since data_entry_time is a default field.
 func byDataEntryTime(db *sqlx.DB, target *news.Article) []news.Article {
-	var selectStmtBefore string = `SELECT * FROM NewsArticle 
+	var selectStmtBefore string = `SELECT ` + news.ArticleColumns("") + ` FROM NewsArticle 
 									WHERE article_id !=$1 AND host=$2
 										AND data_entry_time <= $3 
 									ORDER BY data_entry_time DESC
 									LIMIT 10`
 
-	var selectStmtAfter string = `SELECT * FROM NewsArticle 
+	var selectStmtAfter string = `SELECT ` + news.ArticleColumns("") + ` FROM NewsArticle 
 									WHERE article_id !=$1 AND host=$2
 										AND data_entry_time >= $3 
 									ORDER BY data_entry_time ASC
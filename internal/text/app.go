@@ -1,6 +1,7 @@
 package text
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -11,11 +12,14 @@ import (
 
 func App() {
 	// connect to database
-	db := setup.SQL()
+	db, err := setup.SQL(context.Background())
+	if err != nil {
+		setup.LogCommon(err).Fatal("Connecting to SQL database")
+	}
 
 	// test article
 	target := news.Article{}
-	err := db.Get(&target, "SELECT * FROM NewsArticle WHERE article_id=$1", 2033)
+	err = db.Get(&target, "SELECT "+news.ArticleColumns("")+" FROM NewsArticle WHERE article_id=$1", 2033)
 
 	if err != nil {
 		setup.LogCommon(err).Error("Get one article")
@@ -46,12 +50,19 @@ func CleanArticle(db *sqlx.DB, target *news.Article) *string {
 	// Clean up string
 	text := NormalizeString(&body)
 	// Divide into sentences
-	targetSentences := Sentences(text)
+	targetSentences := Sentences(text, target.Language.String)
+
+	// minimum number of articles and sentences to compare against
+	cutoff, err := setup.EnvToInt("TEXT_ARTICLE_CUTOFF")
+	if err != nil {
+		setup.LogCommon(err).Error("Failed env int conversion")
+		return nil
+	}
 
 	// Get articles published around the same time as the target article
 	articles := AdjacentArticles(db, target)
 	// Only continue if we have a good number of articles to reference
-	if len(articles) < setup.EnvToInt("TEXT_ARTICLE_CUTOFF") {
+	if len(articles) < cutoff {
 		return nil
 	}
 
@@ -65,14 +76,14 @@ func CleanArticle(db *sqlx.DB, target *news.Article) *string {
 			// Clean up string
 			text := NormalizeString(&body)
 			// Divide into sentences
-			newSentences := Sentences(text)
+			newSentences := Sentences(text, each.Language.String)
 			// save for later
 			checkSentences = append(checkSentences, newSentences...)
 		}
 	}
 
 	// Sanity check we got a reasonable number of sentences
-	if len(checkSentences) < setup.EnvToInt("TEXT_ARTICLE_CUTOFF") {
+	if len(checkSentences) < cutoff {
 		return nil
 	}
 
@@ -13,8 +13,9 @@ import (
 )
 
 // Sentences parses the given text into individual sentences and returns all non-empty strings.
+// Language is the text's ISO 639-1 code, used to pick a sentence segmenter. Empty uses English.
 // External call so it can be slow.
-func Sentences(text *string) []string {
+func Sentences(text *string, language string) []string {
 	// address to call for the text application
 	var host string = os.Getenv("PY_CATERPILLAR_HOST")
 	// connect to server
@@ -31,7 +32,7 @@ func Sentences(text *string) []string {
 
 	// Make request
 	response, err := client.Sentences(context.Background(),
-		&protobuf.TextRequest{Text: *text})
+		&protobuf.TextRequest{Text: *text, Language: language})
 
 	// handle possible failure codes
 	if err != nil {
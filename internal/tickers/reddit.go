@@ -0,0 +1,169 @@
+package tickers
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/wpwilson10/caterpillar/internal/stocks"
+)
+
+// MatchTicker is a bare upper case ticker such as AAPL
+const MatchTicker = "ticker"
+
+// Confidence that a bare ticker refers to the listing
+const (
+	tickerConfidence         = 0.5
+	shoutingTickerConfidence = 0.2 // in text that is mostly upper case
+)
+
+// shortest bare ticker that is matched, one letter tickers are only found as cashtags
+const minTickerLength = 2
+
+// text with more than this share of upper case words is shouting, where upper case words are rarely tickers
+const maxUpperShare = 0.5
+
+// bare upper case tickers
+var tickerRe = regexp.MustCompile(`\b[A-Z]{1,5}\b`)
+
+// words of two or more letters for finding shouting text
+var wordRe = regexp.MustCompile(`\b[A-Za-z]{2,}\b`)
+
+// upper case words common on investing subreddits that are also tickers
+var commonWords = map[string]bool{
+	"I": true, "A": true, "AM": true, "AN": true, "AS": true, "AT": true, "BE": true, "BY": true, "DO": true,
+	"GO": true, "HE": true, "IF": true, "IN": true, "IS": true, "IT": true, "ME": true, "MY": true, "NO": true,
+	"OF": true, "OK": true, "ON": true, "OR": true, "SO": true, "TO": true, "UP": true, "US": true, "WE": true,
+	"ALL": true, "AND": true, "ANY": true, "ARE": true, "BIG": true, "BUY": true, "CAN": true, "FOR": true,
+	"GET": true, "HAS": true, "NEW": true, "NOT": true, "NOW": true, "ONE": true, "OUT": true, "OWN": true,
+	"RUN": true, "SEE": true, "THE": true, "TWO": true, "WAY": true, "WHO": true, "YOU": true, "BEST": true,
+	"CASH": true, "EVER": true, "FAST": true, "GOOD": true, "HOLD": true, "JUST": true, "LOVE": true,
+	"MOON": true, "MOVE": true, "NEXT": true, "OPEN": true, "PLAY": true, "REAL": true, "SELL": true,
+	"TELL": true, "TRUE": true, "VERY": true, "WELL": true, "EDIT": true, "HODL": true, "YOLO": true,
+	"FOMO": true, "TLDR": true, "DD": true, "TA": true, "PT": true, "EOD": true, "EOW": true, "ATH": true,
+	"ATL": true, "IMO": true, "IMHO": true, "LOL": true, "WSB": true, "OTM": true, "ITM": true, "ATM": true,
+	"IV": true, "PM": true, "AH": true, "RH": true, "ER": true, "EPS": true, "PE": true, "CEO": true,
+	"CFO": true, "CTO": true, "COO": true, "IPO": true, "ETF": true, "SEC": true, "FDA": true, "FED": true,
+	"GDP": true, "CPI": true, "USA": true, "UK": true, "EU": true, "USD": true, "EUR": true, "API": true,
+	"AI": true, "EV": true, "IRA": true, "ROTH": true, "TV": true, "PR": true, "CEOS": true, "OP": true,
+	"DM": true, "FYI": true, "FAQ": true, "WTF": true, "OMG": true, "RIP": true, "LMAO": true, "TBH": true,
+}
+
+// SymbolFinder finds ticker mentions in reddit text, which uses cashtags and bare upper case tickers
+// more than company names.
+type SymbolFinder struct {
+	symbols map[string]int64 // upper case symbol to active listing
+}
+
+// NewSymbolFinder returns a finder for the listings, which should be the active listings.
+// When listings share a symbol, the older listing is used.
+func NewSymbolFinder(listings []stocks.Listing) *SymbolFinder {
+	f := &SymbolFinder{symbols: map[string]int64{}}
+	for _, listing := range listings {
+		symbol := strings.ToUpper(strings.TrimSpace(listing.Symbol))
+		if id, ok := f.symbols[symbol]; len(symbol) > 0 && (!ok || listing.ListingID < id) {
+			f.symbols[symbol] = listing.ListingID
+		}
+	}
+
+	return f
+}
+
+// Find returns each mention of an active listing in the text.
+func (f *SymbolFinder) Find(text string) []Mention {
+	out := []Mention{}
+
+	for _, m := range cashtagRe.FindAllStringSubmatch(text, -1) {
+		symbol := m[1]
+
+		confidence := cashtagConfidence
+		if symbol != strings.ToUpper(symbol) {
+			confidence = lowerCashtagConfidence
+		}
+		if id, ok := f.symbols[strings.ToUpper(symbol)]; ok {
+			out = append(out, Mention{ListingID: id, Kind: MatchCashtag, Confidence: confidence})
+		}
+	}
+
+	exchanges := map[int]bool{}
+	for _, m := range exchangeRe.FindAllStringSubmatchIndex(text, -1) {
+		exchanges[m[2]] = true
+		if id, ok := f.symbols[text[m[2]:m[3]]]; ok {
+			out = append(out, Mention{ListingID: id, Kind: MatchExchange, Confidence: exchangeConfidence})
+		}
+	}
+
+	confidence := tickerConfidence
+	if isShouting(text) {
+		confidence = shoutingTickerConfidence
+	}
+	for _, m := range tickerRe.FindAllStringIndex(text, -1) {
+		symbol := text[m[0]:m[1]]
+		if exchanges[m[0]] || len(symbol) < minTickerLength || commonWords[symbol] {
+			continue
+		}
+		// already found as a cashtag, or part of a longer symbol like BRK.B
+		if m[0] > 0 && (text[m[0]-1] == '$' || text[m[0]-1] == '.') {
+			continue
+		}
+		if id, ok := f.symbols[symbol]; ok {
+			out = append(out, Mention{ListingID: id, Kind: MatchTicker, Confidence: confidence})
+		}
+	}
+
+	return out
+}
+
+// Link returns the listings mentioned in any of the texts, most confident first.
+func (f *SymbolFinder) Link(texts ...string) []*Link {
+	mentions := []Mention{}
+	for _, text := range texts {
+		mentions = append(mentions, f.Find(text)...)
+	}
+
+	return Aggregate(mentions)
+}
+
+// isShouting returns true if most words in the text are upper case.
+func isShouting(text string) bool {
+	words := wordRe.FindAllString(text, -1)
+	if len(words) < 4 {
+		return false
+	}
+
+	upper := 0
+	for _, w := range words {
+		if w == strings.ToUpper(w) {
+			upper++
+		}
+	}
+
+	return float64(upper)/float64(len(words)) > maxUpperShare
+}
+
+// LoadSymbolFinder returns a finder for the active listings in the database.
+func LoadSymbolFinder(ctx context.Context, db *sqlx.DB) (*SymbolFinder, error) {
+	listings := []stocks.Listing{}
+	err := db.SelectContext(ctx, &listings, "SELECT * FROM Listing WHERE is_active = true ORDER BY listing_id ASC")
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSymbolFinder(listings), nil
+}
+
+// shared finder used by apps that find mentions as they collect
+var sharedFinder = &cache{load: func(ctx context.Context, db *sqlx.DB) (interface{}, error) {
+	return LoadSymbolFinder(ctx, db)
+}}
+
+// SharedSymbolFinder returns a finder for the active listings, loading it at most once per refresh interval.
+// If reloading fails, the previous finder is returned with the error.
+func SharedSymbolFinder(ctx context.Context, db *sqlx.DB) (*SymbolFinder, error) {
+	value, err := sharedFinder.get(ctx, db)
+	finder, _ := value.(*SymbolFinder)
+
+	return finder, err
+}
@@ -0,0 +1,56 @@
+package tickers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSymbolFinderFind(t *testing.T) {
+	f := NewSymbolFinder(testListings)
+
+	tests := []struct {
+		text string
+		want []string // listing:kind:confidence
+	}{
+		{"Bought more $AAPL today", []string{"1:cashtag:0.9"}},
+		{"Bought more $aapl today", []string{"1:cashtag:0.6"}},
+		{"AAPL to the moon", []string{"1:ticker:0.5"}},
+		{"Going long on NYSE: BRK.B and TGT today", []string{"4:exchange:0.95", "2:ticker:0.5"}},
+		// the cashtag is not counted again as a ticker, nor BRK.B's parts
+		{"$TGT and BRK.B", []string{"2:cashtag:0.9"}},
+		// common words and one letter tickers are only found as cashtags
+		{"GE is big. I think X is a BUY. $X", []string{"8:cashtag:0.9", "5:ticker:0.5"}},
+		{"WHY IS TGT DOWN SO MUCH TODAY", []string{"2:ticker:0.2"}},
+		{"US$5 and A$B", []string{}},
+	}
+
+	for _, tt := range tests {
+		got := []string{}
+		for _, m := range f.Find(tt.text) {
+			got = append(got, fmt.Sprintf("%d:%s:%v", m.ListingID, m.Kind, m.Confidence))
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("Find(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	links := Aggregate([]Mention{
+		{ListingID: 2, Kind: MatchTicker, Confidence: 0.5},
+		{ListingID: 1, Kind: MatchTicker, Confidence: 0.5},
+		{ListingID: 2, Kind: MatchCashtag, Confidence: 0.9},
+		{ListingID: 2, Kind: MatchTicker, Confidence: 0.5},
+	})
+
+	if len(links) != 2 || links[0].ListingID != 2 || links[1].ListingID != 1 {
+		t.Fatalf("Aggregate = %v", links)
+	}
+	if l := links[0]; l.MentionCount != 3 || l.Confidence != 0.975 || strings.Join(l.Kinds, ",") != "cashtag,ticker" {
+		t.Errorf("links[0] = %+v", *l)
+	}
+	if l := links[1]; l.MentionCount != 1 || l.Confidence != 0.5 {
+		t.Errorf("links[1] = %+v", *l)
+	}
+}
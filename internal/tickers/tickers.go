@@ -0,0 +1,367 @@
+// Package tickers finds mentions of stock listings in text by cashtag, exchange prefixed ticker,
+// and company name.
+package tickers
+
+import (
+	"context"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/wpwilson10/caterpillar/internal/stocks"
+)
+
+// Kinds of mentions
+const (
+	MatchCashtag  = "cashtag"  // $AAPL
+	MatchExchange = "exchange" // NASDAQ: AAPL
+	MatchName     = "name"     // Apple Inc, Apple
+)
+
+// Confidence that a single mention of each kind refers to the listing
+const (
+	cashtagConfidence      = 0.9
+	lowerCashtagConfidence = 0.6 // $aapl, common on reddit but also used for other things
+	exchangeConfidence     = 0.95
+	nameConfidence         = 0.7 // names of several words
+	wordNameConfidence     = 0.4 // one word names such as Apple are often ordinary words, see findNames
+)
+
+// shortest one word name that is matched
+const minWordNameLength = 4
+
+// refreshInterval is how often the shared linker and symbol finder reload listings
+const refreshInterval = time.Hour
+
+// cashtags such as $AAPL or $BRK.B, not preceded by a word character so US$5 and A$B are skipped
+var cashtagRe = regexp.MustCompile(`(?:^|[^\w$])\$([A-Za-z]{1,5}(?:\.[A-Za-z])?)\b`)
+
+// exchange prefixed tickers such as NASDAQ: AAPL or (NYSE:BRK.B)
+var exchangeRe = regexp.MustCompile(`(?i:\b(?:nasdaq(?:gs|gm|cm)?|nyse(?:\s*(?:american|arca|mkt))?|amex|otc(?:qx|qb|mkts)?|bats|cboe)\s*:\s*)([A-Z]{1,5}(?:\.[A-Z])?)\b`)
+
+// legal suffixes of company names, removed from the end of names
+var legalSuffixes = map[string]bool{
+	"inc": true, "incorporated": true, "corp": true, "corporation": true, "co": true, "company": true,
+	"ltd": true, "limited": true, "plc": true, "llc": true, "lp": true, "sa": true, "ag": true, "nv": true,
+}
+
+// other words removed from the end of names
+var nameSuffixes = map[string]bool{
+	"l": true, "p": true, "se": true, "com": true, "holding": true, "holdings": true, "group": true,
+	"the": true, "cl": true, "class": true, "common": true, "stock": true, "shares": true, "ordinary": true,
+	"ads": true, "adr": true, "sponsored": true, "new": true,
+}
+
+// one word names too common in ordinary writing to link
+var genericNames = map[string]bool{
+	"american": true, "general": true, "first": true, "united": true, "national": true, "international": true,
+	"global": true, "great": true, "china": true, "energy": true, "capital": true, "bank": true, "trust": true,
+	"financial": true, "pacific": true, "southern": true, "western": true, "northern": true, "eastern": true,
+	"realty": true, "partners": true, "resources": true, "technologies": true, "industries": true,
+	"systems": true, "services": true, "solutions": true, "therapeutics": true, "pharmaceuticals": true,
+	"today": true, "people": true, "world": true, "life": true, "home": true, "health": true,
+}
+
+// Mention is a single reference to a listing in text.
+type Mention struct {
+	ListingID  int64
+	Kind       string
+	Confidence float64
+}
+
+// Link is all mentions of a listing in some text.
+type Link struct {
+	ListingID    int64
+	MentionCount int
+	Confidence   float64  // chance at least one mention refers to the listing
+	Kinds        []string // kinds of mentions found, sorted
+}
+
+// Linker finds listings mentioned in text.
+type Linker struct {
+	symbols map[string]int64   // upper case symbol to listing
+	names   map[string][]*name // first word of a name to names starting with it
+}
+
+// name is a company name as lower case words
+type name struct {
+	words     []string
+	listingID int64
+}
+
+// NewLinker returns a linker for the listings. When listings share a symbol or name,
+// active listings are preferred, then common stock, then older listings.
+func NewLinker(listings []stocks.Listing) *Linker {
+	sorted := make([]stocks.Listing, len(listings))
+	copy(sorted, listings)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.IsActive != b.IsActive {
+			return a.IsActive
+		}
+		if (a.Type == "cs") != (b.Type == "cs") {
+			return a.Type == "cs"
+		}
+		return a.ListingID < b.ListingID
+	})
+
+	l := &Linker{symbols: map[string]int64{}, names: map[string][]*name{}}
+	seenNames := map[string]bool{}
+	for _, listing := range sorted {
+		symbol := strings.ToUpper(strings.TrimSpace(listing.Symbol))
+		if _, ok := l.symbols[symbol]; !ok && len(symbol) > 0 {
+			l.symbols[symbol] = listing.ListingID
+		}
+
+		normal := NormalizeName(listing.Name)
+		if len(normal) == 0 || seenNames[normal] {
+			continue
+		}
+		seenNames[normal] = true
+
+		words := strings.Fields(normal)
+		if len(words) == 1 && (len(words[0]) < minWordNameLength || genericNames[words[0]]) {
+			continue
+		}
+		l.names[words[0]] = append(l.names[words[0]], &name{words: words, listingID: listing.ListingID})
+	}
+
+	// longest names first so "bank of america" wins over "bank"
+	for _, names := range l.names {
+		sort.SliceStable(names, func(i, j int) bool {
+			return len(names[i].words) > len(names[j].words)
+		})
+	}
+
+	return l
+}
+
+// NormalizeName returns a company name as lower case words without punctuation, share classes,
+// or suffixes such as Inc, Corp, or Ltd. Returns an empty string if nothing is left.
+func NormalizeName(s string) string {
+	s = strings.ToLower(s)
+	// IEX names share classes like "Alphabet Inc - Class A"
+	if i := strings.Index(s, " - "); i > 0 {
+		s = s[:i]
+	}
+	if i := strings.Index(s, "("); i > 0 {
+		s = s[:i]
+	}
+
+	words := splitWords(s)
+	for len(words) > 0 && words[0] == "the" {
+		words = words[1:]
+	}
+	for len(words) > 0 && (legalSuffixes[words[len(words)-1]] || nameSuffixes[words[len(words)-1]]) {
+		words = words[:len(words)-1]
+	}
+
+	return strings.Join(words, " ")
+}
+
+// splitWords splits text into words of letters, digits, and ampersands, so AT&T is one word.
+func splitWords(s string) []string {
+	return strings.FieldsFunc(s, isSeparator)
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '&'
+}
+
+// Find returns each mention of a known listing in the text.
+// One word names are only found when followed by a legal suffix such as Inc,
+// or when the text also mentions the listing by cashtag or exchange prefixed ticker.
+func (l *Linker) Find(text string) []Mention {
+	out := []Mention{}
+
+	for _, m := range cashtagRe.FindAllStringSubmatch(text, -1) {
+		symbol := m[1]
+		confidence := cashtagConfidence
+		if symbol != strings.ToUpper(symbol) {
+			confidence = lowerCashtagConfidence
+		}
+		if id, ok := l.symbols[strings.ToUpper(symbol)]; ok {
+			out = append(out, Mention{ListingID: id, Kind: MatchCashtag, Confidence: confidence})
+		}
+	}
+
+	for _, m := range exchangeRe.FindAllStringSubmatch(text, -1) {
+		if id, ok := l.symbols[m[1]]; ok {
+			out = append(out, Mention{ListingID: id, Kind: MatchExchange, Confidence: exchangeConfidence})
+		}
+	}
+
+	confirmed := map[int64]bool{}
+	for _, m := range out {
+		confirmed[m.ListingID] = true
+	}
+
+	return append(out, l.findNames(text, confirmed)...)
+}
+
+// findNames returns mentions of company names, which must start with a capital letter.
+// One word names such as Target or Square are ordinary words too, so they are skipped
+// unless followed by a legal suffix or the listing is in confirmed.
+func (l *Linker) findNames(text string, confirmed map[int64]bool) []Mention {
+	original := splitWords(text)
+	words := make([]string, len(original))
+	for i, w := range original {
+		words[i] = strings.ToLower(w)
+	}
+
+	out := []Mention{}
+	for i := 0; i < len(words); i++ {
+		if first := []rune(original[i])[0]; !unicode.IsUpper(first) {
+			continue
+		}
+
+		for _, n := range l.names[words[i]] {
+			if !hasWords(words[i:], n.words) {
+				continue
+			}
+
+			// one word names are only trusted when followed by a suffix like Inc
+			confidence := nameConfidence
+			if next := i + len(n.words); len(n.words) == 1 && (next >= len(words) || !legalSuffixes[words[next]]) {
+				if !confirmed[n.listingID] {
+					continue
+				}
+				confidence = wordNameConfidence
+			}
+			out = append(out, Mention{ListingID: n.listingID, Kind: MatchName, Confidence: confidence})
+			// skip the rest of the name so it is not matched again
+			i += len(n.words) - 1
+			break
+		}
+	}
+
+	return out
+}
+
+// hasWords returns true if words starts with prefix.
+func hasWords(words []string, prefix []string) bool {
+	if len(words) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if words[i] != prefix[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Link returns the listings mentioned in any of the texts, most confident first.
+func (l *Linker) Link(texts ...string) []*Link {
+	mentions := []Mention{}
+	for _, text := range texts {
+		mentions = append(mentions, l.Find(text)...)
+	}
+
+	return Aggregate(mentions)
+}
+
+// Aggregate combines mentions by listing, most confident first.
+// A listing's confidence is the chance at least one of its mentions is right, treating them as independent.
+func Aggregate(mentions []Mention) []*Link {
+	byID := map[int64]*Link{}
+	missed := map[int64]float64{}
+	out := []*Link{}
+	for _, m := range mentions {
+		link, ok := byID[m.ListingID]
+		if !ok {
+			link = &Link{ListingID: m.ListingID}
+			byID[m.ListingID] = link
+			missed[m.ListingID] = 1
+			out = append(out, link)
+		}
+
+		link.MentionCount++
+		missed[m.ListingID] *= 1 - m.Confidence
+		if !containsString(link.Kinds, m.Kind) {
+			link.Kinds = append(link.Kinds, m.Kind)
+		}
+	}
+
+	for _, link := range out {
+		link.Confidence = math.Round((1-missed[link.ListingID])*1000) / 1000
+		sort.Strings(link.Kinds)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Confidence > out[j].Confidence
+	})
+
+	return out
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LoadLinker returns a linker for every listing in the database.
+func LoadLinker(ctx context.Context, db *sqlx.DB) (*Linker, error) {
+	listings := []stocks.Listing{}
+	err := db.SelectContext(ctx, &listings, "SELECT * FROM Listing ORDER BY listing_id ASC")
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLinker(listings), nil
+}
+
+// cache holds a value loaded from the listings, reloaded at most once per refresh interval
+// so that apps that run for a long time find new listings. Safe for concurrent use.
+type cache struct {
+	sync.Mutex
+	load   func(ctx context.Context, db *sqlx.DB) (interface{}, error)
+	value  interface{}
+	loaded time.Time
+}
+
+// get returns the cached value, loading it if it is missing or older than the refresh interval.
+// If reloading fails, the previous value, nil if there is none, is returned with the error.
+func (c *cache) get(ctx context.Context, db *sqlx.DB) (interface{}, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.value != nil && time.Since(c.loaded) < refreshInterval {
+		return c.value, nil
+	}
+
+	value, err := c.load(ctx, db)
+	// wait a full interval before trying again either way
+	c.loaded = time.Now()
+	if err != nil {
+		return c.value, err
+	}
+	c.value = value
+
+	return value, nil
+}
+
+// shared linker used by apps that link as they collect
+var sharedLinker = &cache{load: func(ctx context.Context, db *sqlx.DB) (interface{}, error) {
+	return LoadLinker(ctx, db)
+}}
+
+// Shared returns a linker for every listing, loading it at most once per refresh interval.
+// If reloading fails, the previous linker is returned with the error.
+func Shared(ctx context.Context, db *sqlx.DB) (*Linker, error) {
+	value, err := sharedLinker.get(ctx, db)
+	linker, _ := value.(*Linker)
+
+	return linker, err
+}
@@ -0,0 +1,137 @@
+package tickers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/wpwilson10/caterpillar/internal/stocks"
+)
+
+// testListings are the listings used by the linker and symbol finder tests
+var testListings = []stocks.Listing{
+	{ListingID: 1, Symbol: "AAPL", Name: "Apple Inc", Type: "cs", IsActive: true},
+	{ListingID: 2, Symbol: "TGT", Name: "Target Corp", Type: "cs", IsActive: true},
+	{ListingID: 3, Symbol: "BAC", Name: "Bank of America Corp", Type: "cs", IsActive: true},
+	{ListingID: 4, Symbol: "BRK.B", Name: "Berkshire Hathaway Inc - Class B", Type: "cs", IsActive: true},
+	{ListingID: 5, Symbol: "GE", Name: "General Electric Co", Type: "cs", IsActive: true},
+	{ListingID: 6, Symbol: "UAL", Name: "United Airlines Holdings Inc", Type: "cs", IsActive: true},
+	{ListingID: 7, Symbol: "AAPL", Name: "Apple Inc", Type: "cs", IsActive: false},
+	{ListingID: 8, Symbol: "X", Name: "United States Steel Corp", Type: "cs", IsActive: true},
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := map[string]string{
+		"Apple Inc":                         "apple",
+		"Alphabet Inc - Class A":            "alphabet",
+		"The Walt Disney Co.":               "walt disney",
+		"AT&T Inc.":                         "at&t",
+		"Berkshire Hathaway Inc. (Class B)": "berkshire hathaway",
+		"Royal Dutch Shell PLC ADR":         "royal dutch shell",
+		"Inc":                               "",
+	}
+
+	for in, want := range tests {
+		if got := NormalizeName(in); got != want {
+			t.Errorf("NormalizeName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestLinkerFind(t *testing.T) {
+	l := NewLinker(testListings)
+
+	tests := []struct {
+		text string
+		want []string // listing:kind
+	}{
+		{"Shares of $AAPL rose.", []string{"1:cashtag"}},
+		{"Shares of $aapl rose.", []string{"1:cashtag"}},
+		{"US$5 and A$B are not cashtags.", []string{}},
+		{"Berkshire Hathaway (NYSE:BRK.B) reported earnings.", []string{"4:exchange", "4:name"}},
+		{"Bank of America said on Monday", []string{"3:name"}},
+		{"General Electric Co said", []string{"5:name"}},
+		{"United Airlines Holdings flew", []string{"6:name"}},
+		// one word names need a legal suffix or another mention of the listing
+		{"Apple Inc reported earnings.", []string{"1:name"}},
+		{"Target the right customers.", []string{}},
+		{"Apple pie is popular.", []string{}},
+		{"Target ($TGT) cut prices. Target said sales fell.", []string{"2:cashtag", "2:name", "2:name"}},
+		// names start with a capital letter
+		{"an apple inc a day", []string{}},
+	}
+
+	for _, tt := range tests {
+		got := []string{}
+		for _, m := range l.Find(tt.text) {
+			got = append(got, fmt.Sprintf("%d:%s", m.ListingID, m.Kind))
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("Find(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestLinkerLink(t *testing.T) {
+	links := NewLinker(testListings).Link("Apple Inc and $TGT", "Apple Inc sold more phones.")
+
+	if len(links) != 2 {
+		t.Fatalf("Link = %d links, want 2", len(links))
+	}
+	// two name mentions at 0.7 beat one cashtag at 0.9
+	if l := links[0]; l.ListingID != 1 || l.MentionCount != 2 || l.Confidence != 0.91 || strings.Join(l.Kinds, ",") != MatchName {
+		t.Errorf("links[0] = %+v", *l)
+	}
+	if l := links[1]; l.ListingID != 2 || l.MentionCount != 1 || l.Confidence != 0.9 || strings.Join(l.Kinds, ",") != MatchCashtag {
+		t.Errorf("links[1] = %+v", *l)
+	}
+}
+
+func TestCache(t *testing.T) {
+	calls := 0
+	var fail error
+	c := &cache{load: func(ctx context.Context, db *sqlx.DB) (interface{}, error) {
+		calls++
+		if fail != nil {
+			return (*Linker)(nil), fail
+		}
+		return NewLinker(testListings[:calls]), nil
+	}}
+	ctx := context.Background()
+
+	// a failed first load has no previous value
+	fail = errors.New("no database")
+	if v, err := c.get(ctx, nil); v != nil || err != fail {
+		t.Errorf("get = %v, %v", v, err)
+	}
+
+	// loaded again right away since there is no value yet
+	fail = nil
+	first, err := c.get(ctx, nil)
+	if err != nil || first == nil {
+		t.Fatalf("get = %v, %v", first, err)
+	}
+	if v, _ := c.get(ctx, nil); v != first || calls != 2 {
+		t.Errorf("value was reloaded within the refresh interval, %d loads", calls)
+	}
+
+	// failed reloads keep the previous value and wait a full interval
+	c.loaded = c.loaded.Add(-refreshInterval)
+	fail = errors.New("connection reset")
+	if v, err := c.get(ctx, nil); v != first || err != fail {
+		t.Errorf("failed reload = %v, %v", v, err)
+	}
+	if v, err := c.get(ctx, nil); v != first || err != nil || calls != 3 {
+		t.Errorf("get after failed reload = %v, %v with %d loads", v, err, calls)
+	}
+
+	c.loaded = c.loaded.Add(-refreshInterval)
+	fail = nil
+	if v, err := c.get(ctx, nil); v == first || err != nil || calls != 4 {
+		t.Errorf("reload = %v, %v with %d loads", v, err, calls)
+	}
+}
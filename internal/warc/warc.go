@@ -0,0 +1,271 @@
+// Package warc writes and reads WARC 1.1 archive files of individually gzip compressed records,
+// so that any record can be read by its file offset without decompressing the whole file.
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record types used by this package
+const (
+	TypeInfo     = "warcinfo"
+	TypeResponse = "response"
+)
+
+// version line that starts every record
+const version = "WARC/1.1"
+
+// Record is a single WARC record.
+type Record struct {
+	Type        string    // WARC-Type
+	ID          string    // WARC-Record-ID, set on write if empty
+	Date        time.Time // WARC-Date, set on write if zero
+	TargetURI   string    // WARC-Target-URI
+	ContentType string    // Content-Type of the block
+	Block       []byte    // record content, such as a raw HTTP response
+}
+
+// Location is where a record is stored.
+type Location struct {
+	File   string // file name within the writer's directory
+	Offset int64  // byte offset of the record's gzip member
+	Length int64  // compressed length of the record
+}
+
+// ErrBadRecord is returned when a record can not be parsed.
+var ErrBadRecord = errors.New("warc: malformed record")
+
+// Writer appends records to rotating WARC files in a directory.
+// A new file is started once the current one reaches the maximum size.
+// Safe for concurrent use.
+type Writer struct {
+	mu      sync.Mutex
+	dir     string
+	prefix  string // file name prefix
+	maxSize int64  // size in bytes after which a new file is started
+	file    *os.File
+	name    string // current file name
+	size    int64  // current file size
+	serial  int    // number of files started by this writer
+}
+
+// NewWriter creates a Writer for files named prefix-<timestamp>-<serial>.warc.gz in dir.
+// The directory is created if it does not exist.
+func NewWriter(dir string, prefix string, maxSize int64) (*Writer, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Writer{dir: dir, prefix: prefix, maxSize: maxSize}, nil
+}
+
+// Write appends the record to the current file and returns where it was stored.
+func (w *Writer) Write(r *Record) (Location, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil || w.size >= w.maxSize {
+		err := w.rotate()
+		if err != nil {
+			return Location{}, err
+		}
+	}
+
+	return w.write(r)
+}
+
+// Close closes the current file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+
+	return err
+}
+
+// rotate closes the current file and starts a new one with a warcinfo record.
+func (w *Writer) rotate() error {
+	if w.file != nil {
+		err := w.file.Close()
+		w.file = nil
+		if err != nil {
+			return err
+		}
+	}
+
+	w.serial = w.serial + 1
+	name := fmt.Sprintf("%s-%s-%05d.warc.gz", w.prefix, time.Now().UTC().Format("20060102150405"), w.serial)
+	f, err := os.OpenFile(filepath.Join(w.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file, w.name, w.size = f, name, info.Size()
+
+	_, err = w.write(&Record{
+		Type:        TypeInfo,
+		ContentType: "application/warc-fields",
+		Block:       []byte("software: caterpillar\r\nformat: WARC File Format 1.1\r\n"),
+	})
+
+	return err
+}
+
+// write compresses the record as its own gzip member at the end of the current file.
+func (w *Writer) write(r *Record) (Location, error) {
+	if len(r.ID) == 0 {
+		id, err := newRecordID()
+		if err != nil {
+			return Location{}, err
+		}
+		r.ID = id
+	}
+	if r.Date.IsZero() {
+		r.Date = time.Now()
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write(r.header())
+	if err == nil {
+		_, err = zw.Write(r.Block)
+	}
+	if err == nil {
+		_, err = zw.Write([]byte("\r\n\r\n"))
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		return Location{}, err
+	}
+
+	loc := Location{File: w.name, Offset: w.size, Length: int64(buf.Len())}
+	n, err := w.file.Write(buf.Bytes())
+	w.size = w.size + int64(n)
+	if err != nil {
+		return Location{}, err
+	}
+
+	return loc, nil
+}
+
+// header returns the record's version line and named fields.
+func (r *Record) header() []byte {
+	digest := sha1.Sum(r.Block)
+
+	var sb strings.Builder
+	sb.WriteString(version + "\r\n")
+	sb.WriteString("WARC-Type: " + r.Type + "\r\n")
+	sb.WriteString("WARC-Record-ID: " + r.ID + "\r\n")
+	sb.WriteString("WARC-Date: " + r.Date.UTC().Format(time.RFC3339) + "\r\n")
+	if len(r.TargetURI) > 0 {
+		sb.WriteString("WARC-Target-URI: " + r.TargetURI + "\r\n")
+	}
+	if len(r.ContentType) > 0 {
+		sb.WriteString("Content-Type: " + r.ContentType + "\r\n")
+	}
+	sb.WriteString("WARC-Block-Digest: sha1:" + base32.StdEncoding.EncodeToString(digest[:]) + "\r\n")
+	sb.WriteString("Content-Length: " + strconv.Itoa(len(r.Block)) + "\r\n")
+	sb.WriteString("\r\n")
+
+	return []byte(sb.String())
+}
+
+// newRecordID returns a random version 4 UUID as a WARC record ID.
+func newRecordID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// ReadRecord reads the record stored at the location in dir.
+func ReadRecord(dir string, loc Location) (*Record, error) {
+	f, err := os.Open(filepath.Join(dir, filepath.Base(loc.File)))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(io.NewSectionReader(f, loc.Offset, loc.Length))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	// only this record's gzip member
+	zr.Multistream(false)
+
+	return readRecord(bufio.NewReader(zr))
+}
+
+// readRecord parses a record's version line, named fields, and block.
+func readRecord(br *bufio.Reader) (*Record, error) {
+	tp := textproto.NewReader(br)
+	line, err := tp.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "WARC/") {
+		return nil, ErrBadRecord
+	}
+
+	fields, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.ParseInt(fields.Get("Content-Length"), 10, 64)
+	if err != nil || length < 0 {
+		return nil, ErrBadRecord
+	}
+
+	block, err := ioutil.ReadAll(io.LimitReader(br, length))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(block)) != length {
+		return nil, ErrBadRecord
+	}
+
+	r := &Record{
+		Type:        fields.Get("WARC-Type"),
+		ID:          fields.Get("WARC-Record-ID"),
+		TargetURI:   fields.Get("WARC-Target-URI"),
+		ContentType: fields.Get("Content-Type"),
+		Block:       block,
+	}
+	r.Date, _ = time.Parse(time.RFC3339, fields.Get("WARC-Date"))
+
+	return r, nil
+}
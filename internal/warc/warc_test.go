@@ -0,0 +1,108 @@
+package warc
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriterReadRecord(t *testing.T) {
+	dir, err := ioutil.TempDir("", "warc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// small enough that every few records start a new file
+	w, err := NewWriter(dir, "test", 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := []*Record{}
+	locs := []Location{}
+	for i := 0; i < 10; i++ {
+		r := &Record{
+			Type:        TypeResponse,
+			TargetURI:   fmt.Sprintf("https://www.example.com/story/%d?page=1", i),
+			ContentType: "application/http;msgtype=response",
+			Block:       []byte(fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\n<p>story %d %s</p>", i, bytes.Repeat([]byte{'x'}, 100*i))),
+		}
+		loc, err := w.Write(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+		locs = append(locs, loc)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "test-*.warc.gz"))
+	if len(files) < 2 || w.serial != len(files) {
+		t.Fatalf("wrote %d files with serial %d, want a rotation", len(files), w.serial)
+	}
+
+	for i, loc := range locs {
+		got, err := ReadRecord(dir, loc)
+		if err != nil {
+			t.Fatalf("record %d at %+v: %v", i, loc, err)
+		}
+		want := records[i]
+		if got.Type != want.Type || got.ID != want.ID || got.TargetURI != want.TargetURI ||
+			got.ContentType != want.ContentType || !bytes.Equal(got.Block, want.Block) {
+			t.Errorf("record %d = %+v, want %+v", i, got, want)
+		}
+		if !got.Date.Equal(want.Date.Truncate(1e9)) {
+			t.Errorf("record %d Date = %v, want %v", i, got.Date, want.Date)
+		}
+	}
+
+	// every file starts with a warcinfo record
+	for _, file := range files {
+		info, err := ReadRecord(dir, Location{File: filepath.Base(file), Offset: 0, Length: 1 << 20})
+		if err != nil || info.Type != TypeInfo {
+			t.Errorf("%s starts with %+v, %v", file, info, err)
+		}
+	}
+}
+
+func TestReadRecordTruncated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "warc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := NewWriter(dir, "test", 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// random bytes do not compress, so a cut member is missing part of the block
+	block := make([]byte, 4096)
+	rand.New(rand.NewSource(1)).Read(block)
+	loc, err := w.Write(&Record{Type: TypeResponse, TargetURI: "https://www.example.com/", Block: block})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	for _, length := range []int64{loc.Length / 2, loc.Length - 20, 10} {
+		cut := loc
+		cut.Length = length
+		r, err := ReadRecord(dir, cut)
+		if err == nil {
+			t.Errorf("length %d of %d read %d block bytes, want an error", length, loc.Length, len(r.Block))
+		}
+	}
+
+	// missing files return the open error
+	if _, err = ReadRecord(dir, Location{File: "missing.warc.gz", Length: 10}); !os.IsNotExist(err) {
+		t.Errorf("missing file err = %v", err)
+	}
+}
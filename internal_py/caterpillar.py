@@ -22,8 +22,20 @@ class CaterpillarServicer(caterpillar_pb2_grpc.CaterpillarServicer):
         self.config.memoize_articles = False
         self.config.fetch_images = False
         self.config.follow_meta_refresh = True
-        # setup sentence parser
-        self.seg = pysbd.Segmenter(language="en", clean=False)
+        # setup sentence parsers, created for other languages as needed
+        self.seg = {"en": pysbd.Segmenter(language="en", clean=False)}
+
+    def segmenter(self, language):
+        """Returns a sentence parser for the ISO 639-1 language code, using English if not supported"""
+        if not language:
+            return self.seg["en"]
+        if language not in self.seg:
+            try:
+                self.seg[language] = pysbd.Segmenter(language=language, clean=False)
+            except ValueError:
+                APP_LOG.warning("No sentence parser for language %s", language)
+                self.seg[language] = self.seg["en"]
+        return self.seg[language]
 
     # Newspaper3k article extraction
     def Newspaper(self, request, context):
@@ -18,7 +18,7 @@ DESCRIPTOR = _descriptor.FileDescriptor(
   package='caterpillar',
   syntax='proto3',
   serialized_options=b'Z*github.com/wpwilson10/caterpillar/protobuf',
-  serialized_pb=b'\n\x11\x63\x61terpillar.proto\x12\x0b\x63\x61terpillar\" \n\x10NewspaperRequest\x12\x0c\n\x04link\x18\x01 \x01(\t\"p\n\x0eNewspaperReply\x12\x0c\n\x04link\x18\x01 \x01(\t\x12\r\n\x05title\x18\x02 \x01(\t\x12\x0c\n\x04text\x18\x03 \x01(\t\x12\x11\n\tcanonical\x18\x04 \x01(\t\x12\x0f\n\x07pubdate\x18\x05 \x01(\t\x12\x0f\n\x07\x61uthors\x18\x06 \x03(\t\"\x1b\n\x0bTextRequest\x12\x0c\n\x04text\x18\x01 \x01(\t\"\"\n\rSentenceReply\x12\x11\n\tsentences\x18\x01 \x03(\t\"1\n\x0cSummaryReply\x12\x0f\n\x07summary\x18\x01 \x01(\t\x12\x10\n\x08keywords\x18\x02 \x03(\t2\xdf\x01\n\x0b\x43\x61terpillar\x12I\n\tNewspaper\x12\x1d.caterpillar.NewspaperRequest\x1a\x1b.caterpillar.NewspaperReply\"\x00\x12\x43\n\tSentences\x12\x18.caterpillar.TextRequest\x1a\x1a.caterpillar.SentenceReply\"\x00\x12@\n\x07Summary\x12\x18.caterpillar.TextRequest\x1a\x19.caterpillar.SummaryReply\"\x00\x42,Z*github.com/wpwilson10/caterpillar/protobufb\x06proto3'
+  serialized_pb=b'\n\x11\x63\x61terpillar.proto\x12\x0b\x63\x61terpillar\".\n\x10NewspaperRequest\x12\x0c\n\x04link\x18\x01 \x01(\t\x12\x0c\n\x04html\x18\x02 \x01(\t\"p\n\x0eNewspaperReply\x12\x0c\n\x04link\x18\x01 \x01(\t\x12\r\n\x05title\x18\x02 \x01(\t\x12\x0c\n\x04text\x18\x03 \x01(\t\x12\x11\n\tcanonical\x18\x04 \x01(\t\x12\x0f\n\x07pubdate\x18\x05 \x01(\t\x12\x0f\n\x07\x61uthors\x18\x06 \x03(\t\"-\n\x0bTextRequest\x12\x0c\n\x04text\x18\x01 \x01(\t\x12\x10\n\x08language\x18\x02 \x01(\t\"\"\n\rSentenceReply\x12\x11\n\tsentences\x18\x01 \x03(\t\"1\n\x0cSummaryReply\x12\x0f\n\x07summary\x18\x01 \x01(\t\x12\x10\n\x08keywords\x18\x02 \x03(\t2\xdf\x01\n\x0b\x43\x61terpillar\x12I\n\tNewspaper\x12\x1d.caterpillar.NewspaperRequest\x1a\x1b.caterpillar.NewspaperReply\"\x00\x12\x43\n\tSentences\x12\x18.caterpillar.TextRequest\x1a\x1a.caterpillar.SentenceReply\"\x00\x12@\n\x07Summary\x12\x18.caterpillar.TextRequest\x1a\x19.caterpillar.SummaryReply\"\x00\x42,Z*github.com/wpwilson10/caterpillar/protobufb\x06proto3'
 )
 
 
@@ -38,6 +38,13 @@ _NEWSPAPERREQUEST = _descriptor.Descriptor(
       message_type=None, enum_type=None, containing_type=None,
       is_extension=False, extension_scope=None,
       serialized_options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='html', full_name='caterpillar.NewspaperRequest.html', index=1,
+      number=2, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=b"".decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      serialized_options=None, file=DESCRIPTOR),
   ],
   extensions=[
   ],
@@ -51,7 +58,7 @@ _NEWSPAPERREQUEST = _descriptor.Descriptor(
   oneofs=[
   ],
   serialized_start=34,
-  serialized_end=66,
+  serialized_end=80,
 )
 
 
@@ -116,8 +123,8 @@ _NEWSPAPERREPLY = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=68,
-  serialized_end=180,
+  serialized_start=82,
+  serialized_end=194,
 )
 
 
@@ -135,6 +142,13 @@ _TEXTREQUEST = _descriptor.Descriptor(
       message_type=None, enum_type=None, containing_type=None,
       is_extension=False, extension_scope=None,
       serialized_options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='language', full_name='caterpillar.TextRequest.language', index=1,
+      number=2, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=b"".decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      serialized_options=None, file=DESCRIPTOR),
   ],
   extensions=[
   ],
@@ -147,8 +161,8 @@ _TEXTREQUEST = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=182,
-  serialized_end=209,
+  serialized_start=196,
+  serialized_end=241,
 )
 
 
@@ -178,8 +192,8 @@ _SENTENCEREPLY = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=211,
-  serialized_end=245,
+  serialized_start=243,
+  serialized_end=277,
 )
 
 
@@ -216,8 +230,8 @@ _SUMMARYREPLY = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=247,
-  serialized_end=296,
+  serialized_start=279,
+  serialized_end=328,
 )
 
 DESCRIPTOR.message_types_by_name['NewspaperRequest'] = _NEWSPAPERREQUEST
@@ -271,8 +285,8 @@ _CATERPILLAR = _descriptor.ServiceDescriptor(
   file=DESCRIPTOR,
   index=0,
   serialized_options=None,
-  serialized_start=299,
-  serialized_end=522,
+  serialized_start=331,
+  serialized_end=554,
   methods=[
   _descriptor.MethodDescriptor(
     name='Newspaper',
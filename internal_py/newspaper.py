@@ -10,7 +10,7 @@ from .setup import APP_LOG
 def newspaper(self, request, context):
     '''newspaper handles caterpillar calls to newspaper3k library'''
     # call newspaper library
-    response = extract(link=request.link, config=self.config)
+    response = extract(link=request.link, html=request.html, config=self.config)
     # check if we failed
     if response is None:
         context.set_code(grpc.StatusCode.INTERNAL)
@@ -18,14 +18,17 @@ def newspaper(self, request, context):
     # otherwise return data
     return response
 
-def extract(link, config):
-    '''extract parses an article from the given link'''
+def extract(link, html, config):
+    '''extract parses an article from the given link, downloading it if html is empty'''
     try:
         # setup article
         article = Article(link, config=config)
 
         # extract article
-        article.download()
+        if html:
+            article.download(input_html=html)
+        else:
+            article.download()
         article.parse()
 
         # create reply with information to return
@@ -7,8 +7,8 @@ from . import caterpillar_pb2
 
 def sentences(self, request, context):
     """sentences parses text blocks into individual sentences"""
-    # call sentence parser
-    sent = self.seg.segment(request.text)
+    # call sentence parser for the text's language
+    sent = self.segmenter(request.language).segment(request.text)
     response = caterpillar_pb2.SentenceReply()
     # check if we failed
     if sent is None or len(sent) < 1:
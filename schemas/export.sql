@@ -0,0 +1,11 @@
+-- Progress of incremental exports, each run exports rows after last_id
+CREATE TABLE ExportWatermark(
+	name text PRIMARY KEY, -- table, format, and output directory (e.g. newsarticle:jsonl:/data/export)
+	table_name text,
+	format text, -- jsonl, csv, or parquet
+	directory text, -- root output directory
+	last_id bigint DEFAULT 0, -- key of the last exported row
+	num_rows bigint DEFAULT 0, -- rows exported over all runs
+	num_runs bigint DEFAULT 0,
+	updated_time timestamptz
+);
@@ -0,0 +1,22 @@
+-- Adds full text search columns and indexes to existing databases.
+-- New databases get these from news.sql and reddit.sql.
+-- Requires PostgreSQL 12 or later for generated columns.
+-- Adding a stored generated column rewrites the table, so run this while the crawlers are stopped.
+
+ALTER TABLE NewsArticle ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+	setweight(to_tsvector('english', coalesce(title, source_title, '')), 'A') ||
+	setweight(to_tsvector('english', coalesce(body, '')), 'B')
+) STORED;
+
+ALTER TABLE RedditSubmission ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+	setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+	setweight(to_tsvector('english', coalesce(selftext, '')), 'B')
+) STORED;
+
+ALTER TABLE RedditComment ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+	to_tsvector('english', coalesce(body, ''))
+) STORED;
+
+CREATE INDEX IF NOT EXISTS article_search_index ON NewsArticle USING GIN(search);
+CREATE INDEX IF NOT EXISTS submission_search_index ON RedditSubmission USING GIN(search);
+CREATE INDEX IF NOT EXISTS comment_search_index ON RedditComment USING GIN(search);
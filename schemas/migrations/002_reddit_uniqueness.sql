@@ -0,0 +1,74 @@
+-- Removes duplicate reddit submissions and comments and adds the unique keys that reddit upserts use.
+-- New databases get these from reddit.sql.
+-- Rows in the snapshot, mention and sentiment tables that point at duplicates are moved or deleted.
+-- Those tables are skipped if they do not exist, so this can run before or after sentiment.sql.
+-- The first stored copy of each submission and comment is kept. Its votes and text are updated
+-- the next time the submission is fetched.
+
+BEGIN;
+
+-- duplicate submissions and the first copy that replaces them
+CREATE TEMP TABLE submission_duplicate AS
+SELECT submission_id, keep_id FROM (
+	SELECT submission_id, min(submission_id) OVER (PARTITION BY reddit_id) AS keep_id
+	FROM RedditSubmission
+	WHERE reddit_id IS NOT NULL
+) copies
+WHERE submission_id <> keep_id;
+
+UPDATE RedditComment c SET submission_id = d.keep_id
+FROM submission_duplicate d WHERE c.submission_id = d.submission_id;
+
+DO $$
+BEGIN
+	IF to_regclass('redditsubmissionsnapshot') IS NOT NULL THEN
+		UPDATE RedditSubmissionSnapshot s SET submission_id = d.keep_id
+		FROM submission_duplicate d WHERE s.submission_id = d.submission_id;
+	END IF;
+END $$;
+
+INSERT INTO RedditNews (article_id, submission_id, data_entry_time)
+SELECT n.article_id, d.keep_id, n.data_entry_time
+FROM RedditNews n JOIN submission_duplicate d ON d.submission_id = n.submission_id
+ON CONFLICT (article_id, submission_id) DO NOTHING;
+DELETE FROM RedditNews n USING submission_duplicate d WHERE n.submission_id = d.submission_id;
+
+-- mentions and scores of duplicates are found again by -redditBackfillMentions and -sentiment
+DO $$
+BEGIN
+	IF to_regclass('redditlistingmention') IS NOT NULL THEN
+		DELETE FROM RedditListingMention m USING submission_duplicate d WHERE m.submission_id = d.submission_id;
+	END IF;
+	IF to_regclass('redditsubmissionsentiment') IS NOT NULL THEN
+		DELETE FROM RedditSubmissionSentiment s USING submission_duplicate d WHERE s.submission_id = d.submission_id;
+	END IF;
+END $$;
+DELETE FROM RedditSubmission s USING submission_duplicate d WHERE s.submission_id = d.submission_id;
+
+-- duplicate comments, including those that became duplicates when their submissions were merged
+CREATE TEMP TABLE comment_duplicate AS
+SELECT comment_id FROM (
+	SELECT comment_id, min(comment_id) OVER (PARTITION BY submission_id, reddit_id) AS keep_id
+	FROM RedditComment
+	WHERE reddit_id IS NOT NULL
+) copies
+WHERE comment_id <> keep_id;
+
+DO $$
+BEGIN
+	IF to_regclass('redditlistingmention') IS NOT NULL THEN
+		DELETE FROM RedditListingMention m USING comment_duplicate d WHERE m.comment_id = d.comment_id;
+	END IF;
+	IF to_regclass('redditcommentsentiment') IS NOT NULL THEN
+		DELETE FROM RedditCommentSentiment s USING comment_duplicate d WHERE s.comment_id = d.comment_id;
+	END IF;
+END $$;
+DELETE FROM RedditComment c USING comment_duplicate d WHERE c.comment_id = d.comment_id;
+
+ALTER TABLE RedditSubmission ADD CONSTRAINT redditsubmission_reddit_id_key UNIQUE(reddit_id);
+ALTER TABLE RedditComment ADD CONSTRAINT comment_reddit_key UNIQUE(submission_id, reddit_id);
+
+-- the comment key starts with submission_id, so it replaces this index from sentiment.sql
+DROP INDEX IF EXISTS comment_submission_index;
+
+COMMIT;
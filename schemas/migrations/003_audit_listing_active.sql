@@ -0,0 +1,5 @@
+-- Adds is_active to AuditListing, which the stocks app's audit insert and the API's listing history read.
+-- New databases get this from stocks.sql.
+-- Audit rows written before this migration have a NULL is_active, which the API reads as active.
+
+ALTER TABLE AuditListing ADD COLUMN IF NOT EXISTS is_active boolean;
@@ -0,0 +1,4 @@
+-- Drops RedditSubmissionSnapshot.upvote_ratio, which the reddit client never returned so it was always NULL.
+-- New databases do not have it in reddit.sql. Nothing reads the column, so this can run at any time.
+
+ALTER TABLE IF EXISTS RedditSubmissionSnapshot DROP COLUMN IF EXISTS upvote_ratio;
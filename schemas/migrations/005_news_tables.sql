@@ -0,0 +1,208 @@
+-- Adds the news tables and NewsArticle columns that the crawlers, backfills and reddit apps write to existing databases.
+-- New databases get these from news.sql, stocks.sql and reddit.sql.
+-- Run after stocks.sql and reddit.sql, since ArticleListing and RedditListingMention reference Listing and RedditSubmission.
+-- Articles stored before this migration have NULL in the new columns, which every reader already allows.
+
+BEGIN;
+
+-- Catalog of rss feeds and sitemaps to poll
+CREATE TABLE IF NOT EXISTS NewsFeed(
+	feed_id bigserial PRIMARY KEY,
+	data_entry_time timestamptz,
+	name text,
+	link text,
+	rss text UNIQUE NOT NULL,
+	feed_type text DEFAULT 'rss',
+	category text,
+	language text,
+	region text,
+	enabled boolean DEFAULT true,
+	poll_interval int DEFAULT 0
+);
+
+ALTER TABLE NewsArticle ADD COLUMN IF NOT EXISTS feed_id bigint REFERENCES NewsFeed(feed_id);
+CREATE INDEX IF NOT EXISTS article_feed_index ON NewsArticle(feed_id);
+
+ALTER TABLE NewsArticle ADD COLUMN IF NOT EXISTS language text;
+CREATE INDEX IF NOT EXISTS article_language_index ON NewsArticle(language);
+
+ALTER TABLE NewsArticle ADD COLUMN IF NOT EXISTS published_time_source text;
+
+ALTER TABLE NewsArticle ADD COLUMN IF NOT EXISTS extraction_status text;
+CREATE INDEX IF NOT EXISTS article_extraction_status_index ON NewsArticle(extraction_status);
+
+ALTER TABLE NewsArticle ADD COLUMN IF NOT EXISTS fetch_link text;
+
+CREATE TABLE IF NOT EXISTS NewsFingerprint(
+	article_id bigint PRIMARY KEY REFERENCES NewsArticle(article_id),
+	data_entry_time timestamptz,
+	simhash bigint,
+	band_0 int,
+	band_1 int,
+	band_2 int,
+	band_3 int,
+	duplicate_of bigint REFERENCES NewsArticle(article_id),
+	cluster_id bigint
+);
+CREATE INDEX IF NOT EXISTS fingerprint_band_0_index ON NewsFingerprint(band_0);
+CREATE INDEX IF NOT EXISTS fingerprint_band_1_index ON NewsFingerprint(band_1);
+CREATE INDEX IF NOT EXISTS fingerprint_band_2_index ON NewsFingerprint(band_2);
+CREATE INDEX IF NOT EXISTS fingerprint_band_3_index ON NewsFingerprint(band_3);
+CREATE INDEX IF NOT EXISTS fingerprint_cluster_index ON NewsFingerprint(cluster_id);
+
+CREATE TABLE IF NOT EXISTS NewsFeedState(
+	rss text PRIMARY KEY,
+	name text,
+	etag text,
+	last_modified text,
+	last_attempt_time timestamptz,
+	last_success_time timestamptz,
+	last_item_time timestamptz,
+	consecutive_failures int DEFAULT 0,
+	last_error text,
+	last_item_count int,
+	items_per_day double precision
+);
+
+CREATE TABLE IF NOT EXISTS NewsDisallowed(
+	link text PRIMARY KEY,
+	host text,
+	source text,
+	data_entry_time timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS NewsBlackList(
+	rule_id serial PRIMARY KEY,
+	rule_type text DEFAULT 'host',
+	value text,
+	reason text,
+	expires timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS HackerNews(
+	CONSTRAINT hn_link_id PRIMARY KEY(article_id, item_id),
+	article_id bigint REFERENCES NewsArticle(article_id),
+	item_id bigint,
+	score int,
+	comments int,
+	data_entry_time timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS NewsBackfill(
+	name text PRIMARY KEY,
+	range_start timestamptz,
+	range_end timestamptz,
+	last_id bigint DEFAULT 0,
+	num_processed bigint DEFAULT 0,
+	num_articles bigint DEFAULT 0,
+	start_time timestamptz,
+	updated_time timestamptz,
+	completed_time timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS ArticleRevision(
+	revision_id bigserial PRIMARY KEY,
+	article_id bigint REFERENCES NewsArticle(article_id),
+	data_entry_time timestamptz,
+	revision_number int,
+	recrawl_age text,
+	title text,
+	body text,
+	title_changed boolean,
+	body_changed boolean,
+	diff text
+);
+CREATE INDEX IF NOT EXISTS revision_article_index ON ArticleRevision(article_id);
+
+CREATE TABLE IF NOT EXISTS ArticleRecrawl(
+	article_id bigint PRIMARY KEY REFERENCES NewsArticle(article_id),
+	recrawl_count int,
+	last_recrawl_time timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS NewsAuthor(
+	author_id bigserial PRIMARY KEY,
+	data_entry_time timestamptz,
+	name text,
+	name_key text UNIQUE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS ArticleAuthor(
+	CONSTRAINT article_author_id PRIMARY KEY(article_id, author_id),
+	article_id bigint REFERENCES NewsArticle(article_id),
+	author_id bigint REFERENCES NewsAuthor(author_id),
+	position int
+);
+CREATE INDEX IF NOT EXISTS article_author_author_index ON ArticleAuthor(author_id);
+
+CREATE TABLE IF NOT EXISTS NewsArchive(
+	archive_id bigserial PRIMARY KEY,
+	article_id bigint REFERENCES NewsArticle(article_id),
+	data_entry_time timestamptz,
+	link text,
+	status_code int,
+	content_type text,
+	warc_file text,
+	warc_offset bigint,
+	warc_length bigint
+);
+CREATE INDEX IF NOT EXISTS archive_article_index ON NewsArchive(article_id);
+CREATE INDEX IF NOT EXISTS archive_link_index ON NewsArchive(link);
+
+CREATE TABLE IF NOT EXISTS NewsHostQuality(
+	host text PRIMARY KEY,
+	num_articles bigint,
+	num_ok bigint,
+	num_paywall bigint,
+	num_botwall bigint,
+	num_truncated bigint,
+	num_short bigint,
+	typical_length double precision,
+	updated_time timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS ArticleListing(
+	CONSTRAINT article_listing_key PRIMARY KEY(article_id, listing_id),
+	article_id bigint REFERENCES NewsArticle(article_id),
+	listing_id int REFERENCES Listing(listing_id),
+	mention_count int,
+	confidence real,
+	match_types text,
+	data_entry_time timestamptz
+);
+CREATE INDEX IF NOT EXISTS article_listing_listing_index ON ArticleListing(listing_id);
+
+CREATE TABLE IF NOT EXISTS RedditSubmissionSnapshot(
+	snapshot_id bigserial PRIMARY KEY,
+	reddit_id text,
+	submission_id bigint REFERENCES RedditSubmission(submission_id),
+	snapshot_age text,
+	score int,
+	up_votes int,
+	num_comments int,
+	awards int,
+	is_deleted boolean,
+	is_removed boolean,
+	is_locked boolean,
+	created_time timestamptz,
+	data_entry_time timestamptz
+);
+CREATE INDEX IF NOT EXISTS snapshot_reddit_id_index ON RedditSubmissionSnapshot(reddit_id);
+CREATE INDEX IF NOT EXISTS snapshot_submission_index ON RedditSubmissionSnapshot(submission_id);
+
+CREATE TABLE IF NOT EXISTS RedditListingMention(
+	mention_id bigserial PRIMARY KEY,
+	submission_id bigint REFERENCES RedditSubmission(submission_id),
+	comment_id bigint REFERENCES RedditComment(comment_id),
+	listing_id int REFERENCES Listing(listing_id),
+	mention_count int,
+	confidence real,
+	match_types text,
+	created_time timestamptz,
+	data_entry_time timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS reddit_mention_submission_key ON RedditListingMention(submission_id, listing_id) WHERE comment_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS reddit_mention_comment_key ON RedditListingMention(comment_id, listing_id) WHERE comment_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS reddit_mention_listing_index ON RedditListingMention(listing_id, created_time);
+
+COMMIT;
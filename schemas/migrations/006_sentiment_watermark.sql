@@ -0,0 +1,11 @@
+-- Adds the watermarks that incremental sentiment runs resume from to existing databases.
+-- New databases get this from sentiment.sql.
+-- The first incremental run after this migration starts after the newest scored content of each kind.
+
+CREATE TABLE IF NOT EXISTS SentimentWatermark(
+	kind text PRIMARY KEY,
+	last_id bigint DEFAULT 0,
+	num_scored bigint DEFAULT 0,
+	num_runs bigint DEFAULT 0,
+	updated_time timestamptz
+);
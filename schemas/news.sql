@@ -1,16 +1,40 @@
+-- Catalog of rss feeds and sitemaps to poll
+CREATE TABLE NewsFeed(
+	feed_id bigserial PRIMARY KEY,
+	data_entry_time timestamptz, -- when the feed was added
+	name text,
+	link text, -- website of the feed's publisher
+	rss text UNIQUE NOT NULL, -- rss feed or sitemap url
+	feed_type text DEFAULT 'rss', -- rss or sitemap
+	category text,
+	language text, -- ISO 639-1 code (e.g. en)
+	region text, -- ISO 3166-1 alpha-2 code (e.g. US)
+	enabled boolean DEFAULT true, -- disabled feeds are not polled
+	poll_interval int DEFAULT 0 -- minimum minutes between polls, zero for every run
+);
+
 CREATE TABLE NewsArticle(
 	article_id bigserial PRIMARY KEY,
 	data_entry_time timestamptz, -- when this data was collected and inserted
 	source text, -- source of the article/where we got the link
 	host text, -- hostname parsed from url
-	link text, -- originally queried link url
+	link text, -- originally queried link url, canonicalized
 	source_published_time timestamptz, -- time published from reference source
-	published_time timestamptz, -- time published from newspaper3k
+	published_time timestamptz, -- time published from newspaper3k, or from the reference source if newspaper3k's is missing or implausible
 	source_title text, -- title from reference source
 	title text, -- title from newspaper3k
-	canonical_link text, -- canonical link if one exists
+	canonical_link text, -- canonicalized newspaper3k canonical link if one exists
 	body text, -- main article text
-	authors text -- json array of authors
+	authors text, -- json array of authors from newspaper3k, normalized authors are in ArticleAuthor
+	feed_id bigint REFERENCES NewsFeed(feed_id), -- catalog feed the link came from, null for other sources
+	language text, -- ISO 639-1 code detected from the body, null if unknown
+	published_time_source text, -- where published_time came from: page (newspaper3k) or source (reference source), null if unknown
+	extraction_status text, -- quality of the body: ok, paywall, botwall, truncated, or short, null for articles stored before classification
+	fetch_link text, -- url that was fetched if the canonicalized link differs from it (e.g. amp and mobile pages), used to recrawl
+	search tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('english', coalesce(title, source_title, '')), 'A') ||
+		setweight(to_tsvector('english', coalesce(body, '')), 'B')
+	) STORED -- full text search document, titles rank above body text
 );
 
 -- Set id to start at 1000 instead of 1
@@ -18,3 +42,150 @@ ALTER SEQUENCE NewsArticle_article_id_seq RESTART WITH 1000;
 -- Indecies
 CREATE INDEX article_source_published_time_index ON newsarticle(source_published_time NULLS LAST);
 CREATE INDEX article_source_published_time_desc_index ON newsarticle(source_published_time DESC NULLS LAST);
+CREATE INDEX article_feed_index ON newsarticle(feed_id);
+CREATE INDEX article_language_index ON newsarticle(language);
+CREATE INDEX article_extraction_status_index ON newsarticle(extraction_status);
+CREATE INDEX article_search_index ON newsarticle USING GIN(search);
+
+
+-- SimHash fingerprints for finding near duplicate (syndicated) articles
+CREATE TABLE NewsFingerprint(
+	article_id bigint PRIMARY KEY REFERENCES NewsArticle(article_id),
+	data_entry_time timestamptz,
+	simhash bigint, -- 64 bit simhash of the body shingles
+	band_0 int, -- 16 bit bands of the simhash used as an LSH index
+	band_1 int,
+	band_2 int,
+	band_3 int,
+	duplicate_of bigint REFERENCES NewsArticle(article_id), -- earliest near duplicate article if one exists
+	cluster_id bigint -- article_id of the first article in the cluster
+);
+-- Indecies
+CREATE INDEX fingerprint_band_0_index ON NewsFingerprint(band_0);
+CREATE INDEX fingerprint_band_1_index ON NewsFingerprint(band_1);
+CREATE INDEX fingerprint_band_2_index ON NewsFingerprint(band_2);
+CREATE INDEX fingerprint_band_3_index ON NewsFingerprint(band_3);
+CREATE INDEX fingerprint_cluster_index ON NewsFingerprint(cluster_id);
+
+-- Polling state for each rss feed and sitemap
+CREATE TABLE NewsFeedState(
+	rss text PRIMARY KEY, -- rss feed url
+	name text, -- feed name from the rss list
+	etag text, -- ETag header from the last successful response
+	last_modified text, -- Last-Modified header from the last successful response
+	last_attempt_time timestamptz,
+	last_success_time timestamptz,
+	last_item_time timestamptz, -- publish time of the newest item seen
+	consecutive_failures int DEFAULT 0,
+	last_error text,
+	last_item_count int, -- number of items in the last full response
+	items_per_day double precision -- moving average of items published per day
+);
+
+-- Links that the host's robots.txt does not allow us to fetch
+CREATE TABLE NewsDisallowed(
+	link text PRIMARY KEY, -- canonicalized link url
+	host text, -- hostname parsed from url
+	source text, -- source of the link
+	data_entry_time timestamptz
+);
+
+-- Rules for links that should be excluded from NewsArticle
+-- Used when NEWSPAPER_BLACKLIST_FILEPATH is not set
+CREATE TABLE NewsBlackList(
+	rule_id serial PRIMARY KEY,
+	rule_type text DEFAULT 'host', -- host, domain, suffix, path, or regex
+	value text, -- value matched according to the rule type
+	reason text, -- why links matching this rule are excluded
+	expires timestamptz -- rule is ignored after this time, null for never
+);
+
+-- Links Hacker News stories to news articles
+CREATE TABLE HackerNews(
+	CONSTRAINT hn_link_id PRIMARY KEY(article_id, item_id),
+	article_id bigint REFERENCES NewsArticle(article_id),
+	item_id bigint, -- Hacker News item id
+	score int, -- story score when last seen
+	comments int, -- total comment count when last seen
+	data_entry_time timestamptz -- when score and comments were last updated
+);
+
+-- Checkpoints for resumable backfills
+CREATE TABLE NewsBackfill(
+	name text PRIMARY KEY, -- backfill kind and date range (e.g. reddit:2020-01-01:2020-02-01)
+	range_start timestamptz, -- inclusive
+	range_end timestamptz, -- exclusive
+	last_id bigint DEFAULT 0, -- last source ID that was fully processed
+	num_processed bigint DEFAULT 0,
+	num_articles bigint DEFAULT 0, -- new articles added
+	start_time timestamptz,
+	updated_time timestamptz,
+	completed_time timestamptz -- null until the whole range is processed
+);
+
+-- Changed versions of articles found by recrawling
+CREATE TABLE ArticleRevision(
+	revision_id bigserial PRIMARY KEY,
+	article_id bigint REFERENCES NewsArticle(article_id),
+	data_entry_time timestamptz,
+	revision_number int, -- 1 for the first change after the original article
+	recrawl_age text, -- age of the article when it was recrawled (e.g. 24h)
+	title text, -- title after this revision
+	body text, -- body after this revision
+	title_changed boolean,
+	body_changed boolean,
+	diff text -- line diff from the previous version
+);
+CREATE INDEX revision_article_index ON ArticleRevision(article_id);
+
+-- Recrawl progress for each article
+CREATE TABLE ArticleRecrawl(
+	article_id bigint PRIMARY KEY REFERENCES NewsArticle(article_id),
+	recrawl_count int, -- number of recrawl ages that have been reached
+	last_recrawl_time timestamptz
+);
+
+-- Normalized article authors
+CREATE TABLE NewsAuthor(
+	author_id bigserial PRIMARY KEY,
+	data_entry_time timestamptz, -- when the author was first seen
+	name text, -- cleaned display name
+	name_key text UNIQUE NOT NULL -- lower case name without punctuation, used to match authors
+);
+
+-- Links authors to the articles they wrote
+CREATE TABLE ArticleAuthor(
+	CONSTRAINT article_author_id PRIMARY KEY(article_id, author_id),
+	article_id bigint REFERENCES NewsArticle(article_id),
+	author_id bigint REFERENCES NewsAuthor(author_id),
+	position int -- order of the author in the byline, starting at 0
+);
+CREATE INDEX article_author_author_index ON ArticleAuthor(author_id);
+
+-- Index of raw HTTP responses archived in WARC files for reprocessing
+CREATE TABLE NewsArchive(
+	archive_id bigserial PRIMARY KEY,
+	article_id bigint REFERENCES NewsArticle(article_id), -- null until the page's article is inserted, or if it never is
+	data_entry_time timestamptz, -- when the page was fetched
+	link text, -- link that was fetched
+	status_code int, -- HTTP response status
+	content_type text, -- HTTP response Content-Type
+	warc_file text, -- file name in the NEWSPAPER_WARC_DIR directory
+	warc_offset bigint, -- byte offset of the record's gzip member in the file
+	warc_length bigint -- compressed length of the record
+);
+CREATE INDEX archive_article_index ON NewsArchive(article_id);
+CREATE INDEX archive_link_index ON NewsArchive(link);
+
+-- Extraction quality statistics by host
+CREATE TABLE NewsHostQuality(
+	host text PRIMARY KEY,
+	num_articles bigint, -- articles classified
+	num_ok bigint, -- articles with each extraction status
+	num_paywall bigint,
+	num_botwall bigint,
+	num_truncated bigint,
+	num_short bigint,
+	typical_length double precision, -- moving average body length of ok articles
+	updated_time timestamptz
+);
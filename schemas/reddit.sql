@@ -1,7 +1,7 @@
 -- fields described at https://github.com/reddit-archive/reddit/wiki/JSON
 CREATE TABLE RedditSubmission(
 	submission_id bigserial PRIMARY KEY,
-	reddit_id text, -- reddit's unique ID for this submission
+	reddit_id text UNIQUE, -- reddit's unique ID for this submission
 	title text,
 	url text, -- link to what this post is about
 	permalink text, -- reddits relative url for this submission
@@ -17,11 +17,16 @@ CREATE TABLE RedditSubmission(
 	up_votes int,
 	down_votes int,
 	is_nsfw boolean,
-	is_self boolean
+	is_self boolean,
+	search tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+		setweight(to_tsvector('english', coalesce(selftext, '')), 'B')
+	) STORED -- full text search document, titles rank above self text
 );
 
 -- Set id to start at 1000 instead of 1
 ALTER SEQUENCE redditsubmission_submission_id_seq RESTART WITH 1000;
+CREATE INDEX submission_search_index ON RedditSubmission USING GIN(search);
 
 CREATE TABLE RedditComment(
 	comment_id bigserial PRIMARY KEY,
@@ -35,12 +40,15 @@ CREATE TABLE RedditComment(
 	body_html text,
 	up_votes int,
 	down_votes int,
-	is_deleted boolean
+	is_deleted boolean,
+	search tsvector GENERATED ALWAYS AS (to_tsvector('english', coalesce(body, ''))) STORED, -- full text search document
+	CONSTRAINT comment_reddit_key UNIQUE(submission_id, reddit_id)
 );
 
 
 -- Set id to start at 1000 instead of 1
 ALTER SEQUENCE redditcomment_comment_id_seq RESTART WITH 1000;
+CREATE INDEX comment_search_index ON RedditComment USING GIN(search);
 
 CREATE TABLE RedditNews(
 	CONSTRAINT link_id PRIMARY KEY(article_id, submission_id),
@@ -48,3 +56,38 @@ CREATE TABLE RedditNews(
 	submission_id bigint REFERENCES RedditSubmission(submission_id),
 	data_entry_time timestamptz
 );
+
+-- Votes and status of submissions at each snapshot age, appended on every fetch
+CREATE TABLE RedditSubmissionSnapshot(
+	snapshot_id bigserial PRIMARY KEY,
+	reddit_id text, -- reddit's unique ID for the submission
+	submission_id bigint REFERENCES RedditSubmission(submission_id), -- null until the submission is stored, or if it never is
+	snapshot_age text, -- scheduled age of the submission from REDDIT_SNAPSHOT_AGES (e.g. 6h)
+	score int,
+	up_votes int,
+	num_comments int,
+	awards int, -- times gilded
+	is_deleted boolean, -- deleted by its author
+	is_removed boolean, -- removed by moderators, only known for self posts
+	is_locked boolean,
+	created_time timestamptz, -- when the submission was posted
+	data_entry_time timestamptz -- when the snapshot was fetched
+);
+CREATE INDEX snapshot_reddit_id_index ON RedditSubmissionSnapshot(reddit_id);
+CREATE INDEX snapshot_submission_index ON RedditSubmissionSnapshot(submission_id);
+
+-- Listings mentioned in submissions and comments, run after stocks.sql
+CREATE TABLE RedditListingMention(
+	mention_id bigserial PRIMARY KEY,
+	submission_id bigint REFERENCES RedditSubmission(submission_id),
+	comment_id bigint REFERENCES RedditComment(comment_id), -- null for mentions in the submission's title and self text
+	listing_id int REFERENCES Listing(listing_id),
+	mention_count int,
+	confidence real, -- chance the text is about the listing, from 0 to 1
+	match_types text, -- comma separated kinds of mentions: cashtag, exchange, ticker
+	created_time timestamptz, -- when the submission or comment was posted
+	data_entry_time timestamptz
+);
+CREATE UNIQUE INDEX reddit_mention_submission_key ON RedditListingMention(submission_id, listing_id) WHERE comment_id IS NULL;
+CREATE UNIQUE INDEX reddit_mention_comment_key ON RedditListingMention(comment_id, listing_id) WHERE comment_id IS NOT NULL;
+CREATE INDEX reddit_mention_listing_index ON RedditListingMention(listing_id, created_time);
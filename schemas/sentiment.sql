@@ -0,0 +1,47 @@
+-- Lexicon sentiment scores, run after news.sql and reddit.sql
+-- compound is from -1 (most negative) to 1 (most positive), positive, negative, and neutral are shares of the text
+CREATE TABLE ArticleSentiment(
+	article_id bigint PRIMARY KEY REFERENCES NewsArticle(article_id),
+	compound real,
+	positive real,
+	negative real,
+	neutral real,
+	num_sentences int, -- sentences scored in the title and body
+	version smallint, -- version of the lexicon and rules that made the scores
+	data_entry_time timestamptz
+);
+
+CREATE TABLE RedditSubmissionSentiment(
+	submission_id bigint PRIMARY KEY REFERENCES RedditSubmission(submission_id),
+	compound real,
+	positive real,
+	negative real,
+	neutral real,
+	num_sentences int, -- sentences scored in the title and self text
+	version smallint,
+	data_entry_time timestamptz
+);
+
+CREATE TABLE RedditCommentSentiment(
+	comment_id bigint PRIMARY KEY REFERENCES RedditComment(comment_id),
+	compound real,
+	positive real,
+	negative real,
+	neutral real,
+	num_sentences int, -- sentences scored in the body
+	version smallint,
+	data_entry_time timestamptz
+);
+
+-- Series group content by these, comments are grouped through their unique (submission_id, reddit_id) key
+CREATE INDEX article_host_index ON NewsArticle(host);
+CREATE INDEX submission_subreddit_index ON RedditSubmission(lower(subreddit_name));
+
+-- How far each kind of content has been scored by incremental runs
+CREATE TABLE SentimentWatermark(
+	kind text PRIMARY KEY, -- article, submission, or comment
+	last_id bigint DEFAULT 0, -- key of the last content read
+	num_scored bigint DEFAULT 0, -- content with text scored over all runs
+	num_runs bigint DEFAULT 0,
+	updated_time timestamptz
+);
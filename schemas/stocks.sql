@@ -27,7 +27,8 @@ CREATE TABLE AuditListing(
 	currency text,
 	exchange text,
 	is_sp500 boolean,
-	is_russell3000 boolean
+	is_russell3000 boolean,
+	is_active boolean
 );
 
 CREATE TABLE DataSource(
@@ -57,4 +58,15 @@ ALTER SEQUENCE intraday_intraday_id_seq RESTART WITH 1000;
 CREATE INDEX index_intra_time ON Intraday (listing_id, data_time);
 
 -- Add values to data source 
-INSERT INTO DataSource (source_id, source_name) VALUES (1, 'IEX'), (2, 'Alpha Vantage');
\ No newline at end of file
+INSERT INTO DataSource (source_id, source_name) VALUES (1, 'IEX'), (2, 'Alpha Vantage');
+-- Listings mentioned in news articles, run after news.sql
+CREATE TABLE ArticleListing(
+	CONSTRAINT article_listing_key PRIMARY KEY(article_id, listing_id),
+	article_id bigint REFERENCES NewsArticle(article_id),
+	listing_id int REFERENCES Listing(listing_id),
+	mention_count int, -- mentions in the title and body
+	confidence real, -- chance the article is about the listing, from 0 to 1
+	match_types text, -- comma separated kinds of mentions: cashtag, exchange, name
+	data_entry_time timestamptz
+);
+CREATE INDEX article_listing_listing_index ON ArticleListing(listing_id);